@@ -0,0 +1,75 @@
+package main
+
+import (
+	"L0/internal/archive"
+	"L0/internal/config"
+	"context"
+	"flag"
+	"fmt"
+	"go.uber.org/zap"
+	"time"
+)
+
+// runArchive выгружает старые заказы в файлы или возвращает их обратно в PostgreSQL.
+// Пример: archive run -older-than-days 180, archive restore -from 2024-01-01 -to 2024-02-01
+func runArchive(ctx context.Context, cfg *config.Config, log *zap.Logger, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("archive: expected run or restore")
+	}
+
+	fs := flag.NewFlagSet("archive "+args[0], flag.ContinueOnError)
+	dir := fs.String("dir", cfg.Archive.Dir, "directory with archive files")
+	var olderThanDays *int
+	var dryRun *bool
+	var from, to *string
+	switch args[0] {
+	case "run":
+		olderThanDays = fs.Int("older-than-days", cfg.Archive.OlderThanDays, "archive orders created more than N days ago")
+		dryRun = fs.Bool("dry-run", false, "write and verify files without deleting orders")
+	case "restore":
+		from = fs.String("from", "", "first day to restore, YYYY-MM-DD")
+		to = fs.String("to", "", "day to stop at (exclusive), YYYY-MM-DD")
+	default:
+		return fmt.Errorf("archive: unknown subcommand %q", args[0])
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *dir == "" {
+		return fmt.Errorf("archive: directory is not configured")
+	}
+
+	b, err := newBackend(ctx, cfg, nil, log)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+	archiver := archive.NewArchiver(b.repo, b.orders, *dir, log)
+
+	if args[0] == "run" {
+		if *olderThanDays <= 0 {
+			return fmt.Errorf("archive run: -older-than-days must be positive")
+		}
+		before := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -*olderThanDays)
+		stats, err := archiver.Archive(ctx, before, *dryRun)
+		archived, deleted := 0, int64(0)
+		for _, st := range stats {
+			archived += st.Orders
+			deleted += st.Deleted
+		}
+		log.Info("Archive finished", zap.Int("days", len(stats)), zap.Int("archived", archived), zap.Int64("deleted", deleted))
+		return err
+	}
+
+	fromDay, err := time.Parse(time.DateOnly, *from)
+	if err != nil {
+		return fmt.Errorf("invalid -from: %w", err)
+	}
+	toDay, err := time.Parse(time.DateOnly, *to)
+	if err != nil {
+		return fmt.Errorf("invalid -to: %w", err)
+	}
+	restored, err := archiver.Restore(ctx, fromDay, toDay)
+	log.Info("Restore finished", zap.Int("restored", restored))
+	return err
+}
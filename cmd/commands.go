@@ -0,0 +1,372 @@
+package main
+
+import (
+	"L0/internal/application"
+	"L0/internal/auth"
+	"L0/internal/config"
+	"L0/internal/events"
+	"L0/internal/fieldcrypt"
+	"L0/internal/graphqlapi"
+	"L0/internal/grpcapi"
+	"L0/internal/masking"
+	"L0/internal/messagebroker"
+	"L0/internal/ratelimit"
+	"L0/internal/redis_client"
+	"L0/internal/repository"
+	"L0/internal/router"
+	"L0/internal/router/handlers"
+	"L0/internal/service"
+	"L0/internal/webhook"
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"strconv"
+	"time"
+)
+
+const usage = `Usage: L0 <command> [args]
+
+Commands:
+  all                    HTTP and gRPC servers, Kafka consumer and cache preload (default)
+  serve                  HTTP and gRPC servers only
+  consume                Kafka consumer only
+  preload                load recent orders into Redis and exit
+  migrate up             apply all pending migrations
+  migrate down [N|all]   roll back N migrations (one if N is omitted), all drops every table
+  migrate to V           migrate up or down to version V
+  migrate force V        set version V and clear the dirty flag without running migrations
+  migrate version        print current and expected schema version
+  replay [flags]         reprocess a range of the topic, see "replay -h"
+  partitions maintain    create upcoming order partitions and apply retention once
+  archive run [flags]    move old orders to gzip NDJSON files, see "archive run -h"
+  archive restore [flags] load archived orders for a date range back into PostgreSQL
+  pii erase CUSTOMER_ID  anonymize delivery data of all customer orders and evict them from Redis
+  encryption rotate [N]  re-encrypt delivery data with the primary key in batches of N rows (default 500)
+  auth hash-key KEY      print the sha256 hash of an API key for auth.api_keys
+
+All commands read the same config file (CONFIG_PATH, default ./config/config.yaml).
+`
+
+// backend - сервис заказов вместе с хранилищем и репозиторием, которыми он владеет. Вебхуки,
+// GraphQL и обслуживание партиций работают с тем же пулом и тем же шифром.
+type backend struct {
+	orders  *service.OrderService
+	storage *repository.Storage
+	repo    *repository.Repository
+}
+
+// newBackend поднимает хранилище и кэш. consumer может быть nil для команд, которые не читают Kafka.
+func newBackend(ctx context.Context, cfg *config.Config, consumer service.Consumer, log *zap.Logger) (*backend, error) {
+	cipher, err := fieldcrypt.New(cfg.Encryption)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize encryption: %w", err)
+	}
+	storage, err := repository.NewStorage(ctx, cfg.Storage, log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	repo := storage.NewRepository(cipher)
+	redisClient, err := redisClient.NewRedisClient(ctx, cfg.RedisAddr, cfg.RedisPassword, cfg.DB, cipher, log)
+	if err != nil {
+		repo.Close()
+		return nil, fmt.Errorf("failed to initialize redis_client client: %w", err)
+	}
+	return &backend{
+		orders:  service.NewOrderService(consumer, repo, redisClient, cfg.TTL, log),
+		storage: storage,
+		repo:    repo,
+	}, nil
+}
+
+func (b *backend) Close() {
+	_ = b.orders.CloseConsumer()
+	b.orders.CloseRepo()
+	b.orders.CloseRedisClient()
+}
+
+func runApp(ctx context.Context, cfg *config.Config, log *zap.Logger, opts application.Options) error {
+	var consumer service.Consumer
+	if opts.Consume {
+		consumer = messagebroker.NewConsumer(cfg.Brokers, cfg.Topic, log)
+	}
+	b, err := newBackend(ctx, cfg, consumer, log)
+	if err != nil {
+		return err
+	}
+	// После запуска app сама закрывает консьюмер, репозиторий и Redis при остановке, до запуска это делает defer.
+	started := false
+	defer func() {
+		if !started {
+			b.Close()
+		}
+	}()
+
+	guard, err := webhook.NewGuard(cfg.Webhooks.AllowedNetworks)
+	if err != nil {
+		return err
+	}
+
+	var rout *router.Router
+	var hub *events.Hub
+	var grpcServer *grpc.Server
+	if opts.Serve {
+		masker, err := masking.New(cfg.Masking)
+		if err != nil {
+			return fmt.Errorf("failed to initialize masking: %w", err)
+		}
+		var authn *auth.Authenticator
+		if cfg.Auth.Enabled {
+			if authn, err = auth.New(cfg.Auth); err != nil {
+				return fmt.Errorf("failed to initialize auth: %w", err)
+			}
+		}
+		var limiter *ratelimit.Limiter
+		if cfg.RateLimit.Enabled {
+			// Отдельный клиент Redis живёт, пока работает app.Run.
+			limiterClient := redis.NewClient(&redis.Options{Addr: cfg.RedisAddr, Password: cfg.RedisPassword, DB: cfg.DB})
+			defer limiterClient.Close()
+			limiter = ratelimit.NewLimiter(limiterClient)
+		}
+		var stream *handlers.StreamHandlers
+		// Поток наполняет консьюмер этого же процесса, без него подписчики не получат ни одного заказа.
+		if cfg.Stream.Enabled && opts.Consume {
+			hub = events.NewHub(cfg.Stream.Buffer, cfg.Stream.MaxSubscribers, log)
+			stream = handlers.NewStreamHandlers(hub, cfg.Stream.Heartbeat, log)
+		}
+		var webhookHandlers *handlers.WebhookHandlers
+		if cfg.Webhooks.Enabled {
+			webhookHandlers = handlers.NewWebhookHandlers(b.repo, guard, log)
+		}
+		var graphqlHandlers *handlers.GraphQLHandlers
+		if cfg.GraphQL.Enabled {
+			graphqlServer, err := graphqlapi.NewServer(b.repo, masker, cfg.GraphQL, log)
+			if err != nil {
+				return err
+			}
+			graphqlHandlers = handlers.NewGraphQLHandlers(graphqlServer, log)
+		}
+		handler := handlers.NewOrderHandlers(b.orders, masker, log)
+		rout, err = router.NewRouter(handler, cfg.LogLevel, log, router.Options{
+			Auth:           authn,
+			Masking:        cfg.Masking,
+			Limiter:        limiter,
+			RateLimit:      cfg.RateLimit,
+			AccessLog:      cfg.Rest.AccessLog,
+			CacheMaxAge:    cfg.Rest.CacheMaxAge,
+			Compression:    cfg.Rest.Compression,
+			Stream:         stream,
+			Webhooks:       webhookHandlers,
+			GraphQL:        graphqlHandlers,
+			LegacyRoutes:   cfg.Rest.LegacyRoutes,
+			TrustedProxies: cfg.Rest.TrustedProxies,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to initialize router: %w", err)
+		}
+
+		if cfg.GRPC.Enabled {
+			// SubmitOrder пишет в тот же топик, что и внешние продюсеры: сохраняет заказ консьюмер.
+			producer, err := messagebroker.NewProducer(cfg.Brokers, cfg.Topic, messagebroker.ProducerConfig{
+				BatchSize:     cfg.Producer.BatchSize,
+				BatchBytes:    cfg.Producer.BatchBytes,
+				BatchTimeout:  cfg.Producer.BatchTimeout,
+				WriteTimeout:  cfg.Producer.WriteTimeout,
+				MaxAttempts:   cfg.Producer.MaxAttempts,
+				Compression:   cfg.Producer.Compression,
+				RequiredAcks:  cfg.Producer.RequiredAcks,
+				Balancer:      cfg.Producer.Balancer,
+				Async:         cfg.Producer.Async,
+				SchemaVersion: cfg.Producer.SchemaVersion,
+			}, log)
+			if err != nil {
+				return fmt.Errorf("failed to initialize producer: %w", err)
+			}
+			defer producer.Close()
+			grpcServer = grpcapi.NewServer(b.orders, masker, log, grpcapi.Options{
+				Auth:       authn,
+				Hub:        hub,
+				Submitter:  producer,
+				Reflection: cfg.GRPC.Reflection,
+				Limiter:    limiter,
+				RateLimit:  cfg.RateLimit,
+			})
+		}
+	}
+
+	app := application.NewApp(b.orders, rout, cfg.Rest.Addr, log)
+	if hub != nil {
+		app.PublishTo(hub)
+	}
+	if grpcServer != nil {
+		app.ServeGRPC(grpcServer, cfg.GRPC.Addr)
+	}
+	// Доставляет вебхуки процесс, который сохраняет заказы.
+	if cfg.Webhooks.Enabled && opts.Consume {
+		dispatcher := webhook.NewDispatcher(b.repo, cfg.Webhooks, guard, log)
+		b.orders.Observe(dispatcher)
+		app.AddWorker("webhooks", dispatcher.Run)
+	}
+	// Обслуживание партиций идёт вместе с консьюмером: именно он пишет в таблицы заказов.
+	if opts.Consume && cfg.Partitioning.Enabled {
+		partitions, err := b.storage.NewPartitionManager(cfg.Partitioning)
+		if err != nil {
+			return err
+		}
+		app.AddWorker("partitions", partitions.Run)
+	}
+	started = true
+	return app.Run(opts)
+}
+
+func runAll(ctx context.Context, cfg *config.Config, log *zap.Logger) error {
+	return runApp(ctx, cfg, log, application.Options{Serve: true, Consume: true, Preload: true, PreloadLimit: cfg.Limit})
+}
+
+func runServe(ctx context.Context, cfg *config.Config, log *zap.Logger) error {
+	return runApp(ctx, cfg, log, application.Options{Serve: true})
+}
+
+func runConsume(ctx context.Context, cfg *config.Config, log *zap.Logger) error {
+	return runApp(ctx, cfg, log, application.Options{Consume: true})
+}
+
+func runPreload(ctx context.Context, cfg *config.Config, log *zap.Logger) error {
+	b, err := newBackend(ctx, cfg, nil, log)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	return b.orders.PreloadRecentOrder(ctx, cfg.Limit)
+}
+
+func runPartitions(ctx context.Context, cfg *config.Config, log *zap.Logger, args []string) error {
+	if len(args) == 0 || args[0] != "maintain" {
+		return fmt.Errorf("partitions: expected maintain")
+	}
+	b, err := newBackend(ctx, cfg, nil, log)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	partitions, err := b.storage.NewPartitionManager(cfg.Partitioning)
+	if err != nil {
+		return err
+	}
+	return partitions.Maintain(ctx, time.Now())
+}
+
+func runPII(ctx context.Context, cfg *config.Config, log *zap.Logger, args []string) error {
+	if len(args) != 2 || args[0] != "erase" {
+		return fmt.Errorf("pii: expected erase CUSTOMER_ID")
+	}
+	b, err := newBackend(ctx, cfg, nil, log)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	result, err := b.orders.ErasePII(ctx, args[1], "cli")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("customer %s: erased delivery data in %d orders\n", result.CustomerID, result.OrdersAffected)
+	return nil
+}
+
+func runEncryption(ctx context.Context, cfg *config.Config, log *zap.Logger, args []string) error {
+	if len(args) == 0 || args[0] != "rotate" {
+		return fmt.Errorf("encryption: expected rotate")
+	}
+	batch := 500
+	if len(args) > 1 {
+		var err error
+		if batch, err = strconv.Atoi(args[1]); err != nil || batch <= 0 {
+			return fmt.Errorf("encryption rotate: invalid batch size %q", args[1])
+		}
+	}
+	cipher, err := fieldcrypt.New(cfg.Encryption)
+	if err != nil {
+		return fmt.Errorf("failed to initialize encryption: %w", err)
+	}
+	if cipher == nil {
+		return fmt.Errorf("encryption rotate: encryption is disabled in config")
+	}
+	storage, err := repository.NewStorage(ctx, cfg.Storage, log)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	repo := storage.NewRepository(cipher)
+	defer repo.Close()
+
+	rotated, err := repo.RotateDeliveryEncryption(ctx, batch)
+	fmt.Printf("re-encrypted %d deliveries with key %s\n", rotated, cipher.PrimaryKeyID())
+	return err
+}
+
+func runAuth(args []string) error {
+	if len(args) != 2 || args[0] != "hash-key" {
+		return fmt.Errorf("auth: expected hash-key KEY")
+	}
+	fmt.Println(auth.HashAPIKey(args[1]))
+	return nil
+}
+
+func runMigrate(cfg *config.Config, log *zap.Logger, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("migrate: expected up, down, to, force or version")
+	}
+	connStr := repository.ConnString(cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.DBName, cfg.SSLMode)
+	m, err := repository.NewMigrator(connStr, log)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch args[0] {
+	case "up":
+		return m.Up()
+	case "down":
+		// Полный откат удаляет все таблицы, поэтому он доступен только явным "all".
+		steps := 1
+		if len(args) > 1 && args[1] == "all" {
+			return m.DownAll()
+		}
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps <= 0 {
+				return fmt.Errorf("migrate down: invalid number of steps %q", args[1])
+			}
+		}
+		return m.Down(steps)
+	case "to":
+		if len(args) < 2 {
+			return fmt.Errorf("migrate to: version is required")
+		}
+		version, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			return fmt.Errorf("migrate to: invalid version %q", args[1])
+		}
+		return m.To(uint(version))
+	case "force":
+		if len(args) < 2 {
+			return fmt.Errorf("migrate force: version is required")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("migrate force: invalid version %q", args[1])
+		}
+		return m.Force(version)
+	case "version", "status":
+		version, dirty, err := m.Version()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("version: %d, dirty: %t, expected: %d\n", version, dirty, m.ExpectedVersion())
+		return nil
+	default:
+		return fmt.Errorf("migrate: unknown subcommand %q", args[0])
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"L0/internal/models"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+type orderKind int
+
+const (
+	kindValid orderKind = iota
+	kindInvalid
+	kindDuplicate
+)
+
+const maxRemembered = 1000
+
+var (
+	firstNames       = []string{"Ivan", "Anna", "Test", "Maria", "Oleg", "Elena", "David", "Sara"}
+	lastNames        = []string{"Ivanov", "Petrova", "Testov", "Smirnova", "Cohen", "Levi", "Kuznetsov"}
+	cities           = []string{"Moscow", "Kazan", "Kiryat Mozkin", "Haifa", "Tel Aviv", "Novosibirsk"}
+	regions          = []string{"Central", "Volga", "Kraiot", "Siberia", "North"}
+	streets          = []string{"Ploshad Mira", "Lenina", "Herzl", "Sadovaya", "Tverskaya"}
+	brands           = []string{"Vivienne Sabo", "Nivea", "Adidas", "Xiaomi", "Lego", "Samsung"}
+	products         = []string{"Mascaras", "Sneakers", "Cream", "Headphones", "T-shirt", "Backpack"}
+	currencies       = []string{"USD", "EUR", "RUB"}
+	providers        = []string{"wbpay", "sbp", "card"}
+	banks            = []string{"alpha", "sber", "tinkoff", "vtb"}
+	deliveryServices = []string{"meest", "cdek", "dhl", "wb"}
+	locales          = []string{"en", "ru"}
+)
+
+type generator struct {
+	rnd            *rand.Rand
+	itemsMean      float64
+	itemsMax       int
+	invalidRatio   float64
+	duplicateRatio float64
+	recent         []*models.Order
+}
+
+func newGenerator(seed int64, itemsMean float64, itemsMax int, invalidRatio, duplicateRatio float64) *generator {
+	return &generator{
+		rnd:            rand.New(rand.NewSource(seed)),
+		itemsMean:      itemsMean,
+		itemsMax:       itemsMax,
+		invalidRatio:   invalidRatio,
+		duplicateRatio: duplicateRatio,
+	}
+}
+
+// next возвращает очередной заказ: валидный, намеренно невалидный или повтор ранее отправленного.
+func (g *generator) next() (*models.Order, orderKind) {
+	p := g.rnd.Float64()
+	if p < g.duplicateRatio && len(g.recent) > 0 {
+		return g.recent[g.rnd.Intn(len(g.recent))], kindDuplicate
+	}
+	order := g.order()
+	if p < g.duplicateRatio+g.invalidRatio {
+		g.corrupt(order)
+		return order, kindInvalid
+	}
+	if len(g.recent) < maxRemembered {
+		g.recent = append(g.recent, order)
+	} else {
+		g.recent[g.rnd.Intn(maxRemembered)] = order
+	}
+	return order, kindValid
+}
+
+func (g *generator) order() *models.Order {
+	uid := g.hex(16) + "test"
+	track := "WBIL" + g.upper(10)
+	items := g.items(track)
+	goodsTotal := 0
+	for _, item := range items {
+		goodsTotal += item.TotalPrice
+	}
+	deliveryCost := 100 * g.rnd.Intn(20)
+	name := g.pick(firstNames) + " " + g.pick(lastNames)
+
+	return &models.Order{
+		OrderUID:    uid,
+		TrackNumber: track,
+		Entry:       "WBIL",
+		Delivery: models.Delivery{
+			Name:    name,
+			Phone:   fmt.Sprintf("+972%07d", g.rnd.Intn(10000000)),
+			Zip:     fmt.Sprintf("%07d", g.rnd.Intn(10000000)),
+			City:    g.pick(cities),
+			Address: fmt.Sprintf("%s %d", g.pick(streets), 1+g.rnd.Intn(200)),
+			Region:  g.pick(regions),
+			Email:   strings.ToLower(strings.ReplaceAll(name, " ", ".")) + "@example.com",
+		},
+		Payment: models.Payment{
+			Transaction:  uid,
+			Currency:     g.pick(currencies),
+			Provider:     g.pick(providers),
+			Amount:       goodsTotal + deliveryCost,
+			PaymentDt:    time.Now().Unix(),
+			Bank:         g.pick(banks),
+			DeliveryCost: deliveryCost,
+			GoodsTotal:   goodsTotal,
+		},
+		Items:           items,
+		Locale:          g.pick(locales),
+		CustomerID:      "customer" + g.hex(4),
+		DeliveryService: g.pick(deliveryServices),
+		Shardkey:        fmt.Sprint(g.rnd.Intn(10)),
+		SmID:            g.rnd.Intn(100),
+		DateCreated:     time.Now().UTC().Add(-time.Duration(g.rnd.Intn(3600)) * time.Second).Truncate(time.Second),
+		OofShard:        fmt.Sprint(g.rnd.Intn(3)),
+	}
+}
+
+// items генерирует от 1 до itemsMax товаров, количество распределено экспоненциально со средним itemsMean.
+func (g *generator) items(track string) []models.Item {
+	count := 1 + int(g.rnd.ExpFloat64()*(g.itemsMean-1))
+	if g.itemsMean <= 1 {
+		count = 1
+	}
+	if count > g.itemsMax {
+		count = g.itemsMax
+	}
+	items := make([]models.Item, 0, count)
+	for i := 0; i < count; i++ {
+		price := 100 + g.rnd.Intn(5000)
+		sale := g.rnd.Intn(50)
+		items = append(items, models.Item{
+			ChrtID:      1 + g.rnd.Intn(9999999),
+			TrackNumber: track,
+			Price:       price,
+			Rid:         g.hex(10) + "test",
+			Name:        g.pick(products),
+			Sale:        sale,
+			Size:        fmt.Sprint(g.rnd.Intn(5)),
+			TotalPrice:  price * (100 - sale) / 100,
+			NmID:        1 + g.rnd.Intn(9999999),
+			Brand:       g.pick(brands),
+			Status:      202,
+		})
+	}
+	return items
+}
+
+// corrupt нарушает одно из правил validator.ValidateOrder.
+func (g *generator) corrupt(order *models.Order) {
+	switch g.rnd.Intn(5) {
+	case 0:
+		order.Delivery.Phone = "not-a-phone"
+	case 1:
+		order.Payment.Amount = 0
+	case 2:
+		order.Items = nil
+	case 3:
+		order.DateCreated = time.Now().Add(24 * time.Hour)
+	default:
+		order.TrackNumber = ""
+	}
+}
+
+func (g *generator) pick(values []string) string {
+	return values[g.rnd.Intn(len(values))]
+}
+
+func (g *generator) hex(n int) string {
+	const alphabet = "0123456789abcdef"
+	return g.fromAlphabet(alphabet, n)
+}
+
+func (g *generator) upper(n int) string {
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	return g.fromAlphabet(alphabet, n)
+}
+
+func (g *generator) fromAlphabet(alphabet string, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = alphabet[g.rnd.Intn(len(alphabet))]
+	}
+	return string(b)
+}
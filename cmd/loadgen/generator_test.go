@@ -0,0 +1,46 @@
+package main
+
+import (
+	"L0/pkg/validator"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerator_ValidOrdersPassValidation(t *testing.T) {
+	gen := newGenerator(1, 3, 10, 0, 0)
+
+	for i := 0; i < 100; i++ {
+		order, kind := gen.next()
+		assert.Equal(t, kindValid, kind)
+		assert.NoError(t, validator.ValidateOrder(order))
+		assert.LessOrEqual(t, len(order.Items), 10)
+	}
+}
+
+func TestGenerator_InvalidOrdersFailValidation(t *testing.T) {
+	gen := newGenerator(1, 3, 10, 1, 0)
+
+	for i := 0; i < 100; i++ {
+		order, kind := gen.next()
+		assert.Equal(t, kindInvalid, kind)
+		assert.Error(t, validator.ValidateOrder(order))
+	}
+}
+
+func TestGenerator_DuplicatesReuseSentOrders(t *testing.T) {
+	gen := newGenerator(1, 1, 1, 0, 0.5)
+	sent := map[string]bool{}
+
+	duplicates := 0
+	for i := 0; i < 200; i++ {
+		order, kind := gen.next()
+		if kind == kindDuplicate {
+			duplicates++
+			assert.True(t, sent[order.OrderUID])
+			continue
+		}
+		sent[order.OrderUID] = true
+	}
+	assert.Greater(t, duplicates, 0)
+}
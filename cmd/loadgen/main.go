@@ -0,0 +1,169 @@
+package main
+
+import (
+	"L0/internal/config"
+	"L0/internal/messagebroker"
+	"L0/internal/models"
+	"L0/pkg/logger"
+	"context"
+	"flag"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// loadgen отправляет в Kafka синтетические заказы и измеряет задержку до их появления в HTTP API.
+// Брокеры, топик и настройки продьюсера берутся из того же конфига, что и у сервиса (CONFIG_PATH).
+func main() {
+	rate := flag.Float64("rate", 10, "orders per second")
+	duration := flag.Duration("duration", 30*time.Second, "how long to generate load")
+	count := flag.Int("count", 0, "stop after sending this many orders, 0 means unlimited")
+	itemsMean := flag.Float64("items-mean", 2, "mean number of items per order")
+	itemsMax := flag.Int("items-max", 20, "max number of items per order")
+	invalidRatio := flag.Float64("invalid-ratio", 0.05, "share of orders that fail validation")
+	duplicateRatio := flag.Float64("duplicate-ratio", 0.05, "share of messages that resend an already sent order")
+	api := flag.String("api", "http://localhost:8080", "base URL of the order API, empty disables latency polling")
+	apiKey := flag.String("api-key", "", "API key with orders:read scope, sent as X-API-Key")
+	pollInterval := flag.Duration("poll-interval", 100*time.Millisecond, "interval between GET /api/v1/orders/:orderUID polls")
+	pollTimeout := flag.Duration("poll-timeout", 30*time.Second, "give up waiting for an order after this timeout")
+	pollers := flag.Int("pollers", 50, "max concurrent pollers")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "random seed")
+	flag.Parse()
+
+	cfg := config.MustLoad()
+	log, err := logger.NewLogger(cfg.LogLevel)
+	if err != nil {
+		panic(fmt.Errorf("failed to initialize logger: %w", err))
+	}
+	defer log.Sync()
+
+	if *rate <= 0 {
+		log.Fatal("rate must be positive", zap.Float64("rate", *rate))
+	}
+
+	producer, err := messagebroker.NewProducer(cfg.Brokers, cfg.Topic, messagebroker.ProducerConfig{
+		BatchSize:     cfg.Producer.BatchSize,
+		BatchBytes:    cfg.Producer.BatchBytes,
+		BatchTimeout:  cfg.Producer.BatchTimeout,
+		WriteTimeout:  cfg.Producer.WriteTimeout,
+		MaxAttempts:   cfg.Producer.MaxAttempts,
+		Compression:   cfg.Producer.Compression,
+		RequiredAcks:  cfg.Producer.RequiredAcks,
+		Balancer:      cfg.Producer.Balancer,
+		SchemaVersion: cfg.Producer.SchemaVersion,
+	}, log)
+	if err != nil {
+		log.Fatal("failed to initialize producer", zap.Error(err))
+	}
+	defer producer.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	ctx, cancelTimeout := context.WithTimeout(ctx, *duration)
+	defer cancelTimeout()
+
+	gen := newGenerator(*seed, *itemsMean, *itemsMax, *invalidRatio, *duplicateRatio)
+	st := newStats()
+	poller := &poller{
+		client:   &http.Client{Timeout: 5 * time.Second},
+		baseURL:  strings.TrimRight(*api, "/"),
+		apiKey:   *apiKey,
+		interval: *pollInterval,
+		timeout:  *pollTimeout,
+		sem:      make(chan struct{}, *pollers),
+		stats:    st,
+	}
+
+	log.Info("Starting load generation",
+		zap.Float64("rate", *rate),
+		zap.Duration("duration", *duration),
+		zap.Int64("seed", *seed))
+
+	var wg sync.WaitGroup
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / *rate))
+	defer ticker.Stop()
+
+loop:
+	for *count == 0 || st.sentCount() < *count {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-ticker.C:
+		}
+
+		order, kind := gen.next()
+		sentAt := time.Now()
+		if err := producer.SendMessage(ctx, order.OrderUID, order); err != nil {
+			if ctx.Err() != nil {
+				break loop
+			}
+			log.Error("Failed to send order", zap.String("order_uid", order.OrderUID), zap.Error(err))
+			st.recordSendError()
+			continue
+		}
+		st.recordSent(kind)
+
+		if kind == kindValid && poller.baseURL != "" {
+			wg.Add(1)
+			go func(order *models.Order) {
+				defer wg.Done()
+				poller.await(order.OrderUID, sentAt)
+			}(order)
+		}
+	}
+
+	log.Info("Load generation finished, waiting for pending orders")
+	wg.Wait()
+
+	r := st.report()
+	fmt.Printf("elapsed:     %s\n", r.Elapsed.Round(time.Millisecond))
+	fmt.Printf("sent:        %d (valid %d, invalid %d, duplicate %d), send errors %d\n",
+		r.Sent, r.Valid, r.Invalid, r.Duplicate, r.SendErrors)
+	fmt.Printf("throughput:  %.1f msg/s\n", r.Throughput)
+	fmt.Printf("visible:     %d, timed out %d\n", r.Seen, r.TimedOut)
+	fmt.Printf("latency:     p50 %s, p95 %s, p99 %s, max %s\n", r.P50, r.P95, r.P99, r.Max)
+}
+
+type poller struct {
+	client   *http.Client
+	baseURL  string
+	apiKey   string
+	interval time.Duration
+	timeout  time.Duration
+	sem      chan struct{}
+	stats    *stats
+}
+
+// await опрашивает GET /api/v1/orders/:orderUID, пока заказ не станет доступен или не истечёт timeout.
+func (p *poller) await(orderUID string, sentAt time.Time) {
+	p.sem <- struct{}{}
+	defer func() { <-p.sem }()
+
+	deadline := sentAt.Add(p.timeout)
+	url := fmt.Sprintf("%s/api/v1/orders/%s", p.baseURL, orderUID)
+	for time.Now().Before(deadline) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			break
+		}
+		if p.apiKey != "" {
+			req.Header.Set("X-API-Key", p.apiKey)
+		}
+		resp, err := p.client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				p.stats.recordSeen(time.Since(sentAt))
+				return
+			}
+		}
+		time.Sleep(p.interval)
+	}
+	p.stats.recordTimeout()
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type stats struct {
+	mu        sync.Mutex
+	started   time.Time
+	sent      int
+	valid     int
+	invalid   int
+	duplicate int
+	sendErrs  int
+	seen      int
+	timedOut  int
+	latencies []time.Duration
+}
+
+func newStats() *stats {
+	return &stats{started: time.Now()}
+}
+
+func (s *stats) recordSent(kind orderKind) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent++
+	switch kind {
+	case kindValid:
+		s.valid++
+	case kindInvalid:
+		s.invalid++
+	case kindDuplicate:
+		s.duplicate++
+	}
+}
+
+func (s *stats) sentCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sent
+}
+
+func (s *stats) recordSendError() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sendErrs++
+}
+
+func (s *stats) recordSeen(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seen++
+	s.latencies = append(s.latencies, latency)
+}
+
+func (s *stats) recordTimeout() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timedOut++
+}
+
+type report struct {
+	Elapsed    time.Duration
+	Sent       int
+	Valid      int
+	Invalid    int
+	Duplicate  int
+	SendErrors int
+	Seen       int
+	TimedOut   int
+	Throughput float64
+	P50        time.Duration
+	P95        time.Duration
+	P99        time.Duration
+	Max        time.Duration
+}
+
+func (s *stats) report() report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elapsed := time.Since(s.started)
+	r := report{
+		Elapsed:    elapsed,
+		Sent:       s.sent,
+		Valid:      s.valid,
+		Invalid:    s.invalid,
+		Duplicate:  s.duplicate,
+		SendErrors: s.sendErrs,
+		Seen:       s.seen,
+		TimedOut:   s.timedOut,
+	}
+	if elapsed > 0 {
+		r.Throughput = float64(s.sent) / elapsed.Seconds()
+	}
+
+	latencies := append([]time.Duration(nil), s.latencies...)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	r.P50 = percentile(latencies, 0.50)
+	r.P95 = percentile(latencies, 0.95)
+	r.P99 = percentile(latencies, 0.99)
+	if len(latencies) > 0 {
+		r.Max = latencies[len(latencies)-1]
+	}
+	return r
+}
+
+// percentile ожидает отсортированный срез.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(float64(len(sorted)-1) * p)
+	return sorted[idx]
+}
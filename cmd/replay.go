@@ -0,0 +1,80 @@
+package main
+
+import (
+	"L0/internal/config"
+	"L0/internal/messagebroker"
+	"context"
+	"flag"
+	"fmt"
+	"go.uber.org/zap"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runReplay повторно прогоняет диапазон топика через pipeline обработки заказов.
+// Пример: replay -from-time 2024-01-01T00:00:00Z -to-time 2024-01-02T00:00:00Z -partitions 0,1
+func runReplay(ctx context.Context, cfg *config.Config, log *zap.Logger, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	topic := fs.String("topic", cfg.Topic, "topic to replay")
+	fromOffset := fs.Int64("from-offset", 0, "first offset to replay (inclusive)")
+	toOffset := fs.Int64("to-offset", 0, "offset to stop at (exclusive), 0 means end of partition")
+	fromTime := fs.String("from-time", "", "replay messages produced at or after this RFC3339 time")
+	toTime := fs.String("to-time", "", "replay messages produced before this RFC3339 time")
+	partitions := fs.String("partitions", "", "comma separated partitions, empty means all")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	rng := messagebroker.ReplayRange{FromOffset: *fromOffset, ToOffset: *toOffset}
+	var err error
+	if rng.From, err = parseTime(*fromTime); err != nil {
+		return fmt.Errorf("invalid -from-time: %w", err)
+	}
+	if rng.To, err = parseTime(*toTime); err != nil {
+		return fmt.Errorf("invalid -to-time: %w", err)
+	}
+	if rng.Partitions, err = parsePartitions(*partitions); err != nil {
+		return fmt.Errorf("invalid -partitions: %w", err)
+	}
+
+	b, err := newBackend(ctx, cfg, nil, log)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	replayer := messagebroker.NewReplayer(cfg.Brokers, *topic, log)
+	stats, err := replayer.Replay(ctx, rng, b.orders.ReplayMessage)
+	for _, st := range stats {
+		log.Info("Replay finished for partition",
+			zap.Int("partition", st.Partition),
+			zap.Int64("start", st.Start),
+			zap.Int64("end", st.End),
+			zap.Int("read", st.Read),
+			zap.Int("failed", st.Failed))
+	}
+	return err
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func parsePartitions(value string) ([]int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var partitions []int
+	for _, part := range strings.Split(value, ",") {
+		p, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		partitions = append(partitions, p)
+	}
+	return partitions, nil
+}
@@ -1,12 +1,16 @@
 package application
 
 import (
+	"L0/internal/events"
 	"L0/internal/router"
 	"L0/internal/service"
+	"L0/pkg/logger"
 	"context"
 	"errors"
 	"fmt"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -23,51 +27,137 @@ type App struct {
 	wg           sync.WaitGroup
 	shutdownOnce sync.Once
 	shutdownCh   chan struct{}
+	opts         Options
+	workers      []worker
+	stopWorkers  context.CancelFunc
+	events       *events.Hub
+	grpcServer   *grpc.Server
+	grpcAddr     string
 }
 
+type worker struct {
+	name string
+	run  func(ctx context.Context)
+}
+
+// Options определяет, какие компоненты запускает App.Run.
+type Options struct {
+	Serve        bool
+	Consume      bool
+	Preload      bool
+	PreloadLimit int
+}
+
+// NewApp создаёт приложение. router может быть nil, если HTTP сервер не нужен.
 func NewApp(service *service.OrderService, router *router.Router, addr string, log *zap.Logger) *App {
-	return &App{
+	app := &App{
 		orderService: service,
 		router:       router,
-		httpServer: &http.Server{
+		log:          log.Named("application"),
+		shutdownCh:   make(chan struct{}),
+	}
+	if router != nil {
+		app.httpServer = &http.Server{
 			Addr:    addr,
 			Handler: router.GetHTTPHandler(),
-		},
-		log:        log.Named("application"),
-		shutdownCh: make(chan struct{}),
+		}
 	}
+	return app
 }
 
-func (a *App) Run(limit int) error {
+// AddWorker регистрирует фоновую задачу, которая запускается в Run и должна завершиться при отмене ctx.
+func (a *App) AddWorker(name string, run func(ctx context.Context)) {
+	a.workers = append(a.workers, worker{name: name, run: run})
+}
+
+// PublishTo включает публикацию сводок обработанных заказов в hub. При остановке App закрывает hub,
+// иначе открытые потоки не дали бы HTTP серверу завершиться.
+func (a *App) PublishTo(hub *events.Hub) {
+	a.events = hub
+}
+
+// ServeGRPC запускает server на addr вместе с HTTP сервером. При остановке App вызывает GracefulStop
+// после закрытия hub, чтобы потоковые вызовы успели завершиться.
+func (a *App) ServeGRPC(server *grpc.Server, addr string) {
+	a.grpcServer = server
+	a.grpcAddr = addr
+}
+
+func (a *App) Run(opts Options) error {
+	if !opts.Serve && !opts.Consume {
+		return errors.New("nothing to run: neither HTTP server nor consumer requested")
+	}
+	if opts.Serve && a.httpServer == nil {
+		return errors.New("HTTP server requested without router")
+	}
+	a.opts = opts
+
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	a.wg.Add(1)
-	go func() {
-		defer a.wg.Done()
-		if err := a.orderService.PreloadRecentOrder(ctx, limit); err != nil {
-			a.log.Error("Error preloading order", zap.Error(err))
-		}
-	}()
-
-	a.wg.Add(1)
-	go func() {
-		defer a.wg.Done()
-		a.startKafka(ctx)
-	}()
-
-	serverErr := make(chan error, 1)
-	a.wg.Add(1)
-	go func() {
-		defer a.wg.Done()
-		a.log.Info("Starting HTTP server", zap.String("address", a.httpServer.Addr))
-		if err := a.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			serverErr <- err
+	if opts.Preload {
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			if err := a.orderService.PreloadRecentOrder(ctx, opts.PreloadLimit); err != nil {
+				a.log.Error("Error preloading order", zap.Error(err))
+			}
+		}()
+	}
+
+	if opts.Consume {
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			a.startKafka(ctx)
+		}()
+	}
+
+	workerCtx, stopWorkers := context.WithCancel(ctx)
+	a.stopWorkers = stopWorkers
+	for _, w := range a.workers {
+		a.wg.Add(1)
+		go func(w worker) {
+			defer a.wg.Done()
+			a.log.Info("Starting worker", zap.String("worker", w.name))
+			w.run(workerCtx)
+			a.log.Info("Worker stopped", zap.String("worker", w.name))
+		}(w)
+	}
+
+	serverErr := make(chan error, 2)
+	if opts.Serve {
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			a.log.Info("Starting HTTP server", zap.String("address", a.httpServer.Addr))
+			if err := a.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErr <- err
+			}
+		}()
+	}
+	if opts.Serve && a.grpcServer != nil {
+		listener, err := net.Listen("tcp", a.grpcAddr)
+		if err != nil {
+			a.log.Error("Error listening for gRPC", zap.String("address", a.grpcAddr), zap.Error(err))
+			serverErr <- fmt.Errorf("failed to listen for gRPC on %s: %w", a.grpcAddr, err)
+		} else {
+			a.wg.Add(1)
+			go func() {
+				defer a.wg.Done()
+				a.log.Info("Starting gRPC server", zap.String("address", a.grpcAddr))
+				if err := a.grpcServer.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+					select {
+					case serverErr <- err:
+					default:
+					}
+				}
+			}()
 		}
-	}()
+	}
 
 	a.log.Info("Application started successfully")
 
@@ -77,7 +167,7 @@ func (a *App) Run(limit int) error {
 	case sig := <-sigCh:
 		a.log.Info("Received signal, shutting down", zap.String("signal", sig.String()))
 	case err := <-serverErr:
-		a.log.Error("HTTP server error", zap.Error(err))
+		a.log.Error("Server error", zap.Error(err))
 		runErr = err
 		cancel()
 	}
@@ -104,7 +194,7 @@ func (a *App) startKafka(ctx context.Context) {
 			a.log.Info("Shutdown signal received, stopping Kafka consumer")
 			return
 		default:
-			order, err := a.orderService.ReadMessage(ctx)
+			msgCtx, order, err := a.orderService.ReadMessage(ctx)
 
 			if err != nil {
 
@@ -120,18 +210,17 @@ func (a *App) startKafka(ctx context.Context) {
 				continue
 			}
 
-			if err := a.orderService.SaveOrder(ctx, order); err != nil {
-				a.log.Error("Error saving order to DB", zap.Error(err))
-				continue
-			}
-
-			if err := a.orderService.SetOrder(ctx, order); err != nil {
-				a.log.Error("Error caching order", zap.Error(err))
+			log := logger.FromContext(msgCtx, a.log)
+			if err := a.orderService.ProcessOrder(msgCtx, order); err != nil {
+				log.Error("Error processing order", zap.String("order_uid", order.OrderUID), zap.Error(err))
 				continue
 			}
 
-			a.log.Info("Successfully processed order",
+			log.Info("Successfully processed order",
 				zap.String("order_uid", order.OrderUID))
+			if a.events != nil {
+				a.events.Publish(order.Summary())
+			}
 		}
 	}
 }
@@ -143,29 +232,56 @@ func (a *App) shutdown(ctx context.Context) error {
 		a.log.Info("Initiating graceful shutdown...")
 
 		close(a.shutdownCh)
+		if a.stopWorkers != nil {
+			a.stopWorkers()
+		}
 
 		shutdownCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
 		defer cancel()
 
-		if err := a.httpServer.Shutdown(shutdownCtx); err != nil {
-			a.log.Error("HTTP server shutdown error", zap.Error(err))
-			shutdownErr = fmt.Errorf("HTTP shutdown error: %w", err)
-		} else {
-			a.log.Info("HTTP server stopped gracefully")
+		if a.events != nil {
+			a.events.Close()
 		}
 
-		if err := a.orderService.CloseConsumer(); err != nil {
-			a.log.Error("Failed to close Kafka connection", zap.Error(err))
-			if shutdownErr != nil {
-				shutdownErr = fmt.Errorf("%v, Kafka close error: %w", shutdownErr, err)
+		if a.opts.Serve {
+			if err := a.httpServer.Shutdown(shutdownCtx); err != nil {
+				a.log.Error("HTTP server shutdown error", zap.Error(err))
+				shutdownErr = fmt.Errorf("HTTP shutdown error: %w", err)
 			} else {
-				shutdownErr = fmt.Errorf("Kafka close error: %w", err)
+				a.log.Info("HTTP server stopped gracefully")
+			}
+		}
+
+		if a.opts.Serve && a.grpcServer != nil {
+			stopped := make(chan struct{})
+			go func() {
+				a.grpcServer.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+				a.log.Info("gRPC server stopped gracefully")
+			case <-shutdownCtx.Done():
+				a.grpcServer.Stop()
+				a.log.Warn("gRPC server graceful stop timed out, connections closed")
+			}
+		}
+
+		if a.opts.Consume {
+			if err := a.orderService.CloseConsumer(); err != nil {
+				a.log.Error("Failed to close Kafka connection", zap.Error(err))
+				if shutdownErr != nil {
+					shutdownErr = fmt.Errorf("%v, Kafka close error: %w", shutdownErr, err)
+				} else {
+					shutdownErr = fmt.Errorf("Kafka close error: %w", err)
+				}
+			} else {
+				a.log.Info("Kafka connection closed")
 			}
-		} else {
-			a.log.Info("Kafka connection closed")
 		}
 
 		a.orderService.CloseRepo()
+		a.orderService.CloseRedisClient()
 
 		waitDone := make(chan struct{})
 		go func() {
@@ -0,0 +1,288 @@
+package archive
+
+import (
+	"L0/internal/models"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	fileExt       = ".ndjson.gz"
+	checksumExt   = ".sha256"
+	deleteBatch   = 1000
+	dayDirLayout  = "2006/01/02"
+	fileTimestamp = "20060102T150405Z"
+)
+
+var ErrChecksumMismatch = errors.New("archive checksum mismatch")
+
+// Store отдаёт заказы в том виде, в каком они хранятся: данные доставки остаются зашифрованными,
+// поэтому архив не раскрывает их. RestoreOrder принимает заказ из архива в том же виде.
+type Store interface {
+	ArchivableDays(ctx context.Context, before time.Time) ([]time.Time, error)
+	StreamOrders(ctx context.Context, from time.Time, to time.Time, fn func(order *models.Order) error) error
+	DeleteOrders(ctx context.Context, keys []models.OrderKey) (int64, error)
+	RestoreOrder(ctx context.Context, order *models.Order) error
+}
+
+// Cache - кэш заказов, из которого убираются перенесённые в архив заказы.
+type Cache interface {
+	EvictOrders(ctx context.Context, orderUIDs ...string) error
+}
+
+// Archiver выгружает старые заказы в gzip NDJSON файлы вида <dir>/YYYY/MM/DD/orders-<ts>.ndjson.gz
+// (по дню date_created в UTC) и удаляет их из PostgreSQL и кэша только после проверки контрольной суммы.
+type Archiver struct {
+	store Store
+	cache Cache
+	dir   string
+	now   func() time.Time
+	log   *zap.Logger
+}
+
+type DayStats struct {
+	Day      time.Time
+	File     string
+	Orders   int
+	Deleted  int64
+	Checksum string
+}
+
+func NewArchiver(store Store, cache Cache, dir string, log *zap.Logger) *Archiver {
+	return &Archiver{store: store, cache: cache, dir: dir, now: time.Now, log: log.Named("archiver")}
+}
+
+// Archive переносит в файлы заказы старше before. При dryRun файлы пишутся и проверяются, но заказы не удаляются.
+func (a *Archiver) Archive(ctx context.Context, before time.Time, dryRun bool) ([]DayStats, error) {
+	days, err := a.store.ArchivableDays(ctx, before)
+	if err != nil {
+		return nil, err
+	}
+	a.log.Info("Archiving orders", zap.Time("before", before), zap.Int("days", len(days)), zap.Bool("dry_run", dryRun))
+
+	var stats []DayStats
+	for _, day := range days {
+		to := day.AddDate(0, 0, 1)
+		if to.After(before) {
+			to = before
+		}
+		st, err := a.archiveDay(ctx, day, to, dryRun)
+		if err != nil {
+			return stats, fmt.Errorf("failed to archive %s: %w", day.Format(time.DateOnly), err)
+		}
+		stats = append(stats, st)
+	}
+	return stats, nil
+}
+
+func (a *Archiver) archiveDay(ctx context.Context, day time.Time, to time.Time, dryRun bool) (DayStats, error) {
+	st := DayStats{Day: day}
+	dir := filepath.Join(a.dir, filepath.FromSlash(day.Format(dayDirLayout)))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return st, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	st.File = filepath.Join(dir, "orders-"+a.now().UTC().Format(fileTimestamp)+fileExt)
+
+	keys, checksum, err := a.writeFile(ctx, st.File, day, to)
+	if err != nil {
+		os.Remove(st.File)
+		return st, err
+	}
+	st.Orders = len(keys)
+	st.Checksum = checksum
+	if len(keys) == 0 {
+		os.Remove(st.File)
+		return st, nil
+	}
+
+	verified, err := verifyFile(st.File, checksum)
+	if err != nil {
+		return st, err
+	}
+	if !sameKeys(keys, verified) {
+		return st, fmt.Errorf("%w: %s does not contain all exported orders", ErrChecksumMismatch, st.File)
+	}
+	if err := os.WriteFile(st.File+checksumExt, []byte(checksum+"  "+filepath.Base(st.File)+"\n"), 0o644); err != nil {
+		return st, fmt.Errorf("failed to write checksum: %w", err)
+	}
+
+	if !dryRun {
+		for start := 0; start < len(keys); start += deleteBatch {
+			end := min(start+deleteBatch, len(keys))
+			deleted, err := a.store.DeleteOrders(ctx, keys[start:end])
+			if err != nil {
+				return st, err
+			}
+			st.Deleted += deleted
+			uids := make([]string, 0, end-start)
+			for _, key := range keys[start:end] {
+				uids = append(uids, key.OrderUID)
+			}
+			if err := a.cache.EvictOrders(ctx, uids...); err != nil {
+				return st, fmt.Errorf("failed to evict archived orders from cache: %w", err)
+			}
+		}
+	}
+	a.log.Info("Archived day",
+		zap.String("day", day.Format(time.DateOnly)),
+		zap.String("file", st.File),
+		zap.Int("orders", st.Orders),
+		zap.Int64("deleted", st.Deleted))
+	return st, nil
+}
+
+// writeFile пишет заказы в файл и возвращает их ключи и sha256 сжатого файла.
+func (a *Archiver) writeFile(ctx context.Context, path string, from time.Time, to time.Time) ([]models.OrderKey, string, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create archive file: %w", err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(f, hash))
+	enc := json.NewEncoder(gz)
+
+	var keys []models.OrderKey
+	err = a.store.StreamOrders(ctx, from, to, func(order *models.Order) error {
+		keys = append(keys, models.OrderKey{OrderUID: order.OrderUID, DateCreated: order.DateCreated})
+		return enc.Encode(order)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to finish gzip stream: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return nil, "", fmt.Errorf("failed to sync archive file: %w", err)
+	}
+	return keys, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Restore загружает обратно в PostgreSQL заказы из архивов за дни [from, to). Повторная загрузка безопасна,
+// так как RestoreOrder делает upsert.
+func (a *Archiver) Restore(ctx context.Context, from time.Time, to time.Time) (int, error) {
+	files, err := a.files(from, to)
+	if err != nil {
+		return 0, err
+	}
+	restored := 0
+	for _, file := range files {
+		checksum, err := readChecksum(file)
+		if err != nil {
+			return restored, err
+		}
+		err = readFile(file, checksum, func(order *models.Order) error {
+			if err := a.store.RestoreOrder(ctx, order); err != nil {
+				return err
+			}
+			restored++
+			return nil
+		})
+		if err != nil {
+			return restored, fmt.Errorf("failed to restore %s: %w", file, err)
+		}
+		a.log.Info("Restored archive file", zap.String("file", file))
+	}
+	return restored, nil
+}
+
+// files возвращает архивы за дни [from, to) в хронологическом порядке.
+func (a *Archiver) files(from time.Time, to time.Time) ([]string, error) {
+	var files []string
+	for day := from.UTC().Truncate(24 * time.Hour); day.Before(to); day = day.AddDate(0, 0, 1) {
+		matches, err := filepath.Glob(filepath.Join(a.dir, filepath.FromSlash(day.Format(dayDirLayout)), "*"+fileExt))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+func verifyFile(path string, checksum string) ([]models.OrderKey, error) {
+	var keys []models.OrderKey
+	err := readFile(path, checksum, func(order *models.Order) error {
+		keys = append(keys, models.OrderKey{OrderUID: order.OrderUID, DateCreated: order.DateCreated})
+		return nil
+	})
+	return keys, err
+}
+
+// readFile проверяет sha256 файла и передаёт в fn каждый заказ из него.
+func readFile(path string, checksum string, fn func(order *models.Order) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open archive file: %w", err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return fmt.Errorf("failed to read archive file: %w", err)
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != checksum {
+		return fmt.Errorf("%w: %s expected %s, got %s", ErrChecksumMismatch, path, checksum, actual)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to open gzip stream: %w", err)
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var order models.Order
+		if err := json.Unmarshal(scanner.Bytes(), &order); err != nil {
+			return fmt.Errorf("failed to decode order: %w", err)
+		}
+		if err := fn(&order); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func readChecksum(path string) (string, error) {
+	data, err := os.ReadFile(path + checksumExt)
+	if err != nil {
+		return "", fmt.Errorf("failed to read checksum of %s: %w", path, err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum file for %s", path)
+	}
+	return fields[0], nil
+}
+
+func sameKeys(a []models.OrderKey, b []models.OrderKey) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].OrderUID != b[i].OrderUID || !a[i].DateCreated.Equal(b[i].DateCreated) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,157 @@
+package archive
+
+import (
+	"L0/internal/models"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// memoryStore хранит заказы в памяти вместо PostgreSQL и запоминает, что убрано из кэша
+type memoryStore struct {
+	orders  map[string]*models.Order
+	deleted []models.OrderKey
+	evicted []string
+}
+
+func newMemoryStore(orders ...*models.Order) *memoryStore {
+	s := &memoryStore{orders: make(map[string]*models.Order)}
+	for _, o := range orders {
+		s.orders[o.OrderUID] = o
+	}
+	return s
+}
+
+func (s *memoryStore) sorted() []*models.Order {
+	var orders []*models.Order
+	for _, o := range s.orders {
+		orders = append(orders, o)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].DateCreated.Before(orders[j].DateCreated) })
+	return orders
+}
+
+func (s *memoryStore) ArchivableDays(ctx context.Context, before time.Time) ([]time.Time, error) {
+	var days []time.Time
+	for _, o := range s.sorted() {
+		day := o.DateCreated.UTC().Truncate(24 * time.Hour)
+		if o.DateCreated.Before(before) && (len(days) == 0 || !days[len(days)-1].Equal(day)) {
+			days = append(days, day)
+		}
+	}
+	return days, nil
+}
+
+func (s *memoryStore) StreamOrders(ctx context.Context, from time.Time, to time.Time, fn func(order *models.Order) error) error {
+	for _, o := range s.sorted() {
+		if !o.DateCreated.Before(from) && o.DateCreated.Before(to) {
+			if err := fn(o); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) DeleteOrders(ctx context.Context, keys []models.OrderKey) (int64, error) {
+	for _, key := range keys {
+		delete(s.orders, key.OrderUID)
+	}
+	s.deleted = append(s.deleted, keys...)
+	return int64(len(keys)), nil
+}
+
+func (s *memoryStore) RestoreOrder(ctx context.Context, order *models.Order) error {
+	s.orders[order.OrderUID] = order
+	return nil
+}
+
+func (s *memoryStore) EvictOrders(ctx context.Context, orderUIDs ...string) error {
+	s.evicted = append(s.evicted, orderUIDs...)
+	return nil
+}
+
+func testOrder(uid string, created time.Time) *models.Order {
+	return &models.Order{
+		OrderUID:    uid,
+		TrackNumber: "TRACK-" + uid,
+		DateCreated: created,
+		Items:       []models.Item{{ChrtID: 1, TrackNumber: "TRACK-" + uid, Price: 100}},
+	}
+}
+
+func TestArchiveAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	store := newMemoryStore(
+		testOrder("a", day.Add(time.Hour)),
+		testOrder("b", day.Add(5*time.Hour)),
+		testOrder("c", day.AddDate(0, 0, 1).Add(time.Hour)),
+		testOrder("fresh", day.AddDate(0, 1, 0)),
+	)
+	archiver := NewArchiver(store, store, dir, zap.NewNop())
+
+	stats, err := archiver.Archive(context.Background(), day.AddDate(0, 0, 2), false)
+
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+	assert.Equal(t, 2, stats[0].Orders)
+	assert.Equal(t, int64(2), stats[0].Deleted)
+	assert.Equal(t, filepath.Join(dir, "2024", "03", "10"), filepath.Dir(stats[0].File))
+	assert.FileExists(t, stats[0].File+checksumExt)
+	assert.Len(t, store.deleted, 3)
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, store.evicted)
+	assert.Contains(t, store.orders, "fresh")
+	assert.Len(t, store.orders, 1)
+
+	restored, err := archiver.Restore(context.Background(), day, day.AddDate(0, 0, 1))
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, restored)
+	require.Contains(t, store.orders, "a")
+	assert.Equal(t, "TRACK-a", store.orders["a"].TrackNumber)
+	assert.True(t, store.orders["a"].DateCreated.Equal(day.Add(time.Hour)))
+	assert.NotContains(t, store.orders, "c")
+}
+
+func TestArchive_DryRunKeepsOrders(t *testing.T) {
+	day := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	store := newMemoryStore(testOrder("a", day.Add(time.Hour)))
+	archiver := NewArchiver(store, store, t.TempDir(), zap.NewNop())
+
+	stats, err := archiver.Archive(context.Background(), day.AddDate(0, 0, 1), true)
+
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	assert.FileExists(t, stats[0].File)
+	assert.Empty(t, store.deleted)
+	assert.Empty(t, store.evicted)
+	assert.Contains(t, store.orders, "a")
+}
+
+func TestRestore_ChecksumMismatch(t *testing.T) {
+	day := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	store := newMemoryStore(testOrder("a", day.Add(time.Hour)))
+	archiver := NewArchiver(store, store, t.TempDir(), zap.NewNop())
+	stats, err := archiver.Archive(context.Background(), day.AddDate(0, 0, 1), false)
+	require.NoError(t, err)
+
+	f, err := os.OpenFile(stats[0].File, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("corrupted"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	restored, err := archiver.Restore(context.Background(), day, day.AddDate(0, 0, 1))
+
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	assert.Zero(t, restored)
+	assert.Empty(t, store.orders)
+}
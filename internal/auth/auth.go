@@ -0,0 +1,156 @@
+// Package auth проверяет API ключи и JWT и определяет scopes вызывающего.
+package auth
+
+import (
+	"L0/internal/config"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+const (
+	ScopeOrdersRead  = "orders:read"
+	ScopeOrdersWrite = "orders:write"
+	// ScopeAdmin даёт доступ ко всем маршрутам.
+	ScopeAdmin = "admin"
+
+	APIKeyHeader = "X-API-Key"
+)
+
+var (
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal - аутентифицированный вызывающий.
+type Principal struct {
+	Subject string
+	Scopes  []string
+	// Role используется для маскирования ответов, пустая роль означает роль по умолчанию.
+	Role string
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+type apiKey struct {
+	hash      []byte
+	principal Principal
+}
+
+type Authenticator struct {
+	apiKeys []apiKey
+	jwt     *jwtVerifier
+}
+
+func New(cfg config.Auth) (*Authenticator, error) {
+	a := &Authenticator{}
+	for _, key := range cfg.APIKeys {
+		hash, err := hex.DecodeString(strings.TrimPrefix(key.Hash, "sha256:"))
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("api key %q: hash must be hex encoded sha256", key.Name)
+		}
+		if err := validateScopes(key.Scopes); err != nil {
+			return nil, fmt.Errorf("api key %q: %w", key.Name, err)
+		}
+		a.apiKeys = append(a.apiKeys, apiKey{
+			hash:      hash,
+			principal: Principal{Subject: key.Name, Scopes: key.Scopes, Role: key.Role},
+		})
+	}
+	if cfg.JWT.HS256Secret != "" || cfg.JWT.JWKSFile != "" {
+		verifier, err := newJWTVerifier(cfg.JWT)
+		if err != nil {
+			return nil, err
+		}
+		a.jwt = verifier
+	}
+	return a, nil
+}
+
+// HashAPIKey возвращает значение для поля hash в конфиге.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticate проверяет заголовок X-API-Key или Authorization: Bearer <JWT>.
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	return a.AuthenticateCredentials(r.Header.Get(APIKeyHeader), r.Header.Get("Authorization"))
+}
+
+// AuthenticateCredentials проверяет значения X-API-Key и Authorization, полученные не из HTTP запроса
+// (например, из метаданных gRPC). API ключ проверяется первым.
+func (a *Authenticator) AuthenticateCredentials(key string, header string) (*Principal, error) {
+	if key != "" {
+		return a.authenticateAPIKey(key)
+	}
+	if header != "" {
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || a.jwt == nil {
+			return nil, ErrInvalidCredentials
+		}
+		return a.jwt.verify(strings.TrimSpace(token))
+	}
+	return nil, ErrNoCredentials
+}
+
+func (a *Authenticator) authenticateAPIKey(key string) (*Principal, error) {
+	sum := sha256.Sum256([]byte(key))
+	for _, k := range a.apiKeys {
+		if subtle.ConstantTimeCompare(sum[:], k.hash) == 1 {
+			principal := k.principal
+			return &principal, nil
+		}
+	}
+	return nil, ErrInvalidCredentials
+}
+
+func knownScope(scope string) bool {
+	switch scope {
+	case ScopeOrdersRead, ScopeOrdersWrite, ScopeAdmin:
+		return true
+	}
+	return false
+}
+
+// validateScopes проверяет scopes API ключа: опечатка в конфиге должна останавливать запуск.
+func validateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if !knownScope(scope) {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	return nil
+}
+
+// knownScopes оставляет только scopes этого сервиса: токены общего провайдера несут и чужие scopes.
+func knownScopes(scopes []string) []string {
+	known := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if knownScope(scope) {
+			known = append(known, scope)
+		}
+	}
+	return known
+}
+
+// claims - стандартные поля плюс scopes в виде строки через пробел (scope) или массива (scopes) и роль.
+type claims struct {
+	jwt.RegisteredClaims
+	Scope  string   `json:"scope"`
+	Scopes []string `json:"scopes"`
+	Role   string   `json:"role"`
+}
+
+func (c *claims) principal() *Principal {
+	scopes := append(strings.Fields(c.Scope), c.Scopes...)
+	return &Principal{Subject: c.Subject, Scopes: scopes, Role: c.Role}
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"L0/internal/config"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func request(header string, value string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/order/1", nil)
+	if header != "" {
+		r.Header.Set(header, value)
+	}
+	return r
+}
+
+func token(t *testing.T, method jwt.SigningMethod, key any, kid string, c jwt.MapClaims) string {
+	tok := jwt.NewWithClaims(method, c)
+	if kid != "" {
+		tok.Header["kid"] = kid
+	}
+	signed, err := tok.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestAuthenticate_APIKey(t *testing.T) {
+	a, err := New(config.Auth{APIKeys: []config.APIKey{
+		{Name: "support", Hash: HashAPIKey("secret"), Scopes: []string{ScopeOrdersRead}, Role: "support"},
+	}})
+	require.NoError(t, err)
+
+	p, err := a.Authenticate(request(APIKeyHeader, "secret"))
+	require.NoError(t, err)
+	assert.Equal(t, "support", p.Subject)
+	assert.Equal(t, "support", p.Role)
+	assert.True(t, p.HasScope(ScopeOrdersRead))
+	assert.False(t, p.HasScope(ScopeAdmin))
+
+	_, err = a.Authenticate(request(APIKeyHeader, "wrong"))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	_, err = a.Authenticate(request("", ""))
+	assert.ErrorIs(t, err, ErrNoCredentials)
+}
+
+func TestAuthenticate_HS256(t *testing.T) {
+	a, err := New(config.Auth{JWT: config.JWT{HS256Secret: "hmac-secret", Issuer: "l0"}})
+	require.NoError(t, err)
+
+	valid := token(t, jwt.SigningMethodHS256, []byte("hmac-secret"), "", jwt.MapClaims{
+		"sub": "user-1", "iss": "l0", "exp": time.Now().Add(time.Hour).Unix(), "scope": "orders:read orders:write",
+	})
+	p, err := a.Authenticate(request("Authorization", "Bearer "+valid))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", p.Subject)
+	assert.True(t, p.HasScope(ScopeOrdersWrite))
+
+	foreign := token(t, jwt.SigningMethodHS256, []byte("hmac-secret"), "", jwt.MapClaims{
+		"sub": "user-2", "iss": "l0", "exp": time.Now().Add(time.Hour).Unix(), "scope": "openid billing:read orders:read",
+	})
+	p, err = a.Authenticate(request("Authorization", "Bearer "+foreign))
+	require.NoError(t, err, "scopes of other services are ignored")
+	assert.Equal(t, []string{ScopeOrdersRead}, p.Scopes)
+
+	expired := token(t, jwt.SigningMethodHS256, []byte("hmac-secret"), "", jwt.MapClaims{
+		"sub": "user-1", "iss": "l0", "exp": time.Now().Add(-time.Hour).Unix(),
+	})
+	_, err = a.Authenticate(request("Authorization", "Bearer "+expired))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	wrongIssuer := token(t, jwt.SigningMethodHS256, []byte("hmac-secret"), "", jwt.MapClaims{
+		"iss": "other", "exp": time.Now().Add(time.Hour).Unix(),
+	})
+	_, err = a.Authenticate(request("Authorization", "Bearer "+wrongIssuer))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	forged := token(t, jwt.SigningMethodHS256, []byte("other-secret"), "", jwt.MapClaims{
+		"iss": "l0", "exp": time.Now().Add(time.Hour).Unix(),
+	})
+	_, err = a.Authenticate(request("Authorization", "Bearer "+forged))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestAuthenticate_RS256WithJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	set := map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "rsa-1",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	a, err := New(config.Auth{JWT: config.JWT{JWKSFile: path}})
+	require.NoError(t, err)
+
+	signed := token(t, jwt.SigningMethodRS256, key, "rsa-1", jwt.MapClaims{
+		"sub": "svc", "exp": time.Now().Add(time.Hour).Unix(), "scopes": []string{"admin"}, "role": "admin",
+	})
+	p, err := a.Authenticate(request("Authorization", "Bearer "+signed))
+	require.NoError(t, err)
+	assert.Equal(t, "admin", p.Role)
+	assert.True(t, p.HasScope(ScopeOrdersRead), "admin implies every scope")
+
+	unknownKid := token(t, jwt.SigningMethodRS256, key, "rsa-2", jwt.MapClaims{"exp": time.Now().Add(time.Hour).Unix()})
+	_, err = a.Authenticate(request("Authorization", "Bearer "+unknownKid))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	_, err := New(config.Auth{APIKeys: []config.APIKey{{Name: "bad", Hash: "not-hex"}}})
+	assert.Error(t, err)
+
+	_, err = New(config.Auth{APIKeys: []config.APIKey{{Name: "bad", Hash: HashAPIKey("k"), Scopes: []string{"orders:delete"}}}})
+	assert.Error(t, err)
+}
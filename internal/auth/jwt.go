@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"L0/internal/config"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"os"
+)
+
+// jwtVerifier проверяет HS256 токены общим секретом или oct ключами JWKS и RS256 токены RSA ключами
+// из локального JWKS файла. Ключ выбирается по kid, а при его отсутствии - единственный ключ нужного типа.
+type jwtVerifier struct {
+	parser     *jwt.Parser
+	hmacKeys   map[string][]byte
+	rsaKeys    map[string]*rsa.PublicKey
+	hmacSecret []byte
+}
+
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		K   string `json:"k"`
+	} `json:"keys"`
+}
+
+func newJWTVerifier(cfg config.JWT) (*jwtVerifier, error) {
+	v := &jwtVerifier{
+		hmacKeys: make(map[string][]byte),
+		rsaKeys:  make(map[string]*rsa.PublicKey),
+	}
+	if cfg.HS256Secret != "" {
+		v.hmacSecret = []byte(cfg.HS256Secret)
+	}
+	if cfg.JWKSFile != "" {
+		if err := v.loadJWKS(cfg.JWKSFile); err != nil {
+			return nil, err
+		}
+	}
+
+	opts := []jwt.ParserOption{jwt.WithValidMethods([]string{"HS256", "RS256"}), jwt.WithExpirationRequired()}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	v.parser = jwt.NewParser(opts...)
+	return v, nil
+}
+
+func (v *jwtVerifier) loadJWKS(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+	var set jwks
+	if err := json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("failed to decode JWKS file: %w", err)
+	}
+	for _, key := range set.Keys {
+		switch key.Kty {
+		case "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(key.N)
+			if err != nil {
+				return fmt.Errorf("JWKS key %q: invalid modulus: %w", key.Kid, err)
+			}
+			e, err := base64.RawURLEncoding.DecodeString(key.E)
+			if err != nil {
+				return fmt.Errorf("JWKS key %q: invalid exponent: %w", key.Kid, err)
+			}
+			v.rsaKeys[key.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "oct":
+			k, err := base64.RawURLEncoding.DecodeString(key.K)
+			if err != nil {
+				return fmt.Errorf("JWKS key %q: invalid secret: %w", key.Kid, err)
+			}
+			v.hmacKeys[key.Kid] = k
+		default:
+			return fmt.Errorf("JWKS key %q: unsupported key type %q", key.Kid, key.Kty)
+		}
+	}
+	return nil
+}
+
+func (v *jwtVerifier) verify(token string) (*Principal, error) {
+	var c claims
+	if _, err := v.parser.ParseWithClaims(token, &c, v.key); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+	principal := c.principal()
+	principal.Scopes = knownScopes(principal.Scopes)
+	return principal, nil
+}
+
+func (v *jwtVerifier) key(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	switch token.Method.Alg() {
+	case "HS256":
+		if key, ok := pick(v.hmacKeys, kid); ok {
+			return key, nil
+		}
+		if kid == "" && v.hmacSecret != nil {
+			return v.hmacSecret, nil
+		}
+	case "RS256":
+		if key, ok := pick(v.rsaKeys, kid); ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("no %s key for kid %q", token.Method.Alg(), kid)
+}
+
+// pick возвращает ключ по kid, а без kid - единственный ключ в наборе.
+func pick[K any](keys map[string]K, kid string) (K, bool) {
+	if kid != "" {
+		key, ok := keys[kid]
+		return key, ok
+	}
+	var zero K
+	if len(keys) != 1 {
+		return zero, false
+	}
+	for _, key := range keys {
+		return key, true
+	}
+	return zero, false
+}
@@ -5,15 +5,25 @@ import (
 	"gopkg.in/yaml.v3"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	Storage  `yaml:"storage"`
-	Rest     `yaml:"rest"`
-	Kafka    `yaml:"kafka"`
-	Redis    `yaml:"redis"`
-	LogLevel string `yaml:"log_level"`
+	Storage    `yaml:"storage"`
+	Rest       `yaml:"rest"`
+	Kafka      `yaml:"kafka"`
+	Redis      `yaml:"redis"`
+	Archive    `yaml:"archive"`
+	Encryption `yaml:"encryption"`
+	Masking    `yaml:"masking"`
+	Auth       `yaml:"auth"`
+	RateLimit  `yaml:"rate_limit"`
+	Stream     `yaml:"stream"`
+	Webhooks   `yaml:"webhooks"`
+	GRPC       `yaml:"grpc"`
+	GraphQL    `yaml:"graphql"`
+	LogLevel   string `yaml:"log_level"`
 }
 
 type Storage struct {
@@ -23,16 +33,214 @@ type Storage struct {
 	Port     string `yaml:"port"`
 	DBName   string `yaml:"dbname"`
 	SSLMode  string `yaml:"sslmode"`
+	// AutoMigrate применяет миграции при старте. При false схема только проверяется.
+	AutoMigrate bool `yaml:"auto_migrate"`
+
+	MaxConns          int32         `yaml:"max_conns"`
+	MinConns          int32         `yaml:"min_conns"`
+	MaxConnLifetime   time.Duration `yaml:"max_conn_lifetime"`
+	MaxConnIdleTime   time.Duration `yaml:"max_conn_idle_time"`
+	HealthCheckPeriod time.Duration `yaml:"health_check_period"`
+	ConnectTimeout    time.Duration `yaml:"connect_timeout"`
+	// StatementTimeout передаётся в postgres как statement_timeout для каждого соединения.
+	StatementTimeout time.Duration `yaml:"statement_timeout"`
+	// ReadTimeout и WriteTimeout - дедлайны операций Repository на чтение и запись.
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+
+	// Replicas - DSN реплик для чтения, настройки пула берутся те же, что у primary.
+	// Реплика с отставанием больше ReplicaMaxLag исключается из чтения до следующей проверки.
+	Replicas           []string      `yaml:"replicas"`
+	ReplicaMaxLag      time.Duration `yaml:"replica_max_lag"`
+	ReplicaCheckPeriod time.Duration `yaml:"replica_check_period"`
+
+	Partitioning Partitioning `yaml:"partitioning"`
+}
+
+// Partitioning управляет обслуживанием помесячных партиций таблиц заказов.
+type Partitioning struct {
+	Enabled       bool          `yaml:"enabled"`
+	Interval      time.Duration `yaml:"interval"`
+	PremakeMonths int           `yaml:"premake_months"`
+	// RetentionMonths - сколько полных месяцев хранить помимо текущего, 0 - хранить всё.
+	RetentionMonths int `yaml:"retention_months"`
+	// RetentionMode: detach оставляет старые партиции отдельными таблицами для архивации, drop удаляет их.
+	RetentionMode string `yaml:"retention_mode"`
+}
+
+// Archive - каталог архивов заказов и возраст, после которого заказы переносятся туда командой archive run.
+type Archive struct {
+	Dir           string `yaml:"dir"`
+	OlderThanDays int    `yaml:"older_than_days"`
+}
+
+// Encryption - шифрование имени, телефона, адреса и email доставки в PostgreSQL и Redis.
+type Encryption struct {
+	Enabled bool `yaml:"enabled"`
+	// PrimaryKeyID - ключ для новых значений, остальные ключи нужны только для чтения старых.
+	PrimaryKeyID string `yaml:"primary_key_id"`
+	// Keys - id ключа -> 32 байта в base64, только из переменной ENCRYPTION_KEYS ("k1=...,k2=...").
+	// KeysFile - YAML файл того же формата (секрет), дополняет Keys, путь можно задать ENCRYPTION_KEYS_FILE.
+	Keys     map[string]string `yaml:"-"`
+	KeysFile string            `yaml:"keys_file"`
+	// BlindIndexKey - ключ HMAC для поиска по телефону и email, только из переменной ENCRYPTION_BLIND_INDEX_KEY.
+	// Его смена требует пересчёта индексов.
+	BlindIndexKey string `yaml:"-"`
+}
+
+// Masking - какие поля доставки видит каждая роль в ответах API.
+type Masking struct {
+	// DefaultRole применяется к запросам без роли и к ролям без политики.
+	DefaultRole string `yaml:"default_role"`
+	// RoleHeader - заголовок с ролью, выставляемый доверенным шлюзом. Пустой - заголовок не читается.
+	RoleHeader string `yaml:"role_header"`
+	// Roles - роль -> поле доставки (name, phone, email, address, zip) -> full|partial|redact.
+	Roles map[string]map[string]string `yaml:"roles"`
+}
+
+// Auth - аутентификация HTTP API. При Enabled=false все маршруты открыты.
+type Auth struct {
+	Enabled bool     `yaml:"enabled"`
+	APIKeys []APIKey `yaml:"api_keys"`
+	JWT     JWT      `yaml:"jwt"`
+}
+
+// APIKey - статический ключ. В конфиге хранится только sha256 ключа (go run ./cmd auth hash-key KEY).
+type APIKey struct {
+	Name   string   `yaml:"name"`
+	Hash   string   `yaml:"hash"`
+	Scopes []string `yaml:"scopes"`
+	Role   string   `yaml:"role"`
+}
+
+// JWT - проверка Bearer токенов: HS256 с общим секретом или ключами из JWKS файла, RS256 по JWKS.
+type JWT struct {
+	HS256Secret string `yaml:"hs256_secret"`
+	JWKSFile    string `yaml:"jwks_file"`
+	Issuer      string `yaml:"issuer"`
+	Audience    string `yaml:"audience"`
+}
+
+// RateLimit - token bucket на клиента (API ключ, субъект JWT или IP) в Redis. Ключ Routes - метод и
+// шаблон маршрута gin, например "GET /api/v1/orders/:orderUID", или полное имя gRPC метода.
+// Маршрут без правила получает Default, правило с нулевым Rate отключает лимит.
+type RateLimit struct {
+	Enabled bool                     `yaml:"enabled"`
+	Default RateLimitRule            `yaml:"default"`
+	Routes  map[string]RateLimitRule `yaml:"routes"`
+	// PerIP - общий лимит на IP, проверяется до аутентификации, чтобы перебор ключей и токенов
+	// упирался в него. Нулевой Rate отключает.
+	PerIP RateLimitRule `yaml:"per_ip"`
+}
+
+// RateLimitRule - Rate запросов в секунду в среднем и до Burst подряд.
+type RateLimitRule struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// Stream - поток сводок новых заказов (SSE и WebSocket). Заказы попадают в поток только в процессе,
+// который сам читает Kafka (режим all).
+type Stream struct {
+	Enabled bool `yaml:"enabled"`
+	// Buffer - сколько сводок может ждать отправки клиенту, при переполнении клиент отключается.
+	Buffer         int           `yaml:"buffer"`
+	MaxSubscribers int           `yaml:"max_subscribers"`
+	Heartbeat      time.Duration `yaml:"heartbeat"`
+}
+
+// Webhooks - исходящие вебхуки о сохранённых заказах. Доставляет процесс, который читает Kafka,
+// управляющие маршруты /webhooks есть у процесса с HTTP API.
+type Webhooks struct {
+	Enabled   bool `yaml:"enabled"`
+	Workers   int  `yaml:"workers"`
+	QueueSize int  `yaml:"queue_size"`
+	// MaxAttempts - попыток на одно событие, задержка между ними растёт от InitialBackoff вдвое до MaxBackoff.
+	MaxAttempts    int           `yaml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	// Timeout - ожидание ответа получателя на одну попытку.
+	Timeout time.Duration `yaml:"timeout"`
+	// RefreshInterval - как часто перечитывать подписки из postgres.
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+	// AllowedNetworks - CIDR, куда можно слать вебхуки, хотя они внутренние. Остальные loopback,
+	// link-local и частные адреса запрещены и при создании подписки, и при доставке.
+	AllowedNetworks []string `yaml:"allowed_networks"`
+}
+
+// GRPC - gRPC API заказов на отдельном порту. Ключи auth и роли masking общие с REST API.
+type GRPC struct {
+	Enabled bool   `yaml:"enabled"`
+	Addr    string `yaml:"addr"`
+	// Reflection включает сервис reflection для grpcurl и похожих клиентов.
+	Reflection bool `yaml:"reflection"`
+}
+
+// GraphQL - эндпоинт /graphql. Playground открывается по GET на том же пути при log_level debug.
+type GraphQL struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxDepth - наибольшая вложенность полей запроса.
+	MaxDepth int `yaml:"max_depth"`
+	// MaxComplexity - наибольшая оценка стоимости запроса: поле стоит 1, поля внутри списка с first
+	// умножаются на first.
+	MaxComplexity int `yaml:"max_complexity"`
 }
 
 type Rest struct {
-	Addr string `yaml:"addr"`
+	Addr      string    `yaml:"addr"`
+	AccessLog AccessLog `yaml:"access_log"`
+	// CacheMaxAge - сколько клиент может использовать заказ без перепроверки, 0 - перепроверять по ETag всегда.
+	CacheMaxAge time.Duration `yaml:"cache_max_age"`
+	Compression Compression   `yaml:"compression"`
+	// TrustedProxies - CIDR прокси, которым можно верить в X-Forwarded-For и X-Real-IP. По IP клиента
+	// работают ограничения частоты, поэтому по умолчанию (пустой список) берётся адрес соединения.
+	TrustedProxies []string     `yaml:"trusted_proxies"`
+	LegacyRoutes   LegacyRoutes `yaml:"legacy_routes"`
+}
+
+// LegacyRoutes - сроки путей без версии (/order/:orderUID): с DeprecatedAt ответы получают заголовок
+// Deprecation, SunsetAt - дата отключения в заголовке Sunset. Нулевая дата - заголовок не выставляется.
+type LegacyRoutes struct {
+	DeprecatedAt time.Time `yaml:"deprecated_at"`
+	SunsetAt     time.Time `yaml:"sunset_at"`
+}
+
+// Compression - сжатие ответов API в br или gzip.
+type Compression struct {
+	Enabled bool `yaml:"enabled"`
+	// MinSize - ответы короче стольких байт не сжимаются.
+	MinSize int `yaml:"min_size"`
+}
+
+// AccessLog - журнал HTTP запросов. Ответы не 2xx и медленные запросы пишутся всегда.
+type AccessLog struct {
+	// SuccessSampleEvery - писать каждый N-й успешный (2xx) запрос, 0 и 1 - все.
+	SuccessSampleEvery int `yaml:"success_sample_every"`
+	// SlowThreshold - запросы дольше порога пишутся с уровнем warn, 0 - не выделять.
+	SlowThreshold time.Duration `yaml:"slow_threshold"`
+	// ExcludePaths - префиксы путей, которые не попадают в журнал.
+	ExcludePaths []string `yaml:"exclude_paths"`
 }
 
 type Kafka struct {
-	Brokers []string `yaml:"brokers"`
-	Topic   string   `yaml:"topic"`
+	Brokers  []string `yaml:"brokers"`
+	Topic    string   `yaml:"topic"`
+	Producer Producer `yaml:"producer"`
+}
+
+type Producer struct {
+	BatchSize     int           `yaml:"batch_size"`
+	BatchBytes    int64         `yaml:"batch_bytes"`
+	BatchTimeout  time.Duration `yaml:"batch_timeout"`
+	WriteTimeout  time.Duration `yaml:"write_timeout"`
+	MaxAttempts   int           `yaml:"max_attempts"`
+	Compression   string        `yaml:"compression"`
+	RequiredAcks  string        `yaml:"required_acks"`
+	Balancer      string        `yaml:"balancer"`
+	Async         bool          `yaml:"async"`
+	SchemaVersion string        `yaml:"schema_version"`
 }
+
 type Redis struct {
 	RedisAddr     string `yaml:"redis_addr"`
 	RedisPassword string `yaml:"redis_password"`
@@ -44,6 +252,26 @@ type Cache struct {
 	Limit int           `yaml:"limit"`
 }
 
+const redacted = "***"
+
+// Redacted возвращает копию конфига с замаскированными паролями и ключами для вывода в лог.
+func (c Config) Redacted() Config {
+	c.Storage.Password = redacted
+	c.Storage.Replicas = make([]string, len(c.Storage.Replicas))
+	for i := range c.Storage.Replicas {
+		c.Storage.Replicas[i] = redacted
+	}
+	c.Redis.RedisPassword = redacted
+	keys := make(map[string]string, len(c.Encryption.Keys))
+	for id := range c.Encryption.Keys {
+		keys[id] = redacted
+	}
+	c.Encryption.Keys = keys
+	c.Encryption.BlindIndexKey = redacted
+	c.Auth.JWT.HS256Secret = redacted
+	return c
+}
+
 func MustLoad() *Config {
 	configPath := os.Getenv("CONFIG_PATH")
 	if configPath == "" {
@@ -66,6 +294,32 @@ func MustLoad() *Config {
 	if err != nil {
 		panic(fmt.Errorf("failed to decode config: %w", err))
 	}
+	if keys := os.Getenv("ENCRYPTION_KEYS"); keys != "" {
+		config.Encryption.Keys, err = parseKeys(keys)
+		if err != nil {
+			panic(fmt.Errorf("failed to parse ENCRYPTION_KEYS: %w", err))
+		}
+	}
+	if keysFile := os.Getenv("ENCRYPTION_KEYS_FILE"); keysFile != "" {
+		config.Encryption.KeysFile = keysFile
+	}
+	if blindIndexKey := os.Getenv("ENCRYPTION_BLIND_INDEX_KEY"); blindIndexKey != "" {
+		config.Encryption.BlindIndexKey = blindIndexKey
+	}
 
 	return &config
 }
+
+// parseKeys разбирает список "id=ключ" через запятую. Ключ в base64 может заканчиваться на "=",
+// поэтому id отделяется по первому "=".
+func parseKeys(value string) (map[string]string, error) {
+	keys := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		id, key, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || id == "" || key == "" {
+			return nil, fmt.Errorf("expected id=key, got %q", pair)
+		}
+		keys[id] = key
+	}
+	return keys, nil
+}
@@ -0,0 +1,132 @@
+// Package events раздаёт сводки обработанных заказов подписчикам (SSE и WebSocket клиентам).
+package events
+
+import (
+	"L0/internal/models"
+	"errors"
+	"go.uber.org/zap"
+	"sync"
+)
+
+var (
+	ErrClosed             = errors.New("event hub closed")
+	ErrTooManySubscribers = errors.New("too many subscribers")
+)
+
+// Filter отбирает сводки по службе доставки и покупателю. Пустое поле не ограничивает выборку.
+type Filter struct {
+	DeliveryService string
+	CustomerID      string
+}
+
+func (f Filter) Match(s models.OrderSummary) bool {
+	return (f.DeliveryService == "" || f.DeliveryService == s.DeliveryService) &&
+		(f.CustomerID == "" || f.CustomerID == s.CustomerID)
+}
+
+// Hub рассылает каждую сводку всем подходящим подписчикам. Publish никогда не ждёт клиента:
+// подписчик, чей буфер переполнен, отключается, чтобы медленный клиент не тормозил консьюмер.
+type Hub struct {
+	mu             sync.Mutex
+	subs           map[*Subscription]struct{}
+	buffer         int
+	maxSubscribers int
+	closed         bool
+	log            *zap.Logger
+}
+
+// NewHub создаёт хаб. buffer - сколько сводок может ждать отправки одному клиенту,
+// maxSubscribers - предел одновременных подписчиков, 0 - без предела.
+func NewHub(buffer int, maxSubscribers int, log *zap.Logger) *Hub {
+	return &Hub{
+		subs:           make(map[*Subscription]struct{}),
+		buffer:         max(buffer, 1),
+		maxSubscribers: maxSubscribers,
+		log:            log.Named("events"),
+	}
+}
+
+type Subscription struct {
+	hub     *Hub
+	filter  Filter
+	ch      chan models.OrderSummary
+	dropped bool
+}
+
+// Events закрывается, когда подписка отменена, хаб закрыт или клиент отключён как медленный.
+func (s *Subscription) Events() <-chan models.OrderSummary {
+	return s.ch
+}
+
+// Dropped сообщает, что подписка закрыта из-за переполнения буфера. Читать после закрытия Events.
+func (s *Subscription) Dropped() bool {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.dropped
+}
+
+func (s *Subscription) Close() {
+	s.hub.remove(s, false)
+}
+
+func (h *Hub) Subscribe(filter Filter) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, ErrClosed
+	}
+	if h.maxSubscribers > 0 && len(h.subs) >= h.maxSubscribers {
+		return nil, ErrTooManySubscribers
+	}
+	sub := &Subscription{hub: h, filter: filter, ch: make(chan models.OrderSummary, h.buffer)}
+	h.subs[sub] = struct{}{}
+	return sub, nil
+}
+
+func (h *Hub) Publish(summary models.OrderSummary) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		if !sub.filter.Match(summary) {
+			continue
+		}
+		select {
+		case sub.ch <- summary:
+		default:
+			h.log.Warn("Dropping slow subscriber", zap.Int("buffer", h.buffer))
+			h.removeLocked(sub, true)
+		}
+	}
+}
+
+// Close отключает всех подписчиков, новые подписки больше не принимаются.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.subs {
+		h.removeLocked(sub, false)
+	}
+}
+
+// Subscribers - число активных подписчиков.
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+func (h *Hub) remove(sub *Subscription, dropped bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeLocked(sub, dropped)
+}
+
+func (h *Hub) removeLocked(sub *Subscription, dropped bool) {
+	if _, ok := h.subs[sub]; !ok {
+		return
+	}
+	delete(h.subs, sub)
+	sub.dropped = dropped
+	close(sub.ch)
+}
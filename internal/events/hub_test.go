@@ -0,0 +1,89 @@
+package events
+
+import (
+	"L0/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestHub_FanOutWithFilters(t *testing.T) {
+	hub := NewHub(4, 0, zap.NewNop())
+	all, err := hub.Subscribe(Filter{})
+	require.NoError(t, err)
+	meest, err := hub.Subscribe(Filter{DeliveryService: "meest"})
+	require.NoError(t, err)
+	customer, err := hub.Subscribe(Filter{DeliveryService: "meest", CustomerID: "c1"})
+	require.NoError(t, err)
+
+	hub.Publish(models.OrderSummary{OrderUID: "1", DeliveryService: "meest", CustomerID: "c2"})
+	hub.Publish(models.OrderSummary{OrderUID: "2", DeliveryService: "dhl", CustomerID: "c1"})
+	hub.Publish(models.OrderSummary{OrderUID: "3", DeliveryService: "meest", CustomerID: "c1"})
+
+	assert.Equal(t, []string{"1", "2", "3"}, drain(all))
+	assert.Equal(t, []string{"1", "3"}, drain(meest))
+	assert.Equal(t, []string{"3"}, drain(customer))
+}
+
+func TestHub_DropsSlowSubscriber(t *testing.T) {
+	hub := NewHub(2, 0, zap.NewNop())
+	slow, err := hub.Subscribe(Filter{})
+	require.NoError(t, err)
+	fast, err := hub.Subscribe(Filter{})
+	require.NoError(t, err)
+
+	for _, uid := range []string{"1", "2", "3"} {
+		hub.Publish(models.OrderSummary{OrderUID: uid})
+		if uid != "3" {
+			<-fast.Events()
+		}
+	}
+
+	assert.Equal(t, []string{"1", "2"}, drainClosed(slow))
+	assert.True(t, slow.Dropped())
+	assert.Equal(t, 1, hub.Subscribers())
+	assert.Equal(t, []string{"3"}, drain(fast))
+}
+
+func TestHub_LimitsAndClose(t *testing.T) {
+	hub := NewHub(1, 1, zap.NewNop())
+	sub, err := hub.Subscribe(Filter{})
+	require.NoError(t, err)
+	_, err = hub.Subscribe(Filter{})
+	assert.ErrorIs(t, err, ErrTooManySubscribers)
+
+	sub.Close()
+	sub.Close()
+	sub, err = hub.Subscribe(Filter{})
+	require.NoError(t, err)
+
+	hub.Close()
+	_, ok := <-sub.Events()
+	assert.False(t, ok)
+	assert.False(t, sub.Dropped())
+	_, err = hub.Subscribe(Filter{})
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+// drain забирает уже доставленные сводки, не дожидаясь новых.
+func drain(sub *Subscription) []string {
+	var uids []string
+	for {
+		select {
+		case s := <-sub.Events():
+			uids = append(uids, s.OrderUID)
+		default:
+			return uids
+		}
+	}
+}
+
+func drainClosed(sub *Subscription) []string {
+	var uids []string
+	for s := range sub.Events() {
+		uids = append(uids, s.OrderUID)
+	}
+	return uids
+}
@@ -0,0 +1,267 @@
+// Package fieldcrypt шифрует персональные данные доставки перед записью в PostgreSQL и Redis.
+package fieldcrypt
+
+import (
+	"L0/internal/config"
+	"L0/internal/models"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"strings"
+)
+
+const (
+	prefix  = "enc:v1:"
+	keySize = 32
+)
+
+var (
+	ErrUnknownKey = errors.New("unknown encryption key")
+	ErrMalformed  = errors.New("malformed encrypted value")
+	ErrNoKeys     = errors.New("encryption keys are not set")
+)
+
+// Cipher реализует envelope-шифрование: каждое значение шифруется своим случайным ключом данных (DEK),
+// а DEK - мастер-ключом с идентификатором. Формат: enc:v1:<key id>:<DEK в обёртке>:<шифртекст>.
+// По id старые значения расшифровываются после ротации, пока их ключ остаётся в конфиге.
+// nil *Cipher означает, что шифрование выключено: значения проходят без изменений.
+type Cipher struct {
+	keys     map[string]cipher.AEAD
+	primary  string
+	blindKey []byte
+}
+
+// New создаёт Cipher из конфига. При выключенном шифровании возвращает nil.
+func New(cfg config.Encryption) (*Cipher, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	rawKeys := make(map[string]string, len(cfg.Keys))
+	for id, key := range cfg.Keys {
+		rawKeys[id] = key
+	}
+	if cfg.KeysFile != "" {
+		data, err := os.ReadFile(cfg.KeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read keys file: %w", err)
+		}
+		var fileKeys map[string]string
+		if err := yaml.Unmarshal(data, &fileKeys); err != nil {
+			return nil, fmt.Errorf("failed to decode keys file: %w", err)
+		}
+		for id, key := range fileKeys {
+			rawKeys[id] = key
+		}
+	}
+
+	if len(rawKeys) == 0 {
+		return nil, fmt.Errorf("%w: set ENCRYPTION_KEYS or ENCRYPTION_KEYS_FILE", ErrNoKeys)
+	}
+	if cfg.BlindIndexKey == "" {
+		return nil, fmt.Errorf("%w: set ENCRYPTION_BLIND_INDEX_KEY", ErrNoKeys)
+	}
+
+	c := &Cipher{keys: make(map[string]cipher.AEAD, len(rawKeys)), primary: cfg.PrimaryKeyID}
+	for id, encoded := range rawKeys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid key id %q", id)
+		}
+		key, err := decodeKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		if c.keys[id], err = newAEAD(key); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := c.keys[c.primary]; !ok {
+		return nil, fmt.Errorf("%w: primary key %q is not configured", ErrUnknownKey, c.primary)
+	}
+	blindKey, err := decodeKey(cfg.BlindIndexKey)
+	if err != nil {
+		return nil, fmt.Errorf("blind index key: %w", err)
+	}
+	c.blindKey = blindKey
+	return c, nil
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64: %w", err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("expected %d bytes, got %d", keySize, len(key))
+	}
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// PrimaryKeyID возвращает id ключа, которым шифруются новые значения.
+func (c *Cipher) PrimaryKeyID() string {
+	if c == nil {
+		return ""
+	}
+	return c.primary
+}
+
+// Encrypt шифрует значение мастер-ключом primary. Пустая строка остаётся пустой.
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	if c == nil || plaintext == "" {
+		return plaintext, nil
+	}
+	dek := make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	wrapped, err := seal(c.keys[c.primary], dek, []byte(c.primary))
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	data, err := seal(aead, []byte(plaintext), []byte(c.primary))
+	if err != nil {
+		return "", err
+	}
+	return prefix + c.primary + ":" +
+		base64.RawStdEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawStdEncoding.EncodeToString(data), nil
+}
+
+// Decrypt расшифровывает значение. Незашифрованные значения (записанные до включения шифрования)
+// возвращаются как есть.
+func (c *Cipher) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if c == nil {
+		return "", fmt.Errorf("%w: encryption is disabled", ErrUnknownKey)
+	}
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", ErrMalformed
+	}
+	id := parts[0]
+	kek, ok := c.keys[id]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrMalformed
+	}
+	data, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrMalformed
+	}
+	dek, err := open(kek, wrapped, []byte(id))
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(aead, data, []byte(id))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// IsEncrypted сообщает, что значение записано в формате Cipher.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+func seal(aead cipher.AEAD, plaintext []byte, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+func open(aead cipher.AEAD, data []byte, additional []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], additional)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return plaintext, nil
+}
+
+// BlindIndex возвращает HMAC-SHA256 нормализованного значения, по которому ищутся зашифрованные
+// телефоны и email. Пустое значение и выключенное шифрование дают пустой индекс.
+func (c *Cipher) BlindIndex(value string) string {
+	value = normalize(value)
+	if c == nil || value == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, c.blindKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// normalize приводит телефон и email к виду, в котором их сравнивают при поиске.
+func normalize(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if strings.Contains(value, "@") {
+		return value
+	}
+	return strings.Map(func(r rune) rune {
+		if (r >= '0' && r <= '9') || r == '+' {
+			return r
+		}
+		return -1
+	}, value)
+}
+
+// EncryptDelivery возвращает копию доставки с зашифрованными имя, телефоном, адресом и email.
+func (c *Cipher) EncryptDelivery(d models.Delivery) (models.Delivery, error) {
+	if c == nil {
+		return d, nil
+	}
+	var err error
+	for _, field := range piiFields(&d) {
+		if *field, err = c.Encrypt(*field); err != nil {
+			return d, fmt.Errorf("failed to encrypt delivery: %w", err)
+		}
+	}
+	return d, nil
+}
+
+// DecryptDelivery расшифровывает поля доставки на месте.
+func (c *Cipher) DecryptDelivery(d *models.Delivery) error {
+	var err error
+	for _, field := range piiFields(d) {
+		if *field, err = c.Decrypt(*field); err != nil {
+			return fmt.Errorf("failed to decrypt delivery: %w", err)
+		}
+	}
+	return nil
+}
+
+func piiFields(d *models.Delivery) []*string {
+	return []*string{&d.Name, &d.Phone, &d.Address, &d.Email}
+}
@@ -0,0 +1,143 @@
+package fieldcrypt
+
+import (
+	"L0/internal/config"
+	"L0/internal/models"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), keySize)))
+}
+
+func newTestCipher(t *testing.T, primary string, keys map[string]string) *Cipher {
+	c, err := New(config.Encryption{Enabled: true, PrimaryKeyID: primary, Keys: keys, BlindIndexKey: testKey('b')})
+	require.NoError(t, err)
+	return c
+}
+
+func TestEncryptDecrypt_RoundTrip(t *testing.T) {
+	c := newTestCipher(t, "k1", map[string]string{"k1": testKey('1')})
+
+	encrypted, err := c.Encrypt("+9720000000")
+
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encrypted, "enc:v1:k1:"))
+	assert.NotContains(t, encrypted, "9720000000")
+	decrypted, err := c.Decrypt(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "+9720000000", decrypted)
+}
+
+func TestDecrypt_AfterRotation(t *testing.T) {
+	old := newTestCipher(t, "k1", map[string]string{"k1": testKey('1')})
+	encrypted, err := old.Encrypt("test@gmail.com")
+	require.NoError(t, err)
+
+	rotated := newTestCipher(t, "k2", map[string]string{"k1": testKey('1'), "k2": testKey('2')})
+	decrypted, err := rotated.Decrypt(encrypted)
+
+	require.NoError(t, err)
+	assert.Equal(t, "test@gmail.com", decrypted)
+	reencrypted, err := rotated.Encrypt(decrypted)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(reencrypted, "enc:v1:k2:"))
+}
+
+func TestDecrypt_Errors(t *testing.T) {
+	c := newTestCipher(t, "k1", map[string]string{"k1": testKey('1')})
+	other := newTestCipher(t, "k2", map[string]string{"k2": testKey('2')})
+	encrypted, err := other.Encrypt("secret")
+	require.NoError(t, err)
+
+	_, err = c.Decrypt(encrypted)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	_, err = c.Decrypt("enc:v1:k1:broken")
+	assert.ErrorIs(t, err, ErrMalformed)
+
+	tampered := encrypted[:len(encrypted)-2] + "AA"
+	_, err = other.Decrypt(tampered)
+	assert.Error(t, err)
+}
+
+func TestDecrypt_PlaintextPassesThrough(t *testing.T) {
+	c := newTestCipher(t, "k1", map[string]string{"k1": testKey('1')})
+
+	value, err := c.Decrypt("Test Testov")
+
+	require.NoError(t, err)
+	assert.Equal(t, "Test Testov", value)
+}
+
+func TestNilCipher(t *testing.T) {
+	c, err := New(config.Encryption{})
+	require.NoError(t, err)
+	assert.Nil(t, c)
+
+	d := models.Delivery{Name: "Test", Phone: "+9720000000"}
+	encrypted, err := c.EncryptDelivery(d)
+	require.NoError(t, err)
+	assert.Equal(t, d, encrypted)
+	assert.Empty(t, c.BlindIndex("+9720000000"))
+}
+
+func TestBlindIndex_Normalizes(t *testing.T) {
+	c := newTestCipher(t, "k1", map[string]string{"k1": testKey('1')})
+
+	assert.Equal(t, c.BlindIndex("+972 000-00-00"), c.BlindIndex("+9720000000"))
+	assert.Equal(t, c.BlindIndex(" Test@Gmail.com"), c.BlindIndex("test@gmail.com"))
+	assert.NotEqual(t, c.BlindIndex("+9720000000"), c.BlindIndex("+9720000001"))
+	assert.Empty(t, c.BlindIndex(""))
+}
+
+func TestEncryptDelivery_KeepsNonPIIFields(t *testing.T) {
+	c := newTestCipher(t, "k1", map[string]string{"k1": testKey('1')})
+	d := models.Delivery{Name: "Test", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin", Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com"}
+
+	encrypted, err := c.EncryptDelivery(d)
+
+	require.NoError(t, err)
+	assert.Equal(t, d.City, encrypted.City)
+	assert.Equal(t, d.Region, encrypted.Region)
+	assert.True(t, IsEncrypted(encrypted.Phone))
+	assert.True(t, IsEncrypted(encrypted.Address))
+	assert.True(t, IsEncrypted(encrypted.Name))
+	assert.True(t, IsEncrypted(encrypted.Email))
+
+	require.NoError(t, c.DecryptDelivery(&encrypted))
+	assert.Equal(t, d, encrypted)
+}
+
+func TestNew_KeysFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("k2: "+testKey('2')+"\n"), 0o600))
+
+	c, err := New(config.Encryption{Enabled: true, PrimaryKeyID: "k2", KeysFile: path, BlindIndexKey: testKey('b')})
+
+	require.NoError(t, err)
+	assert.Equal(t, "k2", c.PrimaryKeyID())
+}
+
+func TestNew_Invalid(t *testing.T) {
+	_, err := New(config.Encryption{Enabled: true, PrimaryKeyID: "missing", Keys: map[string]string{"k1": testKey('1')}, BlindIndexKey: testKey('b')})
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	_, err = New(config.Encryption{Enabled: true, PrimaryKeyID: "k1", Keys: map[string]string{"k1": "c2hvcnQ="}, BlindIndexKey: testKey('b')})
+	assert.Error(t, err)
+}
+
+func TestNew_MissingKeys(t *testing.T) {
+	_, err := New(config.Encryption{Enabled: true, PrimaryKeyID: "k1", BlindIndexKey: testKey('b')})
+	assert.ErrorIs(t, err, ErrNoKeys)
+
+	_, err = New(config.Encryption{Enabled: true, PrimaryKeyID: "k1", Keys: map[string]string{"k1": testKey('1')}})
+	assert.ErrorIs(t, err, ErrNoKeys)
+}
@@ -0,0 +1,113 @@
+package graphqlapi
+
+import (
+	"L0/internal/models"
+	"L0/internal/service"
+	"fmt"
+	"github.com/graphql-go/graphql/language/ast"
+	"strconv"
+	"strings"
+)
+
+// cost - глубина и стоимость набора полей.
+type cost struct {
+	depth      int
+	complexity int
+}
+
+// checkLimits оценивает глубину и стоимость операций документа до выполнения. Поле стоит 1, поля
+// внутри orders умножаются на first. Поля интроспекции (__schema, __type) не учитываются, иначе
+// playground не смог бы загрузить схему. Документ должен пройти валидацию: циклов фрагментов в нём нет.
+func checkLimits(doc *ast.Document, variables map[string]interface{}, maxDepth int, maxComplexity int) error {
+	m := &measurer{fragments: make(map[string]*ast.FragmentDefinition), memo: make(map[string]cost), variables: variables}
+	for _, def := range doc.Definitions {
+		if fragment, ok := def.(*ast.FragmentDefinition); ok {
+			m.fragments[fragment.Name.Value] = fragment
+		}
+	}
+	for _, def := range doc.Definitions {
+		operation, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		c := m.selectionSet(operation.SelectionSet)
+		if maxDepth > 0 && c.depth > maxDepth {
+			return &models.ValidationError{Reason: fmt.Sprintf("query depth %d exceeds limit %d", c.depth, maxDepth)}
+		}
+		if maxComplexity > 0 && c.complexity > maxComplexity {
+			return &models.ValidationError{Reason: fmt.Sprintf("query complexity %d exceeds limit %d", c.complexity, maxComplexity)}
+		}
+	}
+	return nil
+}
+
+type measurer struct {
+	fragments map[string]*ast.FragmentDefinition
+	// memo - стоимость фрагментов: без неё вложенные повторы фрагментов считались бы экспоненциально долго.
+	memo      map[string]cost
+	variables map[string]interface{}
+}
+
+func (m *measurer) selectionSet(set *ast.SelectionSet) cost {
+	var total cost
+	if set == nil {
+		return total
+	}
+	for _, selection := range set.Selections {
+		var c cost
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(selection.Name.Value, "__") {
+				continue
+			}
+			children := m.selectionSet(selection.SelectionSet)
+			c = cost{depth: children.depth + 1, complexity: 1 + children.complexity*m.multiplier(selection)}
+		case *ast.InlineFragment:
+			c = m.selectionSet(selection.SelectionSet)
+		case *ast.FragmentSpread:
+			c = m.fragment(selection.Name.Value)
+		}
+		total.depth = max(total.depth, c.depth)
+		total.complexity += c.complexity
+	}
+	return total
+}
+
+func (m *measurer) fragment(name string) cost {
+	if c, ok := m.memo[name]; ok {
+		return c
+	}
+	var c cost
+	if fragment := m.fragments[name]; fragment != nil {
+		c = m.selectionSet(fragment.SelectionSet)
+	}
+	m.memo[name] = c
+	return c
+}
+
+// multiplier - сколько раз выбираются вложенные поля: для orders это first, ограниченный MaxListLimit.
+func (m *measurer) multiplier(field *ast.Field) int {
+	if field.Name.Value != "orders" {
+		return 1
+	}
+	first := service.DefaultListLimit
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+		switch value := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(value.Value); err == nil {
+				first = n
+			}
+		case *ast.Variable:
+			switch n := m.variables[value.Name.Value].(type) {
+			case float64:
+				first = int(n)
+			case int:
+				first = n
+			}
+		}
+	}
+	return min(max(first, 1), service.MaxListLimit)
+}
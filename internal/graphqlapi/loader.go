@@ -0,0 +1,74 @@
+package graphqlapi
+
+import (
+	"L0/internal/models"
+	"L0/pkg/logger"
+	"context"
+	"go.uber.org/zap"
+	"sync"
+)
+
+// itemsLoader собирает заказы, чьи товары запрошены, и читает товары всех собранных заказов одним
+// обращением к Repository. Резолвер items возвращает thunk, а graphql-go вызывает thunk'и только после
+// того, как пройден весь уровень запроса, поэтому в пачку попадают все заказы страницы. Живёт один запрос.
+type itemsLoader struct {
+	repo    Repository
+	log     *zap.Logger
+	mu      sync.Mutex
+	pending []models.OrderKey
+	loaded  map[string][]*models.Item
+	failed  map[string]error
+}
+
+func newItemsLoader(repo Repository, log *zap.Logger) *itemsLoader {
+	return &itemsLoader{
+		repo:   repo,
+		log:    log,
+		loaded: make(map[string][]*models.Item),
+		failed: make(map[string]error),
+	}
+}
+
+// load откладывает чтение товаров заказа key до вызова возвращённого thunk'а.
+func (l *itemsLoader) load(ctx context.Context, key models.OrderKey) func() (interface{}, error) {
+	id := key.Cursor()
+	l.mu.Lock()
+	if _, ok := l.loaded[id]; !ok {
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if len(l.pending) > 0 {
+			l.dispatch(ctx)
+		}
+		if err := l.failed[id]; err != nil {
+			return nil, err
+		}
+		return l.loaded[id], nil
+	}
+}
+
+// dispatch читает товары всех ожидающих заказов. Вызывается под l.mu.
+func (l *itemsLoader) dispatch(ctx context.Context) {
+	keys := l.pending
+	l.pending = nil
+	items, err := l.repo.GetItemsByOrders(ctx, keys)
+	if err != nil {
+		logger.FromContext(ctx, l.log).Error("Error loading items", zap.Int("orders", len(keys)), zap.Error(err))
+	}
+	for i, key := range keys {
+		id := key.Cursor()
+		if err != nil {
+			l.failed[id] = err
+			continue
+		}
+		orderItems := make([]*models.Item, len(items[i]))
+		for j := range items[i] {
+			orderItems[j] = &items[i][j]
+		}
+		l.loaded[id] = orderItems
+	}
+}
@@ -0,0 +1,32 @@
+package graphqlapi
+
+import "strings"
+
+// playgroundPage - GraphiQL со статикой с unpkg. API ключ задаётся во вкладке Headers.
+const playgroundPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>L0 GraphQL</title>
+  <link rel="stylesheet" href="https://unpkg.com/graphiql@3/graphiql.min.css">
+  <style>body { margin: 0; height: 100vh; } #graphiql { height: 100vh; }</style>
+</head>
+<body>
+  <div id="graphiql"></div>
+  <script crossorigin src="https://unpkg.com/react@18/umd/react.production.min.js"></script>
+  <script crossorigin src="https://unpkg.com/react-dom@18/umd/react-dom.production.min.js"></script>
+  <script crossorigin src="https://unpkg.com/graphiql@3/graphiql.min.js"></script>
+  <script>
+    const fetcher = GraphiQL.createFetcher({ url: "{{endpoint}}" });
+    ReactDOM.createRoot(document.getElementById("graphiql")).render(
+      React.createElement(GraphiQL, { fetcher, defaultHeaders: '{"X-API-Key": ""}' })
+    );
+  </script>
+</body>
+</html>
+`
+
+// Playground возвращает страницу GraphiQL, которая отправляет запросы на endpoint.
+func Playground(endpoint string) []byte {
+	return []byte(strings.ReplaceAll(playgroundPage, "{{endpoint}}", endpoint))
+}
@@ -0,0 +1,246 @@
+package graphqlapi
+
+import (
+	"L0/internal/models"
+	"L0/internal/service"
+	"L0/pkg/logger"
+	"errors"
+	"fmt"
+	"github.com/graphql-go/graphql"
+	"go.uber.org/zap"
+)
+
+// maxIDLength - ограничение на длину идентификаторов, как в REST API.
+const maxIDLength = 64
+
+// field - поле, значение которого берётся из источника типа *T.
+func field[T any](typ graphql.Output, get func(source *T) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: typ,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return get(p.Source.(*T)), nil
+		},
+	}
+}
+
+var (
+	str    = graphql.NewNonNull(graphql.String)
+	number = graphql.NewNonNull(graphql.Int)
+)
+
+var deliveryType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "Delivery",
+	Description: "Получатель. Контакты маскируются по роли вызывающего.",
+	Fields: graphql.Fields{
+		"name":    field(str, func(d *models.Delivery) interface{} { return d.Name }),
+		"phone":   field(str, func(d *models.Delivery) interface{} { return d.Phone }),
+		"zip":     field(str, func(d *models.Delivery) interface{} { return d.Zip }),
+		"city":    field(str, func(d *models.Delivery) interface{} { return d.City }),
+		"address": field(str, func(d *models.Delivery) interface{} { return d.Address }),
+		"region":  field(str, func(d *models.Delivery) interface{} { return d.Region }),
+		"email":   field(str, func(d *models.Delivery) interface{} { return d.Email }),
+	},
+})
+
+var paymentType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Payment",
+	Fields: graphql.Fields{
+		"transaction":  field(str, func(p *models.Payment) interface{} { return p.Transaction }),
+		"requestId":    field(str, func(p *models.Payment) interface{} { return p.RequestID }),
+		"currency":     field(str, func(p *models.Payment) interface{} { return p.Currency }),
+		"provider":     field(str, func(p *models.Payment) interface{} { return p.Provider }),
+		"amount":       field(number, func(p *models.Payment) interface{} { return p.Amount }),
+		"paymentDt":    field(number, func(p *models.Payment) interface{} { return p.PaymentDt }),
+		"bank":         field(str, func(p *models.Payment) interface{} { return p.Bank }),
+		"deliveryCost": field(number, func(p *models.Payment) interface{} { return p.DeliveryCost }),
+		"goodsTotal":   field(number, func(p *models.Payment) interface{} { return p.GoodsTotal }),
+		"customFee":    field(number, func(p *models.Payment) interface{} { return p.CustomFee }),
+	},
+})
+
+var itemType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Item",
+	Fields: graphql.Fields{
+		"chrtId":      field(number, func(i *models.Item) interface{} { return i.ChrtID }),
+		"trackNumber": field(str, func(i *models.Item) interface{} { return i.TrackNumber }),
+		"price":       field(number, func(i *models.Item) interface{} { return i.Price }),
+		"rid":         field(str, func(i *models.Item) interface{} { return i.Rid }),
+		"name":        field(str, func(i *models.Item) interface{} { return i.Name }),
+		"sale":        field(number, func(i *models.Item) interface{} { return i.Sale }),
+		"size":        field(str, func(i *models.Item) interface{} { return i.Size }),
+		"totalPrice":  field(number, func(i *models.Item) interface{} { return i.TotalPrice }),
+		"nmId":        field(number, func(i *models.Item) interface{} { return i.NmID }),
+		"brand":       field(str, func(i *models.Item) interface{} { return i.Brand }),
+		"status":      field(number, func(i *models.Item) interface{} { return i.Status }),
+	},
+})
+
+var orderType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Order",
+	Fields: graphql.Fields{
+		"orderUid":          field(str, func(o *models.Order) interface{} { return o.OrderUID }),
+		"trackNumber":       field(str, func(o *models.Order) interface{} { return o.TrackNumber }),
+		"entry":             field(str, func(o *models.Order) interface{} { return o.Entry }),
+		"delivery":          field(graphql.NewNonNull(deliveryType), func(o *models.Order) interface{} { return &o.Delivery }),
+		"payment":           field(graphql.NewNonNull(paymentType), func(o *models.Order) interface{} { return &o.Payment }),
+		"locale":            field(str, func(o *models.Order) interface{} { return o.Locale }),
+		"internalSignature": field(str, func(o *models.Order) interface{} { return o.InternalSignature }),
+		"customerId":        field(str, func(o *models.Order) interface{} { return o.CustomerID }),
+		"deliveryService":   field(str, func(o *models.Order) interface{} { return o.DeliveryService }),
+		"shardkey":          field(str, func(o *models.Order) interface{} { return o.Shardkey }),
+		"smId":              field(number, func(o *models.Order) interface{} { return o.SmID }),
+		"dateCreated":       field(graphql.NewNonNull(graphql.DateTime), func(o *models.Order) interface{} { return o.DateCreated }),
+		"oofShard":          field(str, func(o *models.Order) interface{} { return o.OofShard }),
+		"items": {
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(itemType))),
+			Description: "Товары в порядке добавления. Товары всех заказов одного ответа читаются одним запросом.",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				order := p.Source.(*models.Order)
+				return requestFrom(p.Context).items.load(p.Context, models.OrderKey{OrderUID: order.OrderUID, DateCreated: order.DateCreated}), nil
+			},
+		},
+	},
+})
+
+// edge - заказ страницы orders вместе с курсором на него.
+type edge struct {
+	cursor string
+	node   *models.Order
+}
+
+// connection - страница orders в форме Relay connection.
+type connection struct {
+	edges       []*edge
+	hasNextPage bool
+}
+
+var orderEdgeType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "OrderEdge",
+	Fields: graphql.Fields{
+		"cursor": field(str, func(e *edge) interface{} { return e.cursor }),
+		"node":   field(graphql.NewNonNull(orderType), func(e *edge) interface{} { return e.node }),
+	},
+})
+
+var pageInfoType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PageInfo",
+	Fields: graphql.Fields{
+		"hasNextPage": field(graphql.NewNonNull(graphql.Boolean), func(c *connection) interface{} { return c.hasNextPage }),
+		"endCursor": field(graphql.String, func(c *connection) interface{} {
+			if len(c.edges) == 0 {
+				return nil
+			}
+			return c.edges[len(c.edges)-1].cursor
+		}),
+	},
+})
+
+var orderConnectionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "OrderConnection",
+	Fields: graphql.Fields{
+		"edges": field(graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(orderEdgeType))), func(c *connection) interface{} { return c.edges }),
+		"pageInfo": {
+			Type: graphql.NewNonNull(pageInfoType),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source, nil
+			},
+		},
+	},
+})
+
+var orderFilterType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "OrderFilter",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"customerId":      {Type: graphql.String},
+		"deliveryService": {Type: graphql.String},
+	},
+})
+
+// newSchema собирает схему. Резолверы читают заказы через repo и маскируют их по роли запроса.
+func newSchema(repo Repository) (graphql.Schema, error) {
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"order": {
+				Type:        orderType,
+				Description: "Заказ по UID или null, если его нет.",
+				Args: graphql.FieldConfigArgument{
+					"uid": {Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					uid, _ := p.Args["uid"].(string)
+					if uid == "" || len(uid) > maxIDLength {
+						return nil, &models.ValidationError{Reason: fmt.Sprintf("uid must be 1-%d characters", maxIDLength)}
+					}
+					page, err := repo.GetOrderView(p.Context, uid, models.OrderView{SkipItems: true})
+					if errors.Is(err, models.OrderNotFoundError) {
+						return nil, nil
+					}
+					if err != nil {
+						logger.FromContext(p.Context, requestFrom(p.Context).log).Error("Error getting order", zap.String("order_uid", uid), zap.Error(err))
+						return nil, err
+					}
+					return requestFrom(p.Context).mask(page.Order), nil
+				},
+			},
+			"orders": {
+				Type:        graphql.NewNonNull(orderConnectionType),
+				Description: fmt.Sprintf("Заказы от новых к старым. first - 1-%d, по умолчанию %d; after - endCursor предыдущей страницы.", service.MaxListLimit, service.DefaultListLimit),
+				Args: graphql.FieldConfigArgument{
+					"filter": {Type: orderFilterType},
+					"first":  {Type: graphql.Int},
+					"after":  {Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return resolveOrders(p, repo)
+				},
+			},
+		},
+	})
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
+
+func resolveOrders(p graphql.ResolveParams, repo Repository) (interface{}, error) {
+	limit := service.DefaultListLimit
+	if first, ok := p.Args["first"].(int); ok {
+		limit = first
+	}
+	if limit < 1 || limit > service.MaxListLimit {
+		return nil, &models.ValidationError{Reason: fmt.Sprintf("first must be 1-%d", service.MaxListLimit)}
+	}
+	var filter models.OrderFilter
+	if input, ok := p.Args["filter"].(map[string]interface{}); ok {
+		filter.CustomerID, _ = input["customerId"].(string)
+		filter.DeliveryService, _ = input["deliveryService"].(string)
+	}
+	if len(filter.CustomerID) > maxIDLength || len(filter.DeliveryService) > maxIDLength {
+		return nil, &models.ValidationError{Reason: fmt.Sprintf("filters must be at most %d characters", maxIDLength)}
+	}
+	var after *models.OrderKey
+	if cursor, ok := p.Args["after"].(string); ok && cursor != "" {
+		key, err := models.ParseOrderCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = &key
+	}
+
+	req := requestFrom(p.Context)
+	// Лишний заказ показывает, есть ли следующая страница.
+	orders, err := repo.ListOrdersWithoutItems(p.Context, filter, after, limit+1)
+	if err != nil {
+		logger.FromContext(p.Context, req.log).Error("Error listing orders", zap.Error(err))
+		return nil, err
+	}
+	result := &connection{edges: make([]*edge, 0, min(len(orders), limit))}
+	if len(orders) > limit {
+		orders, result.hasNextPage = orders[:limit], true
+	}
+	for _, order := range orders {
+		result.edges = append(result.edges, &edge{
+			cursor: models.OrderKey{OrderUID: order.OrderUID, DateCreated: order.DateCreated}.Cursor(),
+			node:   req.mask(order),
+		})
+	}
+	return result, nil
+}
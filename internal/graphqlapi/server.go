@@ -0,0 +1,149 @@
+// Package graphqlapi - GraphQL API заказов: order(uid) и orders(filter, first, after) с вложенными
+// delivery, payment и items. Данные читаются напрямую из Repository, контакты маскируются по роли.
+package graphqlapi
+
+import (
+	"L0/internal/config"
+	"L0/internal/masking"
+	"L0/internal/models"
+	"L0/internal/router/problem"
+	"context"
+	"fmt"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"go.uber.org/zap"
+)
+
+// Repository - чтение заказов для резолверов, его реализует repository.Repository.
+type Repository interface {
+	GetOrderView(ctx context.Context, orderUID string, view models.OrderView) (*models.OrderPage, error)
+	ListOrdersWithoutItems(ctx context.Context, filter models.OrderFilter, after *models.OrderKey, limit int) ([]*models.Order, error)
+	GetItemsByOrders(ctx context.Context, keys []models.OrderKey) ([][]models.Item, error)
+}
+
+// Server разбирает, проверяет и выполняет запросы GraphQL.
+type Server struct {
+	schema        graphql.Schema
+	repo          Repository
+	masker        *masking.Masker
+	maxDepth      int
+	maxComplexity int
+	log           *zap.Logger
+}
+
+func NewServer(repo Repository, masker *masking.Masker, cfg config.GraphQL, log *zap.Logger) (*Server, error) {
+	schema, err := newSchema(repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build graphql schema: %w", err)
+	}
+	return &Server{
+		schema:        schema,
+		repo:          repo,
+		masker:        masker,
+		maxDepth:      cfg.MaxDepth,
+		maxComplexity: cfg.MaxComplexity,
+		log:           log.Named("graphql"),
+	}, nil
+}
+
+type requestKey struct{}
+
+// request - состояние одного запроса, доступное резолверам через контекст.
+type request struct {
+	role   string
+	masker *masking.Masker
+	items  *itemsLoader
+	log    *zap.Logger
+}
+
+func requestFrom(ctx context.Context) *request {
+	return ctx.Value(requestKey{}).(*request)
+}
+
+func (r *request) mask(order *models.Order) *models.Order {
+	return r.masker.Order(r.role, order)
+}
+
+// Execute выполняет запрос от имени role. Все ошибки, включая синтаксические и превышение лимитов,
+// возвращаются в Result.Errors с кодом в extensions.code.
+func (s *Server) Execute(ctx context.Context, role string, req models.GraphQLRequest) *graphql.Result {
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(req.Query),
+		Name: "GraphQL request",
+	})})
+	if err != nil {
+		return &graphql.Result{Errors: withCode(gqlerrors.FormatErrors(err), problem.CodeValidation)}
+	}
+	if validation := graphql.ValidateDocument(&s.schema, doc, nil); !validation.IsValid {
+		return &graphql.Result{Errors: withCode(validation.Errors, problem.CodeValidation)}
+	}
+	if err := checkLimits(doc, req.Variables, s.maxDepth, s.maxComplexity); err != nil {
+		return &graphql.Result{Errors: withCode(gqlerrors.FormatErrors(err), problem.CodeValidation)}
+	}
+
+	if role == "" {
+		role = s.masker.DefaultRole()
+	}
+	ctx = context.WithValue(ctx, requestKey{}, &request{
+		role:   role,
+		masker: s.masker,
+		items:  newItemsLoader(s.repo, s.log),
+		log:    s.log,
+	})
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        s.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	})
+	for i := range result.Errors {
+		result.Errors[i] = classify(result.Errors[i])
+	}
+	return result
+}
+
+func withCode(errs []gqlerrors.FormattedError, code string) []gqlerrors.FormattedError {
+	for i := range errs {
+		errs[i].Extensions = map[string]interface{}{"code": code}
+	}
+	return errs
+}
+
+// classify выставляет ошибке резолвера код и текст по той же классификации, что и problem+json в REST:
+// детали внутренних ошибок наружу не попадают. Ошибки самого graphql-go (например, неверный тип
+// переменной) считаются ошибками запроса.
+func classify(err gqlerrors.FormattedError) gqlerrors.FormattedError {
+	cause := rootError(err)
+	switch cause.(type) {
+	case gqlerrors.FormattedError, *gqlerrors.Error:
+		err.Extensions = map[string]interface{}{"code": problem.CodeValidation}
+		return err
+	}
+	_, code, detail := problem.Classify(cause)
+	err.Message = detail
+	err.Extensions = map[string]interface{}{"code": code}
+	return err
+}
+
+// rootError снимает обёртки graphql-go с ошибки, которую вернул резолвер.
+func rootError(err error) error {
+	for {
+		switch e := err.(type) {
+		case gqlerrors.FormattedError:
+			if e.OriginalError() == nil {
+				return e
+			}
+			err = e.OriginalError()
+		case *gqlerrors.Error:
+			if e.OriginalError == nil {
+				return e
+			}
+			err = e.OriginalError
+		default:
+			return err
+		}
+	}
+}
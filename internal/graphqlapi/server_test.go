@@ -0,0 +1,212 @@
+package graphqlapi
+
+import (
+	"L0/internal/config"
+	"L0/internal/masking"
+	"L0/internal/models"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+var baseTime = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// fakeRepo хранит заказы от новых к старым и считает обращения за товарами.
+type fakeRepo struct {
+	orders     []*models.Order
+	itemsCalls [][]models.OrderKey
+	itemsErr   error
+	listErr    error
+}
+
+func (r *fakeRepo) GetOrderView(_ context.Context, orderUID string, view models.OrderView) (*models.OrderPage, error) {
+	for _, order := range r.orders {
+		if order.OrderUID == orderUID {
+			return view.Page(order), nil
+		}
+	}
+	return nil, models.OrderNotFoundError
+}
+
+func (r *fakeRepo) ListOrdersWithoutItems(_ context.Context, _ models.OrderFilter, after *models.OrderKey, limit int) ([]*models.Order, error) {
+	if r.listErr != nil {
+		return nil, r.listErr
+	}
+	var result []*models.Order
+	for _, order := range r.orders {
+		if after != nil && !order.DateCreated.Before(after.DateCreated) {
+			continue
+		}
+		if len(result) == limit {
+			break
+		}
+		result = append(result, models.OrderView{SkipItems: true}.Page(order).Order)
+	}
+	return result, nil
+}
+
+func (r *fakeRepo) GetItemsByOrders(_ context.Context, keys []models.OrderKey) ([][]models.Item, error) {
+	r.itemsCalls = append(r.itemsCalls, keys)
+	if r.itemsErr != nil {
+		return nil, r.itemsErr
+	}
+	result := make([][]models.Item, len(keys))
+	for i, key := range keys {
+		for _, order := range r.orders {
+			if order.OrderUID == key.OrderUID {
+				result[i] = order.Items
+			}
+		}
+	}
+	return result, nil
+}
+
+func testOrder(uid string, created time.Time) *models.Order {
+	return &models.Order{
+		OrderUID:    uid,
+		TrackNumber: "WBILMTESTTRACK",
+		Delivery:    models.Delivery{Name: "Test Testov", Phone: "+79990000000"},
+		Payment:     models.Payment{Amount: 1817, Currency: "USD"},
+		Items:       []models.Item{{Rid: uid + "-r1", Price: 453}, {Rid: uid + "-r2", Price: 317}},
+		DateCreated: created,
+	}
+}
+
+func newTestServer(t *testing.T, cfg config.GraphQL) (*Server, *fakeRepo) {
+	repo := &fakeRepo{orders: []*models.Order{
+		testOrder("order-3", baseTime.Add(-time.Hour)),
+		testOrder("order-2", baseTime.Add(-2*time.Hour)),
+		testOrder("order-1", baseTime.Add(-3*time.Hour)),
+	}}
+	masker, err := masking.New(config.Masking{DefaultRole: "support", Roles: map[string]map[string]string{"support": {"phone": "partial"}, "admin": {}}})
+	require.NoError(t, err)
+	server, err := NewServer(repo, masker, cfg, zap.NewNop())
+	require.NoError(t, err)
+	return server, repo
+}
+
+// run выполняет запрос и возвращает ответ в том виде, в каком его получит клиент.
+func run(t *testing.T, server *Server, role string, query string, variables map[string]interface{}) map[string]interface{} {
+	result := server.Execute(context.Background(), role, models.GraphQLRequest{Query: query, Variables: variables})
+	return decode(t, result)
+}
+
+func decode(t *testing.T, result *graphql.Result) map[string]interface{} {
+	data, err := json.Marshal(result)
+	require.NoError(t, err)
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &body))
+	return body
+}
+
+func errorCode(t *testing.T, body map[string]interface{}) string {
+	errs, ok := body["errors"].([]interface{})
+	require.True(t, ok, "response has no errors: %v", body)
+	return errs[0].(map[string]interface{})["extensions"].(map[string]interface{})["code"].(string)
+}
+
+func TestOrder(t *testing.T) {
+	server, repo := newTestServer(t, config.GraphQL{})
+	query := `query($uid: String!) { order(uid: $uid) { orderUid delivery { phone } payment { amount } items { rid } } }`
+
+	body := run(t, server, "", query, map[string]interface{}{"uid": "order-2"})
+	require.Nil(t, body["errors"])
+	order := body["data"].(map[string]interface{})["order"].(map[string]interface{})
+	assert.Equal(t, "order-2", order["orderUid"])
+	assert.NotEqual(t, "+79990000000", order["delivery"].(map[string]interface{})["phone"], "роль по умолчанию видит телефон частично")
+	assert.Equal(t, 1817.0, order["payment"].(map[string]interface{})["amount"])
+	assert.Len(t, order["items"], 2)
+	require.Len(t, repo.itemsCalls, 1)
+
+	body = run(t, server, "admin", query, map[string]interface{}{"uid": "order-2"})
+	order = body["data"].(map[string]interface{})["order"].(map[string]interface{})
+	assert.Equal(t, "+79990000000", order["delivery"].(map[string]interface{})["phone"])
+
+	body = run(t, server, "", query, map[string]interface{}{"uid": "missing"})
+	assert.Nil(t, body["errors"])
+	assert.Nil(t, body["data"].(map[string]interface{})["order"])
+}
+
+func TestOrders_PagesAndBatchesItems(t *testing.T) {
+	server, repo := newTestServer(t, config.GraphQL{})
+	query := `query($after: String) {
+		orders(first: 2, after: $after) { edges { cursor node { orderUid items { rid } } } pageInfo { hasNextPage endCursor } }
+	}`
+
+	body := run(t, server, "", query, nil)
+	require.Nil(t, body["errors"])
+	orders := body["data"].(map[string]interface{})["orders"].(map[string]interface{})
+	edges := orders["edges"].([]interface{})
+	require.Len(t, edges, 2)
+	assert.Equal(t, "order-3", edges[0].(map[string]interface{})["node"].(map[string]interface{})["orderUid"])
+	assert.Len(t, edges[1].(map[string]interface{})["node"].(map[string]interface{})["items"], 2)
+	pageInfo := orders["pageInfo"].(map[string]interface{})
+	assert.Equal(t, true, pageInfo["hasNextPage"])
+	require.Len(t, repo.itemsCalls, 1, "товары всех заказов страницы читаются одним запросом")
+	assert.Len(t, repo.itemsCalls[0], 2)
+
+	body = run(t, server, "", query, map[string]interface{}{"after": pageInfo["endCursor"]})
+	require.Nil(t, body["errors"])
+	orders = body["data"].(map[string]interface{})["orders"].(map[string]interface{})
+	edges = orders["edges"].([]interface{})
+	require.Len(t, edges, 1)
+	assert.Equal(t, "order-1", edges[0].(map[string]interface{})["node"].(map[string]interface{})["orderUid"])
+	assert.Equal(t, false, orders["pageInfo"].(map[string]interface{})["hasNextPage"])
+}
+
+func TestOrders_InvalidArguments(t *testing.T) {
+	server, _ := newTestServer(t, config.GraphQL{})
+
+	for _, query := range []string{
+		`{ orders(first: 0) { edges { cursor } } }`,
+		`{ orders(first: 101) { edges { cursor } } }`,
+		`{ orders(after: "not-a-cursor") { edges { cursor } } }`,
+		`{ order(uid: "") { orderUid } }`,
+		`{ order(uid: 1) { orderUid } }`,
+		`{ order { orderUid } }`,
+		`{ orders { edges { cursor }`,
+	} {
+		t.Run(query, func(t *testing.T) {
+			assert.Equal(t, "validation_failed", errorCode(t, run(t, server, "", query, nil)))
+		})
+	}
+}
+
+func TestErrorsAreClassified(t *testing.T) {
+	server, repo := newTestServer(t, config.GraphQL{})
+
+	repo.itemsErr = &pgconn.PgError{Code: "57P01", Message: "terminating connection due to administrator command"}
+	body := run(t, server, "", `{ orders { edges { node { items { rid } } } } }`, nil)
+	assert.Equal(t, "dependency_unavailable", errorCode(t, body))
+
+	repo.listErr = context.DeadlineExceeded
+	body = run(t, server, "", `{ orders { edges { cursor } } }`, nil)
+	assert.Equal(t, "timeout", errorCode(t, body))
+	assert.Nil(t, body["data"], "orders обязателен, поэтому ошибка обнуляет data")
+}
+
+func TestLimits(t *testing.T) {
+	server, _ := newTestServer(t, config.GraphQL{MaxDepth: 4, MaxComplexity: 100})
+
+	body := run(t, server, "", `{ orders(first: 5) { edges { node { items { rid } } } } }`, nil)
+	assert.Equal(t, "validation_failed", errorCode(t, body), "глубина 5 больше 4")
+
+	body = run(t, server, "", `{ orders(first: 100) { edges { node { orderUid } } } }`, nil)
+	assert.Equal(t, "validation_failed", errorCode(t, body), "стоимость 301 больше 100")
+
+	body = run(t, server, "", `query($n: Int) { orders(first: $n) { edges { node { orderUid } } } }`, map[string]interface{}{"n": 50.0})
+	assert.Equal(t, "validation_failed", errorCode(t, body), "first из переменной тоже учитывается")
+
+	body = run(t, server, "", `fragment F on Order { orderUid } { orders(first: 10) { edges { node { ...F } } } }`, nil)
+	assert.Nil(t, body["errors"])
+
+	body = run(t, server, "", `{ __schema { types { name fields { name type { name ofType { name ofType { name } } } } } } }`, nil)
+	assert.Nil(t, body["errors"], "интроспекция не ограничивается")
+}
@@ -0,0 +1,184 @@
+package grpcapi
+
+import (
+	"L0/internal/auth"
+	"L0/internal/config"
+	"L0/internal/grpcapi/orderv1"
+	"L0/internal/ratelimit"
+	"L0/pkg/logger"
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionv1alpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ключи метаданных. gRPC приводит их к нижнему регистру, значения те же, что у заголовков REST API.
+var (
+	requestIDKey = strings.ToLower("X-Request-ID")
+	apiKeyKey    = strings.ToLower(auth.APIKeyHeader)
+)
+
+// methodScopes - scope, необходимый для вызова метода. Reflection раскрывает схему API и требует
+// того же orders:read, что и Swagger в REST.
+var methodScopes = map[string]string{
+	orderv1.OrderService_GetOrder_FullMethodName:                           auth.ScopeOrdersRead,
+	orderv1.OrderService_ListOrders_FullMethodName:                         auth.ScopeOrdersRead,
+	orderv1.OrderService_StreamOrders_FullMethodName:                       auth.ScopeOrdersRead,
+	orderv1.OrderService_SubmitOrder_FullMethodName:                        auth.ScopeOrdersWrite,
+	reflectionv1.ServerReflection_ServerReflectionInfo_FullMethodName:      auth.ScopeOrdersRead,
+	reflectionv1alpha.ServerReflection_ServerReflectionInfo_FullMethodName: auth.ScopeOrdersRead,
+}
+
+type principalKey struct{}
+
+// callContext готовит контекст вызова: correlation id (из x-request-id или новый, возвращается
+// в заголовке ответа), поля логгера и аутентифицированный вызывающий.
+func (s *Server) callContext(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	requestID := first(md, requestIDKey)
+	if !logger.ValidRequestID(requestID) {
+		requestID = logger.NewRequestID()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, requestID))
+	ctx = logger.WithRequestID(ctx, requestID)
+	ctx = logger.WithFields(ctx, zap.String("grpc_method", method))
+
+	// Лимит на IP проверяется до аутентификации, чтобы перебор ключей тоже упирался в него.
+	client := "ip:" + peerIP(ctx)
+	if err := s.allow(ctx, client, s.rateLimit.PerIP); err != nil {
+		return ctx, err
+	}
+	if s.authn != nil {
+		principal, err := s.authn.AuthenticateCredentials(first(md, apiKeyKey), first(md, "authorization"))
+		switch {
+		case errors.Is(err, auth.ErrNoCredentials):
+			return ctx, status.Error(codes.Unauthenticated, "authentication required")
+		case err != nil:
+			logger.FromContext(ctx, s.log).Warn("Authentication failed", zap.Error(err))
+			return ctx, status.Error(codes.Unauthenticated, "invalid credentials")
+		}
+		scope, ok := methodScopes[method]
+		if !ok {
+			return ctx, status.Error(codes.PermissionDenied, "method is not available")
+		}
+		if !principal.HasScope(scope) {
+			return ctx, status.Error(codes.PermissionDenied, "missing scope "+scope)
+		}
+		ctx = context.WithValue(ctx, principalKey{}, principal)
+		client = "sub:" + principal.Subject
+	}
+	rule, ok := s.rateLimit.Routes[method]
+	if !ok {
+		rule = s.rateLimit.Default
+	}
+	if err := s.allow(ctx, method+":"+client, rule); err != nil {
+		return ctx, err
+	}
+	return ctx, nil
+}
+
+// allow забирает токен из ведра key, как RateLimitMiddleware в REST: при исчерпании - ResourceExhausted
+// с retry-after в заголовках ответа, при недоступности Redis вызов пропускается.
+func (s *Server) allow(ctx context.Context, key string, rule config.RateLimitRule) error {
+	if s.limiter == nil || rule.Rate <= 0 || rule.Burst <= 0 {
+		return nil
+	}
+	res, err := s.limiter.Allow(ctx, key, ratelimit.Limit{Rate: rule.Rate, Burst: rule.Burst})
+	if err != nil {
+		logger.FromContext(ctx, s.log).Warn("Rate limiter unavailable, call allowed", zap.Error(err))
+		return nil
+	}
+	if !res.Allowed {
+		_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(int(math.Ceil(res.RetryAfter.Seconds())))))
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	return nil
+}
+
+// peerIP - IP вызывающего без порта.
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+	return p.Addr.String()
+}
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (s *Server) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	start := time.Now()
+	ctx, err = s.callContext(ctx, info.FullMethod)
+	defer func() { s.logCall(ctx, start, err) }()
+	defer s.recoverPanic(ctx, &err)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) streamInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	start := time.Now()
+	ctx, err := s.callContext(stream.Context(), info.FullMethod)
+	defer func() { s.logCall(ctx, start, err) }()
+	defer s.recoverPanic(ctx, &err)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+}
+
+// recoverPanic отвечает Internal на панику в обработчике, как RecoveryMiddleware в REST.
+func (s *Server) recoverPanic(ctx context.Context, err *error) {
+	if recovered := recover(); recovered != nil {
+		logger.FromContext(ctx, s.log).Error("Panic recovered", zap.Any("panic", recovered), zap.Stack("stack"))
+		*err = status.Error(codes.Internal, "internal error")
+	}
+}
+
+// logCall пишет одну запись журнала на вызов, уровень выбирается по коду ответа.
+func (s *Server) logCall(ctx context.Context, start time.Time, err error) {
+	code := status.Code(err)
+	fields := []zap.Field{zap.String("code", code.String()), zap.Duration("latency", time.Since(start))}
+	if err != nil {
+		fields = append(fields, zap.String("error", status.Convert(err).Message()))
+	}
+	log := logger.FromContext(ctx, s.log)
+	switch code {
+	case codes.OK, codes.Canceled:
+		log.Info("gRPC call completed", fields...)
+	case codes.Internal, codes.Unknown, codes.DataLoss:
+		log.Error("gRPC call completed", fields...)
+	default:
+		log.Warn("gRPC call completed", fields...)
+	}
+}
+
+// contextStream подменяет контекст потока на подготовленный callContext.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,205 @@
+// Package grpcapi - gRPC API заказов (proto/order/v1/order.proto) поверх того же service.OrderService,
+// что и REST. Аутентификация, маскирование и коды ошибок согласованы с REST API.
+package grpcapi
+
+//go:generate protoc -I ../../proto --go_out=. --go_opt=module=L0/internal/grpcapi --go-grpc_out=. --go-grpc_opt=module=L0/internal/grpcapi order/v1/order.proto
+
+import (
+	"L0/internal/auth"
+	"L0/internal/config"
+	"L0/internal/events"
+	"L0/internal/grpcapi/orderv1"
+	"L0/internal/masking"
+	"L0/internal/models"
+	"L0/internal/ratelimit"
+	"L0/internal/router/problem"
+	"L0/internal/service"
+	"L0/pkg/logger"
+	"L0/pkg/validator"
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"net/http"
+)
+
+// maxIDLength - ограничение на длину идентификаторов, как в REST API.
+const maxIDLength = 64
+
+// Submitter публикует заказ в Kafka, его реализует messagebroker.Producer.
+type Submitter interface {
+	SendMessage(ctx context.Context, key string, value interface{}) error
+}
+
+// Options - необязательные компоненты gRPC сервера.
+type Options struct {
+	// Auth == nil отключает аутентификацию, все методы открыты.
+	Auth *auth.Authenticator
+	// Hub == nil отключает StreamOrders.
+	Hub *events.Hub
+	// Submitter == nil отключает SubmitOrder.
+	Submitter Submitter
+	// Reflection регистрирует сервис reflection для grpcurl и похожих клиентов.
+	Reflection bool
+	// Limiter == nil отключает ограничение частоты вызовов. Ключ RateLimit.Routes - полное имя
+	// метода, например "/order.v1.OrderService/SubmitOrder".
+	Limiter   *ratelimit.Limiter
+	RateLimit config.RateLimit
+}
+
+// Server реализует orderv1.OrderServiceServer.
+type Server struct {
+	orderv1.UnimplementedOrderServiceServer
+	orders    *service.OrderService
+	masker    *masking.Masker
+	hub       *events.Hub
+	submitter Submitter
+	authn     *auth.Authenticator
+	limiter   *ratelimit.Limiter
+	rateLimit config.RateLimit
+	log       *zap.Logger
+}
+
+// NewServer собирает grpc.Server с OrderService, аутентификацией, лимитом вызовов, журналом вызовов
+// и восстановлением после паник.
+func NewServer(orderService *service.OrderService, masker *masking.Masker, log *zap.Logger, opts Options) *grpc.Server {
+	s := &Server{
+		orders:    orderService,
+		masker:    masker,
+		hub:       opts.Hub,
+		submitter: opts.Submitter,
+		authn:     opts.Auth,
+		limiter:   opts.Limiter,
+		rateLimit: opts.RateLimit,
+		log:       log.Named("grpc"),
+	}
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.unaryInterceptor),
+		grpc.ChainStreamInterceptor(s.streamInterceptor),
+	)
+	orderv1.RegisterOrderServiceServer(server, s)
+	if opts.Reflection {
+		reflection.Register(server)
+	}
+	return server
+}
+
+func (s *Server) GetOrder(ctx context.Context, req *orderv1.GetOrderRequest) (*orderv1.Order, error) {
+	if err := validateID("order_uid", req.GetOrderUid()); err != nil {
+		return nil, toStatus(err)
+	}
+	order, err := s.orders.GetOrderByUID(ctx, req.GetOrderUid())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProtoOrder(s.masker.Order(s.role(ctx), order)), nil
+}
+
+func (s *Server) ListOrders(ctx context.Context, req *orderv1.ListOrdersRequest) (*orderv1.ListOrdersResponse, error) {
+	filter := models.OrderFilter{CustomerID: req.GetCustomerId(), DeliveryService: req.GetDeliveryService()}
+	if len(filter.CustomerID) > maxIDLength || len(filter.DeliveryService) > maxIDLength {
+		return nil, toStatus(&models.ValidationError{Reason: fmt.Sprintf("filters must be at most %d characters", maxIDLength)})
+	}
+	list, err := s.orders.ListOrders(ctx, filter, req.GetPageToken(), int(req.GetPageSize()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &orderv1.ListOrdersResponse{NextPageToken: list.NextCursor}
+	for _, order := range s.masker.Orders(s.role(ctx), list.Orders) {
+		resp.Orders = append(resp.Orders, toProtoOrder(order))
+	}
+	return resp, nil
+}
+
+func (s *Server) StreamOrders(req *orderv1.StreamOrdersRequest, stream grpc.ServerStreamingServer[orderv1.OrderSummary]) error {
+	if s.hub == nil {
+		return status.Error(codes.Unavailable, "order stream is disabled")
+	}
+	filter := events.Filter{DeliveryService: req.GetDeliveryService(), CustomerID: req.GetCustomerId()}
+	if len(filter.DeliveryService) > maxIDLength || len(filter.CustomerID) > maxIDLength {
+		return toStatus(&models.ValidationError{Reason: fmt.Sprintf("filters must be at most %d characters", maxIDLength)})
+	}
+	sub, err := s.hub.Subscribe(filter)
+	if errors.Is(err, events.ErrTooManySubscribers) {
+		return status.Error(codes.ResourceExhausted, "too many stream subscribers, retry later")
+	}
+	if err != nil {
+		return status.Error(codes.Unavailable, "order stream is shutting down")
+	}
+	defer sub.Close()
+	log := logger.FromContext(stream.Context(), s.log)
+	log.Info("gRPC stream client subscribed", zap.Int("subscribers", s.hub.Subscribers()))
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case summary, ok := <-sub.Events():
+			if !ok {
+				if sub.Dropped() {
+					log.Warn("gRPC stream client dropped as too slow")
+					return status.Error(codes.ResourceExhausted, "slow consumer")
+				}
+				return status.Error(codes.Unavailable, "server shutting down")
+			}
+			if err := stream.Send(toProtoSummary(summary)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Server) SubmitOrder(ctx context.Context, req *orderv1.SubmitOrderRequest) (*orderv1.SubmitOrderResponse, error) {
+	if s.submitter == nil {
+		return nil, status.Error(codes.Unimplemented, "order submission is disabled")
+	}
+	if req.GetOrder() == nil {
+		return nil, toStatus(&models.ValidationError{Reason: "order is required"})
+	}
+	order := fromProtoOrder(req.GetOrder())
+	if err := validator.ValidateOrder(order); err != nil {
+		return nil, toStatus(err)
+	}
+	// Producer берёт correlation id вызова в заголовок trace-id, консьюмер продолжит логи под тем же id.
+	if err := s.submitter.SendMessage(ctx, order.OrderUID, order); err != nil {
+		logger.FromContext(ctx, s.log).Error("Error publishing order", zap.String("order_uid", order.OrderUID), zap.Error(err))
+		return nil, status.Error(codes.Unavailable, "failed to publish order")
+	}
+	return &orderv1.SubmitOrderResponse{OrderUid: order.OrderUID, TraceId: logger.RequestID(ctx)}, nil
+}
+
+// role - роль вызывающего для маскирования, как у AuthMiddleware в REST.
+func (s *Server) role(ctx context.Context) string {
+	if principal, ok := ctx.Value(principalKey{}).(*auth.Principal); ok && principal.Role != "" {
+		return principal.Role
+	}
+	return s.masker.DefaultRole()
+}
+
+func validateID(name string, value string) error {
+	if value == "" || len(value) > maxIDLength {
+		return &models.ValidationError{Reason: fmt.Sprintf("%s must be 1-%d characters", name, maxIDLength)}
+	}
+	return nil
+}
+
+// toStatus переводит ошибку сервиса в статус gRPC по той же классификации, что и problem+json в REST.
+func toStatus(err error) error {
+	httpStatus, _, detail := problem.Classify(err)
+	code := codes.Internal
+	switch httpStatus {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	case http.StatusGatewayTimeout:
+		code = codes.DeadlineExceeded
+	}
+	return status.Error(code, detail)
+}
@@ -0,0 +1,321 @@
+package grpcapi
+
+import (
+	"L0/internal/auth"
+	"L0/internal/config"
+	"L0/internal/events"
+	"L0/internal/grpcapi/orderv1"
+	"L0/internal/masking"
+	"L0/internal/models"
+	"L0/internal/ratelimit"
+	"L0/internal/service"
+	"L0/pkg/logger"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var baseTime = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// fakeRepo хранит заказы от новых к старым, как их отдаёт postgres.
+type fakeRepo struct {
+	orders []*models.Order
+}
+
+func (fakeRepo) SaveOrder(context.Context, *models.Order) error { return nil }
+func (r fakeRepo) GetOrderByUID(_ context.Context, orderUID string) (*models.Order, error) {
+	for _, order := range r.orders {
+		if order.OrderUID == orderUID {
+			return order, nil
+		}
+	}
+	return nil, models.OrderNotFoundError
+}
+func (fakeRepo) GetOrderView(context.Context, string, models.OrderView) (*models.OrderPage, error) {
+	return nil, models.OrderNotFoundError
+}
+func (fakeRepo) GetRecentOrders(context.Context, int) ([]*models.Order, error) { return nil, nil }
+func (r fakeRepo) ListOrders(_ context.Context, filter models.OrderFilter, after *models.OrderKey, limit int) ([]*models.Order, error) {
+	var result []*models.Order
+	for _, order := range r.orders {
+		if filter.CustomerID != "" && order.CustomerID != filter.CustomerID {
+			continue
+		}
+		if after != nil && !order.DateCreated.Before(after.DateCreated) {
+			continue
+		}
+		if len(result) == limit {
+			break
+		}
+		result = append(result, order)
+	}
+	return result, nil
+}
+func (fakeRepo) ErasePII(context.Context, string, string) (*models.ErasureResult, error) {
+	return nil, nil
+}
+func (fakeRepo) PoolStats() models.PoolStats { return models.PoolStats{} }
+func (fakeRepo) Close()                      {}
+
+type fakeCache struct{}
+
+func (fakeCache) SetOrder(context.Context, *models.Order, time.Duration, string) error { return nil }
+func (fakeCache) GetOrder(context.Context, string, string) (*models.Order, error) {
+	return nil, redis.Nil
+}
+func (fakeCache) DeleteOrders(context.Context, ...string) error { return nil }
+func (fakeCache) Close()                                        {}
+
+type fakeSubmitter struct {
+	keys    []string
+	traceID string
+	fail    error
+}
+
+func (s *fakeSubmitter) SendMessage(ctx context.Context, key string, _ interface{}) error {
+	s.keys = append(s.keys, key)
+	s.traceID = logger.RequestID(ctx)
+	return s.fail
+}
+
+func testOrder(uid string, created time.Time) *models.Order {
+	return &models.Order{
+		OrderUID:    uid,
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery:    models.Delivery{Name: "Test Testov", Phone: "+79990000000", City: "Kiryat Mozkin", Address: "Ploshad Mira 15"},
+		Payment:     models.Payment{Transaction: uid, Currency: "USD", Provider: "wbpay", Amount: 1817},
+		Items: []models.Item{{
+			ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, Rid: "ab4219087a764ae0btest",
+			Name: "Mascaras", TotalPrice: 317, NmID: 2389212, Brand: "Vivienne Sabo", Status: 202,
+		}},
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		DateCreated:     created,
+	}
+}
+
+func newTestClient(t *testing.T, hub *events.Hub, submitter Submitter) orderv1.OrderServiceClient {
+	return orderv1.NewOrderServiceClient(newTestConn(t, Options{Hub: hub, Submitter: submitter}))
+}
+
+// newTestConn поднимает сервер с opts и ключами reader, writer и admin на bufconn.
+func newTestConn(t *testing.T, opts Options) *grpc.ClientConn {
+	authn, err := auth.New(config.Auth{APIKeys: []config.APIKey{
+		{Name: "reader", Hash: auth.HashAPIKey("read-key"), Scopes: []string{auth.ScopeOrdersRead}},
+		{Name: "writer", Hash: auth.HashAPIKey("write-key"), Scopes: []string{auth.ScopeOrdersWrite}},
+		{Name: "admin", Hash: auth.HashAPIKey("admin-key"), Scopes: []string{auth.ScopeAdmin}, Role: "admin"},
+	}})
+	require.NoError(t, err)
+	masker, err := masking.New(config.Masking{DefaultRole: "support", Roles: map[string]map[string]string{"support": {"phone": "partial"}, "admin": {}}})
+	require.NoError(t, err)
+	repo := fakeRepo{orders: []*models.Order{
+		testOrder("order-3", baseTime.Add(-time.Hour)),
+		testOrder("order-2", baseTime.Add(-2*time.Hour)),
+		testOrder("order-1", baseTime.Add(-3*time.Hour)),
+	}}
+	svc := service.NewOrderService(nil, repo, fakeCache{}, time.Minute, zap.NewNop())
+	opts.Auth = authn
+	server := NewServer(svc, masker, zap.NewNop(), opts)
+
+	listener := bufconn.Listen(1 << 20)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func withKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), auth.APIKeyHeader, key)
+}
+
+func TestGetOrder(t *testing.T) {
+	client := newTestClient(t, nil, nil)
+
+	var header metadata.MD
+	order, err := client.GetOrder(withKey("read-key"), &orderv1.GetOrderRequest{OrderUid: "order-2"}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Equal(t, "order-2", order.GetOrderUid())
+	assert.NotEqual(t, "+79990000000", order.GetDelivery().GetPhone(), "роль по умолчанию видит телефон частично")
+	assert.Equal(t, int64(9934930), order.GetItems()[0].GetChrtId())
+	assert.True(t, order.GetDateCreated().AsTime().Equal(baseTime.Add(-2*time.Hour)))
+	assert.NotEmpty(t, header.Get("x-request-id"))
+
+	order, err = client.GetOrder(withKey("admin-key"), &orderv1.GetOrderRequest{OrderUid: "order-2"})
+	require.NoError(t, err)
+	assert.Equal(t, "+79990000000", order.GetDelivery().GetPhone())
+
+	_, err = client.GetOrder(withKey("read-key"), &orderv1.GetOrderRequest{OrderUid: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.GetOrder(withKey("read-key"), &orderv1.GetOrderRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestAuthentication(t *testing.T) {
+	client := newTestClient(t, nil, &fakeSubmitter{})
+
+	_, err := client.GetOrder(context.Background(), &orderv1.GetOrderRequest{OrderUid: "order-1"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.GetOrder(withKey("wrong-key"), &orderv1.GetOrderRequest{OrderUid: "order-1"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.GetOrder(withKey("write-key"), &orderv1.GetOrderRequest{OrderUid: "order-1"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = client.SubmitOrder(withKey("read-key"), &orderv1.SubmitOrderRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestListOrders_Pages(t *testing.T) {
+	client := newTestClient(t, nil, nil)
+
+	first, err := client.ListOrders(withKey("read-key"), &orderv1.ListOrdersRequest{PageSize: 2})
+	require.NoError(t, err)
+	require.Len(t, first.GetOrders(), 2)
+	assert.Equal(t, "order-3", first.GetOrders()[0].GetOrderUid())
+	require.NotEmpty(t, first.GetNextPageToken())
+
+	second, err := client.ListOrders(withKey("read-key"), &orderv1.ListOrdersRequest{PageSize: 2, PageToken: first.GetNextPageToken()})
+	require.NoError(t, err)
+	require.Len(t, second.GetOrders(), 1)
+	assert.Equal(t, "order-1", second.GetOrders()[0].GetOrderUid())
+	assert.Empty(t, second.GetNextPageToken())
+
+	_, err = client.ListOrders(withKey("read-key"), &orderv1.ListOrdersRequest{PageToken: "not-a-cursor"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.ListOrders(withKey("read-key"), &orderv1.ListOrdersRequest{PageSize: service.MaxListLimit + 1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestStreamOrders(t *testing.T) {
+	hub := events.NewHub(4, 0, zap.NewNop())
+	client := newTestClient(t, hub, nil)
+
+	ctx, cancel := context.WithCancel(withKey("read-key"))
+	defer cancel()
+	stream, err := client.StreamOrders(ctx, &orderv1.StreamOrdersRequest{DeliveryService: "meest"})
+	require.NoError(t, err)
+	// Публикуем только после подписки, иначе заказ прошёл бы мимо потока.
+	require.Eventually(t, func() bool { return hub.Subscribers() == 1 }, time.Second, 10*time.Millisecond)
+
+	hub.Publish(models.OrderSummary{OrderUID: "other", DeliveryService: "dhl"})
+	hub.Publish(models.OrderSummary{OrderUID: "order-4", DeliveryService: "meest", Amount: 1817, DateCreated: baseTime})
+	summary, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "order-4", summary.GetOrderUid())
+	assert.Equal(t, int64(1817), summary.GetAmount())
+
+	hub.Close()
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestStreamOrders_Disabled(t *testing.T) {
+	client := newTestClient(t, nil, nil)
+
+	stream, err := client.StreamOrders(withKey("read-key"), &orderv1.StreamOrdersRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestSubmitOrder(t *testing.T) {
+	submitter := &fakeSubmitter{}
+	client := newTestClient(t, nil, submitter)
+
+	order := toProtoOrder(testOrder("order-new", baseTime))
+	ctx := metadata.AppendToOutgoingContext(withKey("write-key"), "x-request-id", "trace-123")
+	resp, err := client.SubmitOrder(ctx, &orderv1.SubmitOrderRequest{Order: order})
+	require.NoError(t, err)
+	assert.Equal(t, "order-new", resp.GetOrderUid())
+	assert.Equal(t, "trace-123", resp.GetTraceId())
+	assert.Equal(t, []string{"order-new"}, submitter.keys)
+	assert.Equal(t, "trace-123", submitter.traceID)
+
+	order.Payment = nil
+	order.DateCreated = timestamppb.New(baseTime)
+	_, err = client.SubmitOrder(withKey("write-key"), &orderv1.SubmitOrderRequest{Order: order})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.SubmitOrder(withKey("write-key"), &orderv1.SubmitOrderRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Len(t, submitter.keys, 1, "невалидные заказы не публикуются")
+
+	submitter.fail = errors.New("kafka down")
+	_, err = client.SubmitOrder(withKey("write-key"), &orderv1.SubmitOrderRequest{Order: toProtoOrder(testOrder("order-5", baseTime))})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestReflection_RequiresReadScope(t *testing.T) {
+	client := reflectionv1.NewServerReflectionClient(newTestConn(t, Options{Reflection: true}))
+
+	listServices := func(ctx context.Context) (*reflectionv1.ServerReflectionResponse, error) {
+		stream, err := client.ServerReflectionInfo(ctx)
+		require.NoError(t, err)
+		err = stream.Send(&reflectionv1.ServerReflectionRequest{
+			MessageRequest: &reflectionv1.ServerReflectionRequest_ListServices{},
+		})
+		require.NoError(t, err)
+		return stream.Recv()
+	}
+
+	resp, err := listServices(withKey("read-key"))
+	require.NoError(t, err)
+	var services []string
+	for _, service := range resp.GetListServicesResponse().GetService() {
+		services = append(services, service.GetName())
+	}
+	assert.Contains(t, services, "order.v1.OrderService")
+
+	_, err = listServices(context.Background())
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestRateLimit(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+	client := orderv1.NewOrderServiceClient(newTestConn(t, Options{
+		Limiter: ratelimit.NewLimiter(redisClient),
+		RateLimit: config.RateLimit{
+			Default: config.RateLimitRule{Rate: 1, Burst: 1},
+			PerIP:   config.RateLimitRule{Rate: 1, Burst: 3},
+		},
+	}))
+
+	_, err := client.GetOrder(withKey("read-key"), &orderv1.GetOrderRequest{OrderUid: "order-1"})
+	require.NoError(t, err)
+
+	var header metadata.MD
+	_, err = client.GetOrder(withKey("read-key"), &orderv1.GetOrderRequest{OrderUid: "order-1"}, grpc.Header(&header))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, []string{"1"}, header.Get("retry-after"))
+
+	// Неверные ключи расходуют лимит IP, и после него не проверяются даже верные.
+	_, err = client.GetOrder(withKey("wrong-key"), &orderv1.GetOrderRequest{OrderUid: "order-1"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = client.GetOrder(withKey("admin-key"), &orderv1.GetOrderRequest{OrderUid: "order-1"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
@@ -0,0 +1,128 @@
+// Package masking скрывает персональные данные доставки в ответах API в зависимости от роли вызывающего.
+package masking
+
+import (
+	"L0/internal/config"
+	"L0/internal/models"
+	"fmt"
+	"strings"
+)
+
+const (
+	ModeFull    = "full"
+	ModePartial = "partial"
+	ModeRedact  = "redact"
+)
+
+// Policy - режим показа для каждого поля доставки. Поле без режима показывается полностью.
+type Policy map[string]string
+
+// Masker применяет политики ролей к заказам. Роль без собственной политики получает политику defaultRole.
+type Masker struct {
+	policies    map[string]Policy
+	defaultRole string
+}
+
+func New(cfg config.Masking) (*Masker, error) {
+	m := &Masker{policies: make(map[string]Policy, len(cfg.Roles)), defaultRole: cfg.DefaultRole}
+	for role, policy := range cfg.Roles {
+		for field, mode := range policy {
+			if _, ok := maskers[field]; !ok {
+				return nil, fmt.Errorf("role %q: unknown delivery field %q", role, field)
+			}
+			if mode != ModeFull && mode != ModePartial && mode != ModeRedact {
+				return nil, fmt.Errorf("role %q: unknown mode %q for field %q", role, mode, field)
+			}
+		}
+		m.policies[role] = policy
+	}
+	if _, ok := m.policies[m.defaultRole]; !ok {
+		return nil, fmt.Errorf("default role %q has no masking policy", m.defaultRole)
+	}
+	return m, nil
+}
+
+// DefaultRole - роль для запросов, у которых роль не определена.
+func (m *Masker) DefaultRole() string {
+	return m.defaultRole
+}
+
+// Order возвращает копию заказа с данными доставки, скрытыми по политике роли. Исходный заказ не меняется.
+func (m *Masker) Order(role string, order *models.Order) *models.Order {
+	if order == nil {
+		return nil
+	}
+	policy, ok := m.policies[role]
+	if !ok {
+		policy = m.policies[m.defaultRole]
+	}
+	masked := *order
+	for field, mode := range policy {
+		f := maskers[field]
+		value := f.get(&masked.Delivery)
+		switch mode {
+		case ModePartial:
+			*value = f.partial(*value)
+		case ModeRedact:
+			*value = ""
+		}
+	}
+	return &masked
+}
+
+// Orders применяет Order к каждому заказу.
+func (m *Masker) Orders(role string, orders []*models.Order) []*models.Order {
+	masked := make([]*models.Order, len(orders))
+	for i, order := range orders {
+		masked[i] = m.Order(role, order)
+	}
+	return masked
+}
+
+type fieldMasker struct {
+	get     func(d *models.Delivery) *string
+	partial func(value string) string
+}
+
+var maskers = map[string]fieldMasker{
+	"name":    {get: func(d *models.Delivery) *string { return &d.Name }, partial: maskName},
+	"phone":   {get: func(d *models.Delivery) *string { return &d.Phone }, partial: maskPhone},
+	"email":   {get: func(d *models.Delivery) *string { return &d.Email }, partial: maskEmail},
+	"address": {get: func(d *models.Delivery) *string { return &d.Address }, partial: maskAddress},
+	"zip":     {get: func(d *models.Delivery) *string { return &d.Zip }, partial: maskAddress},
+}
+
+// maskPhone оставляет первые 4 и последние 2 символа: +9720000000 -> +972*****00.
+func maskPhone(phone string) string {
+	r := []rune(phone)
+	if len(r) <= 6 {
+		return strings.Repeat("*", len(r))
+	}
+	return string(r[:4]) + strings.Repeat("*", len(r)-6) + string(r[len(r)-2:])
+}
+
+// maskEmail оставляет первую букву адреса и домен: test@gmail.com -> t***@gmail.com.
+func maskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return strings.Repeat("*", len([]rune(email)))
+	}
+	first := []rune(email[:at])[0]
+	return string(first) + "***" + email[at:]
+}
+
+// maskName оставляет первые буквы слов: Test Testov -> T*** T***.
+func maskName(name string) string {
+	words := strings.Fields(name)
+	for i, word := range words {
+		words[i] = string([]rune(word)[0]) + "***"
+	}
+	return strings.Join(words, " ")
+}
+
+func maskAddress(address string) string {
+	if address == "" {
+		return ""
+	}
+	return "***"
+}
@@ -0,0 +1,83 @@
+package masking
+
+import (
+	"L0/internal/config"
+	"L0/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMasker(t *testing.T) *Masker {
+	m, err := New(config.Masking{
+		DefaultRole: "support",
+		Roles: map[string]map[string]string{
+			"support": {"name": ModePartial, "phone": ModePartial, "email": ModePartial, "address": ModeRedact},
+			"admin":   {},
+		},
+	})
+	require.NoError(t, err)
+	return m
+}
+
+func testOrder() *models.Order {
+	return &models.Order{
+		OrderUID: "b563feb7b2b84b6test",
+		Delivery: models.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Email:   "test@gmail.com",
+		},
+		Payment: models.Payment{Amount: 1817},
+	}
+}
+
+func TestOrder_SupportIsMasked(t *testing.T) {
+	order := testOrder()
+
+	masked := newTestMasker(t).Order("support", order)
+
+	assert.Equal(t, "+972*****00", masked.Delivery.Phone)
+	assert.Equal(t, "t***@gmail.com", masked.Delivery.Email)
+	assert.Equal(t, "T*** T***", masked.Delivery.Name)
+	assert.Empty(t, masked.Delivery.Address)
+	assert.Equal(t, "Kiryat Mozkin", masked.Delivery.City)
+	assert.Equal(t, 1817, masked.Payment.Amount)
+	assert.Equal(t, "+9720000000", order.Delivery.Phone, "original order must not change")
+}
+
+func TestOrder_AdminSeesEverything(t *testing.T) {
+	order := testOrder()
+
+	masked := newTestMasker(t).Order("admin", order)
+
+	assert.Equal(t, order.Delivery, masked.Delivery)
+}
+
+func TestOrder_UnknownRoleUsesDefault(t *testing.T) {
+	m := newTestMasker(t)
+
+	assert.Equal(t, "+972*****00", m.Order("", testOrder()).Delivery.Phone)
+	assert.Equal(t, "+972*****00", m.Order("guest", testOrder()).Delivery.Phone)
+}
+
+func TestMaskHelpers(t *testing.T) {
+	assert.Equal(t, "****", maskPhone("1234"))
+	assert.Equal(t, "+799******99", maskPhone("+79999999999"))
+	assert.Equal(t, "*****", maskEmail("plain"))
+	assert.Equal(t, "", maskName(""))
+}
+
+func TestNew_Invalid(t *testing.T) {
+	_, err := New(config.Masking{DefaultRole: "support", Roles: map[string]map[string]string{"support": {"phone": "hidden"}}})
+	assert.Error(t, err)
+
+	_, err = New(config.Masking{DefaultRole: "support", Roles: map[string]map[string]string{"support": {"iban": ModeRedact}}})
+	assert.Error(t, err)
+
+	_, err = New(config.Masking{DefaultRole: "support", Roles: map[string]map[string]string{"admin": {}}})
+	assert.Error(t, err)
+}
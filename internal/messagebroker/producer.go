@@ -1,45 +1,254 @@
 package messagebroker
 
 import (
+	"L0/pkg/logger"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"github.com/segmentio/kafka-go"
 	"go.uber.org/zap"
+	"strings"
+	"time"
 )
 
+const (
+	HeaderContentType   = "content-type"
+	HeaderTraceID       = "trace-id"
+	HeaderSchemaVersion = "schema-version"
+
+	ContentTypeJSON      = "application/json"
+	DefaultSchemaVersion = "1"
+)
+
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// ProducerConfig описывает настройки kafka.Writer. Нулевые значения означают дефолты kafka-go.
+type ProducerConfig struct {
+	BatchSize     int
+	BatchBytes    int64
+	BatchTimeout  time.Duration
+	WriteTimeout  time.Duration
+	MaxAttempts   int
+	Compression   string
+	RequiredAcks  string
+	Balancer      string // round_robin (дефолт kafka-go), hash (по ключу заказа) или least_bytes
+	Async         bool
+	SchemaVersion string
+	// OnDelivery вызывается для каждого сообщения после попытки доставки (в том числе в async режиме).
+	OnDelivery func(report DeliveryReport)
+}
+
+type DeliveryReport struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       string
+	TraceID   string
+	Err       error
+}
+
+// Message - единица пакетной отправки для SendMessages.
+type Message struct {
+	Key     string
+	Value   interface{}
+	Headers map[string]string
+}
+
 type Producer struct {
-	writer *kafka.Writer
-	log    *zap.Logger
+	writer        Writer
+	schemaVersion string
+	log           *zap.Logger
+}
+
+func NewProducer(broker []string, topic string, cfg ProducerConfig, log *zap.Logger) (*Producer, error) {
+	log = log.Named("producer")
+
+	compression, err := parseCompression(cfg.Compression)
+	if err != nil {
+		log.Error("Invalid compression codec", zap.String("compression", cfg.Compression), zap.Error(err))
+		return nil, err
+	}
+	acks, err := parseRequiredAcks(cfg.RequiredAcks)
+	if err != nil {
+		log.Error("Invalid required acks", zap.String("required_acks", cfg.RequiredAcks), zap.Error(err))
+		return nil, err
+	}
+	balancer, err := parseBalancer(cfg.Balancer)
+	if err != nil {
+		log.Error("Invalid balancer", zap.String("balancer", cfg.Balancer), zap.Error(err))
+		return nil, err
+	}
+
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(broker...),
+		Topic:        topic,
+		Balancer:     balancer,
+		BatchSize:    cfg.BatchSize,
+		BatchBytes:   cfg.BatchBytes,
+		BatchTimeout: cfg.BatchTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		MaxAttempts:  cfg.MaxAttempts,
+		Compression:  compression,
+		RequiredAcks: acks,
+		Async:        cfg.Async,
+	}
+	if cfg.OnDelivery != nil {
+		writer.Completion = deliveryCompletion(cfg.OnDelivery)
+	} else if cfg.Async {
+		writer.Completion = func(messages []kafka.Message, err error) {
+			if err != nil {
+				log.Error("Failed to deliver messages", zap.Int("count", len(messages)), zap.Error(err))
+			}
+		}
+	}
+
+	return newProducer(writer, cfg.SchemaVersion, log), nil
 }
 
-func NewProducer(broker []string, topic string, log *zap.Logger) *Producer {
-	writer := kafka.Writer{
-		Addr:  kafka.TCP(broker...),
-		Topic: topic,
+func newProducer(writer Writer, schemaVersion string, log *zap.Logger) *Producer {
+	if schemaVersion == "" {
+		schemaVersion = DefaultSchemaVersion
 	}
-	return &Producer{writer: &writer, log: log.Named("producer")}
+	return &Producer{writer: writer, schemaVersion: schemaVersion, log: log}
 }
 
 func (p *Producer) SendMessage(ctx context.Context, key string, value interface{}) error {
-	p.log.Debug("Producer send message", zap.Any("value", value))
-	jsonValue, err := json.Marshal(value)
-	if err != nil {
-		p.log.Error("Failed to marshal value", zap.Any("value", value), zap.Error(err))
-		return fmt.Errorf("failed to marshal value: %w", err)
+	return p.SendMessages(ctx, []Message{{Key: key, Value: value}})
+}
+
+// SendMessages отправляет сообщения одним вызовом WriteMessages, kafka-go сам разбивает их на батчи.
+func (p *Producer) SendMessages(ctx context.Context, msgs []Message) error {
+	if len(msgs) == 0 {
+		return nil
 	}
-	message := &kafka.Message{
-		Key:   []byte(key),
-		Value: jsonValue,
+	p.log.Debug("Producer send messages", zap.Int("count", len(msgs)))
+
+	messages := make([]kafka.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		message, err := p.buildMessage(ctx, msg)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, message)
 	}
-	if err := p.writer.WriteMessages(ctx, *message); err != nil {
-		p.log.Error("Failed to write message", zap.Any("message", message), zap.Error(err))
-		return fmt.Errorf("failed to write message: %w", err)
+
+	if err := p.writer.WriteMessages(ctx, messages...); err != nil {
+		p.log.Error("Failed to write messages", zap.Int("count", len(messages)), zap.Error(err))
+		return fmt.Errorf("failed to write messages: %w", err)
 	}
-	p.log.Debug("Producer sent message", zap.Any("message", message))
+	p.log.Debug("Producer sent messages", zap.Int("count", len(messages)))
 	return nil
 }
 
+func (p *Producer) buildMessage(ctx context.Context, msg Message) (kafka.Message, error) {
+	jsonValue, err := json.Marshal(msg.Value)
+	if err != nil {
+		p.log.Error("Failed to marshal value", zap.Any("value", msg.Value), zap.Error(err))
+		return kafka.Message{}, fmt.Errorf("failed to marshal value: %w", err)
+	}
+
+	// trace-id - тот же correlation id, что request_id в логах: его выставляет middleware или консьюмер.
+	traceID := logger.RequestID(ctx)
+	if traceID == "" {
+		traceID = newTraceID()
+	}
+	headers := []kafka.Header{
+		{Key: HeaderContentType, Value: []byte(ContentTypeJSON)},
+		{Key: HeaderTraceID, Value: []byte(traceID)},
+		{Key: HeaderSchemaVersion, Value: []byte(p.schemaVersion)},
+	}
+	for k, v := range msg.Headers {
+		headers = append(headers, kafka.Header{Key: k, Value: []byte(v)})
+	}
+
+	return kafka.Message{
+		Key:     []byte(msg.Key),
+		Value:   jsonValue,
+		Headers: headers,
+	}, nil
+}
+
 func (p *Producer) Close() error {
 	return p.writer.Close()
 }
+
+func deliveryCompletion(onDelivery func(DeliveryReport)) func([]kafka.Message, error) {
+	return func(messages []kafka.Message, err error) {
+		for _, msg := range messages {
+			onDelivery(DeliveryReport{
+				Topic:     msg.Topic,
+				Partition: msg.Partition,
+				Offset:    msg.Offset,
+				Key:       string(msg.Key),
+				TraceID:   HeaderValue(msg.Headers, HeaderTraceID),
+				Err:       err,
+			})
+		}
+	}
+}
+
+// HeaderValue возвращает значение заголовка сообщения или пустую строку.
+func HeaderValue(headers []kafka.Header, key string) string {
+	for _, h := range headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func newTraceID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+func parseCompression(codec string) (kafka.Compression, error) {
+	switch strings.ToLower(codec) {
+	case "", "none":
+		return 0, nil
+	case "gzip":
+		return kafka.Gzip, nil
+	case "snappy":
+		return kafka.Snappy, nil
+	case "lz4":
+		return kafka.Lz4, nil
+	case "zstd":
+		return kafka.Zstd, nil
+	default:
+		return 0, fmt.Errorf("unknown compression codec %q", codec)
+	}
+}
+
+func parseRequiredAcks(acks string) (kafka.RequiredAcks, error) {
+	switch strings.ToLower(acks) {
+	case "", "one", "leader":
+		return kafka.RequireOne, nil
+	case "none":
+		return kafka.RequireNone, nil
+	case "all":
+		return kafka.RequireAll, nil
+	default:
+		return 0, fmt.Errorf("unknown required acks %q", acks)
+	}
+}
+
+func parseBalancer(balancer string) (kafka.Balancer, error) {
+	switch strings.ToLower(balancer) {
+	case "", "round_robin":
+		return &kafka.RoundRobin{}, nil
+	case "hash":
+		return &kafka.Hash{}, nil
+	case "least_bytes":
+		return &kafka.LeastBytes{}, nil
+	default:
+		return nil, fmt.Errorf("unknown balancer %q", balancer)
+	}
+}
@@ -0,0 +1,106 @@
+package messagebroker
+
+import (
+	"L0/pkg/logger"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// mockWriter реализует интерфейс Writer
+type mockWriter struct {
+	written []kafka.Message
+	calls   int
+	err     error
+}
+
+func (m *mockWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	m.calls++
+	if m.err != nil {
+		return m.err
+	}
+	m.written = append(m.written, msgs...)
+	return nil
+}
+
+func (m *mockWriter) Close() error {
+	return nil
+}
+
+func TestProducer_SendMessage_Headers(t *testing.T) {
+	writer := &mockWriter{}
+	producer := newProducer(writer, "2", zap.NewNop())
+
+	ctx := logger.WithRequestID(context.Background(), "trace-123")
+	err := producer.SendMessage(ctx, "key", map[string]string{"a": "b"})
+
+	assert.NoError(t, err)
+	assert.Len(t, writer.written, 1)
+	msg := writer.written[0]
+	assert.Equal(t, []byte("key"), msg.Key)
+	assert.JSONEq(t, `{"a":"b"}`, string(msg.Value))
+	assert.Equal(t, ContentTypeJSON, HeaderValue(msg.Headers, HeaderContentType))
+	assert.Equal(t, "trace-123", HeaderValue(msg.Headers, HeaderTraceID))
+	assert.Equal(t, "2", HeaderValue(msg.Headers, HeaderSchemaVersion))
+}
+
+func TestProducer_SendMessage_GeneratesTraceID(t *testing.T) {
+	writer := &mockWriter{}
+	producer := newProducer(writer, "", zap.NewNop())
+
+	err := producer.SendMessage(context.Background(), "key", "value")
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, HeaderValue(writer.written[0].Headers, HeaderTraceID))
+	assert.Equal(t, DefaultSchemaVersion, HeaderValue(writer.written[0].Headers, HeaderSchemaVersion))
+}
+
+func TestProducer_SendMessages_SingleWrite(t *testing.T) {
+	writer := &mockWriter{}
+	producer := newProducer(writer, "", zap.NewNop())
+
+	err := producer.SendMessages(context.Background(), []Message{
+		{Key: "1", Value: 1},
+		{Key: "2", Value: 2, Headers: map[string]string{"source": "test"}},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, writer.calls)
+	assert.Len(t, writer.written, 2)
+	assert.Equal(t, "test", HeaderValue(writer.written[1].Headers, "source"))
+}
+
+func TestProducer_SendMessages_Error(t *testing.T) {
+	writer := &mockWriter{err: errors.New("write error")}
+	producer := newProducer(writer, "", zap.NewNop())
+
+	err := producer.SendMessages(context.Background(), []Message{{Key: "1", Value: 1}})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "write error")
+}
+
+func TestDeliveryCompletion(t *testing.T) {
+	var reports []DeliveryReport
+	completion := deliveryCompletion(func(r DeliveryReport) { reports = append(reports, r) })
+
+	completion([]kafka.Message{
+		{Key: []byte("1"), Offset: 10, Headers: []kafka.Header{{Key: HeaderTraceID, Value: []byte("t1")}}},
+		{Key: []byte("2"), Offset: 11},
+	}, errors.New("broker down"))
+
+	assert.Len(t, reports, 2)
+	assert.Equal(t, "1", reports[0].Key)
+	assert.Equal(t, "t1", reports[0].TraceID)
+	assert.EqualError(t, reports[1].Err, "broker down")
+}
+
+func TestNewProducer_InvalidCompression(t *testing.T) {
+	_, err := NewProducer([]string{"localhost:9092"}, "test", ProducerConfig{Compression: "brotli"}, zap.NewNop())
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,214 @@
+package messagebroker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+	"time"
+)
+
+// replayIdleTimeout - сколько ждать следующего сообщения диапазона. Offset'ы перед концом диапазона
+// могут не существовать (компакция, маркеры транзакций), и тогда чтение закончится по этому таймауту.
+const replayIdleTimeout = 10 * time.Second
+
+// replayReader - Reader с отставанием от high-water mark партиции, его реализует *kafka.Reader.
+type replayReader interface {
+	Reader
+	Lag() int64
+}
+
+// ReplayRange задаёт границы повторного чтения топика. Границы по offset и по времени
+// можно комбинировать, в этом случае берётся более узкий диапазон. To* - исключающие границы.
+type ReplayRange struct {
+	Partitions []int
+	FromOffset int64
+	ToOffset   int64
+	From       time.Time
+	To         time.Time
+}
+
+type ReplayStats struct {
+	Partition int
+	Start     int64
+	End       int64
+	Read      int
+	Failed    int
+}
+
+// ReplayHandler обрабатывает одно сообщение. Ошибка обработки не прерывает replay.
+type ReplayHandler func(ctx context.Context, msg *kafka.Message) error
+
+// Replayer читает диапазон топика без consumer group, поэтому закоммиченные
+// offset'ы основного консьюмера не затрагиваются.
+type Replayer struct {
+	brokers []string
+	topic   string
+	log     *zap.Logger
+}
+
+func NewReplayer(brokers []string, topic string, log *zap.Logger) *Replayer {
+	return &Replayer{brokers: brokers, topic: topic, log: log.Named("replayer")}
+}
+
+func (r *Replayer) Replay(ctx context.Context, rng ReplayRange, handle ReplayHandler) ([]ReplayStats, error) {
+	if len(r.brokers) == 0 {
+		return nil, errors.New("no brokers configured")
+	}
+	partitions := rng.Partitions
+	if len(partitions) == 0 {
+		var err error
+		partitions, err = r.partitions(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	stats := make([]ReplayStats, 0, len(partitions))
+	for _, partition := range partitions {
+		st, err := r.replayPartition(ctx, partition, rng, handle)
+		stats = append(stats, st)
+		if err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+func (r *Replayer) partitions(ctx context.Context) ([]int, error) {
+	conn, err := kafka.DialContext(ctx, "tcp", r.brokers[0])
+	if err != nil {
+		r.log.Error("Failed to dial broker", zap.Error(err))
+		return nil, fmt.Errorf("failed to dial broker: %w", err)
+	}
+	defer conn.Close()
+
+	parts, err := conn.ReadPartitions(r.topic)
+	if err != nil {
+		r.log.Error("Failed to read partitions", zap.String("topic", r.topic), zap.Error(err))
+		return nil, fmt.Errorf("failed to read partitions: %w", err)
+	}
+	result := make([]int, 0, len(parts))
+	for _, p := range parts {
+		result = append(result, p.ID)
+	}
+	return result, nil
+}
+
+func (r *Replayer) replayPartition(ctx context.Context, partition int, rng ReplayRange, handle ReplayHandler) (ReplayStats, error) {
+	stats := ReplayStats{Partition: partition}
+	log := r.log.With(zap.String("topic", r.topic), zap.Int("partition", partition))
+
+	b, err := r.partitionBounds(ctx, partition, rng)
+	if err != nil {
+		log.Error("Failed to resolve partition bounds", zap.Error(err))
+		return stats, fmt.Errorf("failed to resolve bounds of partition %d: %w", partition, err)
+	}
+	stats.Start, stats.End = resolveBounds(b, rng)
+	if stats.Start >= stats.End {
+		log.Info("Nothing to replay", zap.Int64("start", stats.Start), zap.Int64("end", stats.End))
+		return stats, nil
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   r.brokers,
+		Topic:     r.topic,
+		Partition: partition,
+	})
+	defer reader.Close()
+	if err := reader.SetOffset(stats.Start); err != nil {
+		return stats, fmt.Errorf("failed to set offset: %w", err)
+	}
+
+	log.Info("Replaying partition", zap.Int64("start", stats.Start), zap.Int64("end", stats.End))
+	read, failed, err := replayMessages(ctx, reader, stats.End, replayIdleTimeout, handle, log)
+	stats.Read, stats.Failed = read, failed
+	return stats, err
+}
+
+type partitionBounds struct {
+	first, last    int64
+	fromAt, toAt   int64
+	hasFrom, hasTo bool
+}
+
+func (r *Replayer) partitionBounds(ctx context.Context, partition int, rng ReplayRange) (partitionBounds, error) {
+	var b partitionBounds
+	conn, err := kafka.DialLeader(ctx, "tcp", r.brokers[0], r.topic, partition)
+	if err != nil {
+		return b, err
+	}
+	defer conn.Close()
+
+	if b.first, b.last, err = conn.ReadOffsets(); err != nil {
+		return b, err
+	}
+	if !rng.From.IsZero() {
+		if b.fromAt, err = conn.ReadOffset(rng.From); err != nil {
+			return b, err
+		}
+		b.hasFrom = true
+	}
+	if !rng.To.IsZero() {
+		if b.toAt, err = conn.ReadOffset(rng.To); err != nil {
+			return b, err
+		}
+		b.hasTo = true
+	}
+	return b, nil
+}
+
+// resolveBounds сужает [first, last) партиции до запрошенного диапазона.
+// Брокер возвращает отрицательный offset, если после метки времени сообщений нет.
+func resolveBounds(b partitionBounds, rng ReplayRange) (int64, int64) {
+	start, end := b.first, b.last
+	if rng.FromOffset > start {
+		start = rng.FromOffset
+	}
+	if rng.ToOffset > 0 && rng.ToOffset < end {
+		end = rng.ToOffset
+	}
+	if b.hasFrom {
+		if b.fromAt < 0 {
+			start = end
+		} else if b.fromAt > start {
+			start = b.fromAt
+		}
+	}
+	if b.hasTo && b.toAt >= 0 && b.toAt < end {
+		end = b.toAt
+	}
+	return start, end
+}
+
+// replayMessages читает сообщения до end (не больше high-water mark, взятого до начала чтения).
+// Чтение заканчивается на сообщении с offset'ом end-1 или дальше, когда прочитано всё, что было
+// в партиции (Lag == 0), или когда за idle не пришло ни одного сообщения.
+func replayMessages(ctx context.Context, reader replayReader, end int64, idle time.Duration, handle ReplayHandler, log *zap.Logger) (int, int, error) {
+	var read, failed int
+	for {
+		readCtx, cancel := context.WithTimeout(ctx, idle)
+		msg, err := reader.ReadMessage(readCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				log.Info("No more messages in range", zap.Int64("end", end), zap.Duration("idle", idle))
+				return read, failed, nil
+			}
+			log.Error("Error reading message", zap.Error(err))
+			return read, failed, fmt.Errorf("error reading message: %w", err)
+		}
+		if msg.Offset >= end {
+			return read, failed, nil
+		}
+		read++
+		if err := handle(ctx, &msg); err != nil {
+			failed++
+			log.Warn("Failed to replay message", zap.Int64("offset", msg.Offset), zap.Error(err))
+		}
+		if msg.Offset+1 >= end || reader.Lag() == 0 {
+			return read, failed, nil
+		}
+	}
+}
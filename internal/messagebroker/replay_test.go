@@ -0,0 +1,120 @@
+package messagebroker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// sequenceReader отдаёт сообщения с последовательными offset'ами до last включительно, дальше ждёт
+// новых, как kafka.Reader. Lag считается от high-water mark hwm.
+type sequenceReader struct {
+	next int64
+	last int64
+	hwm  int64
+}
+
+func (s *sequenceReader) ReadMessage(ctx context.Context) (kafka.Message, error) {
+	if s.next > s.last {
+		<-ctx.Done()
+		return kafka.Message{}, ctx.Err()
+	}
+	msg := kafka.Message{Offset: s.next}
+	s.next++
+	return msg, nil
+}
+
+func (s *sequenceReader) Lag() int64 {
+	return s.hwm - s.next
+}
+
+func (s *sequenceReader) Close() error {
+	return nil
+}
+
+// failingReader возвращает ошибку на каждое чтение
+type failingReader struct {
+	mockReader
+}
+
+func (f *failingReader) Lag() int64 {
+	return -1
+}
+
+func TestResolveBounds(t *testing.T) {
+	tests := []struct {
+		name       string
+		bounds     partitionBounds
+		rng        ReplayRange
+		start, end int64
+	}{
+		{"whole partition", partitionBounds{first: 5, last: 20}, ReplayRange{}, 5, 20},
+		{"offsets", partitionBounds{first: 5, last: 20}, ReplayRange{FromOffset: 10, ToOffset: 15}, 10, 15},
+		{"offsets beyond partition", partitionBounds{first: 5, last: 20}, ReplayRange{FromOffset: 1, ToOffset: 100}, 5, 20},
+		{"timestamps", partitionBounds{first: 0, last: 20, fromAt: 7, hasFrom: true, toAt: 12, hasTo: true}, ReplayRange{}, 7, 12},
+		{"narrowest wins", partitionBounds{first: 0, last: 20, fromAt: 7, hasFrom: true}, ReplayRange{FromOffset: 9}, 9, 20},
+		{"no messages after time", partitionBounds{first: 0, last: 20, fromAt: -1, hasFrom: true}, ReplayRange{}, 20, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := resolveBounds(tt.bounds, tt.rng)
+			assert.Equal(t, tt.start, start)
+			assert.Equal(t, tt.end, end)
+		})
+	}
+}
+
+func TestReplayMessages_StopsAtEnd(t *testing.T) {
+	reader := &sequenceReader{next: 3, last: 100, hwm: 100}
+	var offsets []int64
+	handler := func(ctx context.Context, msg *kafka.Message) error {
+		offsets = append(offsets, msg.Offset)
+		if msg.Offset == 4 {
+			return errors.New("invalid order")
+		}
+		return nil
+	}
+
+	read, failed, err := replayMessages(context.Background(), reader, 6, time.Second, handler, zap.NewNop())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, read)
+	assert.Equal(t, 1, failed)
+	assert.Equal(t, []int64{3, 4, 5}, offsets)
+}
+
+func TestReplayMessages_ReadError(t *testing.T) {
+	reader := &failingReader{mockReader{err: errors.New("read error")}}
+
+	_, _, err := replayMessages(context.Background(), reader, 10, time.Second, func(context.Context, *kafka.Message) error { return nil }, zap.NewNop())
+
+	assert.Error(t, err)
+}
+
+func TestReplayMessages_StopsAtHighWaterMark(t *testing.T) {
+	// Последний offset диапазона 9, но в партиции сообщения только до 6.
+	reader := &sequenceReader{next: 3, last: 6, hwm: 7}
+
+	read, _, err := replayMessages(context.Background(), reader, 10, time.Hour, func(context.Context, *kafka.Message) error { return nil }, zap.NewNop())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, read)
+}
+
+func TestReplayMessages_GapAtEnd(t *testing.T) {
+	// Offset 9 - маркер транзакции: high-water mark 10, но сообщения есть только до 8.
+	reader := &sequenceReader{next: 3, last: 8, hwm: 10}
+
+	start := time.Now()
+	read, failed, err := replayMessages(context.Background(), reader, 10, 20*time.Millisecond, func(context.Context, *kafka.Message) error { return nil }, zap.NewNop())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 6, read)
+	assert.Zero(t, failed)
+	assert.Less(t, time.Since(start), time.Second)
+}
@@ -1,12 +1,16 @@
 package models
 
 import (
+	"encoding/base64"
 	"errors"
+	"strconv"
+	"strings"
 	"time"
 )
 
 var (
-	OrderNotFoundError = errors.New("order not found")
+	OrderNotFoundError   = errors.New("order not found")
+	WebhookNotFoundError = errors.New("webhook not found")
 )
 
 type Order struct {
@@ -26,6 +30,104 @@ type Order struct {
 	OofShard          string    `json:"oof_shard"`
 }
 
+// OrderSummary - краткие данные заказа для потока новых заказов, без контактов доставки.
+type OrderSummary struct {
+	OrderUID        string    `json:"order_uid"`
+	TrackNumber     string    `json:"track_number"`
+	CustomerID      string    `json:"customer_id"`
+	DeliveryService string    `json:"delivery_service"`
+	Amount          int       `json:"amount"`
+	Currency        string    `json:"currency"`
+	ItemsCount      int       `json:"items_count"`
+	DateCreated     time.Time `json:"date_created"`
+}
+
+func (o *Order) Summary() OrderSummary {
+	return OrderSummary{
+		OrderUID:        o.OrderUID,
+		TrackNumber:     o.TrackNumber,
+		CustomerID:      o.CustomerID,
+		DeliveryService: o.DeliveryService,
+		Amount:          o.Payment.Amount,
+		Currency:        o.Payment.Currency,
+		ItemsCount:      len(o.Items),
+		DateCreated:     o.DateCreated,
+	}
+}
+
+// OrderView - какую часть заказа читать: без товаров или только страницу товаров в порядке добавления.
+type OrderView struct {
+	SkipItems   bool
+	ItemsOffset int
+	// ItemsLimit - размер страницы товаров, 0 - все товары начиная с ItemsOffset.
+	ItemsLimit int
+}
+
+// Full сообщает, что нужен заказ целиком.
+func (v OrderView) Full() bool {
+	return !v.SkipItems && v.ItemsOffset == 0 && v.ItemsLimit == 0
+}
+
+// Page вырезает из заказа, прочитанного целиком, запрошенную часть. Исходный заказ не меняется.
+func (v OrderView) Page(order *Order) *OrderPage {
+	page := *order
+	if v.SkipItems {
+		page.Items = nil
+		return &OrderPage{Order: &page}
+	}
+	items := order.Items[min(v.ItemsOffset, len(order.Items)):]
+	hasMore := v.ItemsLimit > 0 && len(items) > v.ItemsLimit
+	if hasMore {
+		items = items[:v.ItemsLimit]
+	}
+	page.Items = append([]Item{}, items...)
+	return &OrderPage{Order: &page, HasMoreItems: hasMore}
+}
+
+// OrderPage - заказ, прочитанный по OrderView. HasMoreItems - после страницы товаров есть ещё.
+type OrderPage struct {
+	Order        *Order
+	HasMoreItems bool
+}
+
+// OrderKey однозначно определяет строку заказа в партиционированной таблице orders.
+type OrderKey struct {
+	OrderUID    string
+	DateCreated time.Time
+}
+
+// Cursor кодирует ключ в непрозрачный курсор списка заказов.
+func (k OrderKey) Cursor() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(k.DateCreated.UnixNano(), 10) + ":" + k.OrderUID))
+}
+
+// ParseOrderCursor разбирает курсор, выданный OrderKey.Cursor.
+func ParseOrderCursor(cursor string) (OrderKey, error) {
+	invalid := &ValidationError{Reason: "invalid cursor"}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return OrderKey{}, invalid
+	}
+	nanos, uid, ok := strings.Cut(string(raw), ":")
+	unix, err := strconv.ParseInt(nanos, 10, 64)
+	if !ok || err != nil || uid == "" {
+		return OrderKey{}, invalid
+	}
+	return OrderKey{OrderUID: uid, DateCreated: time.Unix(0, unix).UTC()}, nil
+}
+
+// OrderFilter - условия выборки списка заказов. Пустое поле не ограничивает выборку.
+type OrderFilter struct {
+	CustomerID      string
+	DeliveryService string
+}
+
+// OrderList - страница списка заказов от новых к старым. NextCursor пуст на последней странице.
+type OrderList struct {
+	Orders     []*Order
+	NextCursor string
+}
+
 type Delivery struct {
 	Name    string `json:"name"`
 	Phone   string `json:"phone"`
@@ -63,6 +165,106 @@ type Item struct {
 	Status      int    `json:"status"`
 }
 
-type ErrorResponse struct {
-	Error string `json:"error"`
+// ErasureResult - итог стирания персональных данных покупателя. Финансовые данные заказов сохраняются.
+type ErasureResult struct {
+	CustomerID     string    `json:"customer_id"`
+	OrdersAffected int       `json:"orders_affected"`
+	OrderUIDs      []string  `json:"order_uids"`
+	ErasedAt       time.Time `json:"erased_at"`
+}
+
+// EventOrderCreated - событие вебхука о сохранённом заказе.
+const EventOrderCreated = "order.created"
+
+// WebhookSubscription - подписка на исходящие вебхуки. Пустой Events и пустые фильтры означают "все".
+// Secret отдаётся только в ответе на создание подписки.
+type WebhookSubscription struct {
+	ID              int64     `json:"id"`
+	URL             string    `json:"url" example:"https://example.com/hooks/orders"`
+	Secret          string    `json:"secret,omitempty"`
+	Events          []string  `json:"events" example:"order.created"`
+	DeliveryService string    `json:"delivery_service,omitempty"`
+	CustomerID      string    `json:"customer_id,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// WebhookRequest - тело запроса на создание подписки. Без Secret секрет генерируется сервером.
+type WebhookRequest struct {
+	URL             string   `json:"url" example:"https://example.com/hooks/orders"`
+	Secret          string   `json:"secret,omitempty"`
+	Events          []string `json:"events,omitempty" example:"order.created"`
+	DeliveryService string   `json:"delivery_service,omitempty"`
+	CustomerID      string   `json:"customer_id,omitempty"`
+}
+
+// WebhookDelivery - одна попытка доставки вебхука.
+type WebhookDelivery struct {
+	ID             int64     `json:"id"`
+	SubscriptionID int64     `json:"subscription_id"`
+	EventID        string    `json:"event_id"`
+	Event          string    `json:"event"`
+	OrderUID       string    `json:"order_uid"`
+	Attempt        int       `json:"attempt"`
+	StatusCode     int       `json:"status_code,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	DurationMs     int       `json:"duration_ms"`
+	Success        bool      `json:"success"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// GraphQLRequest - тело запроса к /graphql.
+type GraphQLRequest struct {
+	Query         string                 `json:"query" example:"{ order(uid: \"b563feb7b2b84b6test\") { trackNumber payment { amount } } }"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// GraphQLResponse - ответ /graphql. Ошибки разбора, проверки и резолверов приходят в Errors со статусом 200.
+type GraphQLResponse struct {
+	Data   interface{}    `json:"data,omitempty"`
+	Errors []GraphQLError `json:"errors,omitempty"`
+}
+
+// GraphQLError - ошибка GraphQL. Extensions.code - тот же стабильный код, что в Problem.
+type GraphQLError struct {
+	Message    string                 `json:"message" example:"validation failed: first must be 1-100"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// Problem - тело ошибки HTTP API по RFC 7807, отдаётся с Content-Type application/problem+json.
+type Problem struct {
+	Type     string `json:"type" example:"about:blank"`
+	Title    string `json:"title" example:"Not Found"`
+	Status   int    `json:"status" example:"404"`
+	Detail   string `json:"detail,omitempty" example:"order not found"`
+	Instance string `json:"instance,omitempty" example:"/api/v1/orders/b563feb7b2b84b6test"`
+	// Code - стабильный машиночитаемый код ошибки.
+	Code      string `json:"code" example:"order_not_found"`
+	RequestID string `json:"request_id,omitempty" example:"4f1c2a9be07d4c1fa3c2a3e1d2b0c9f8"`
+}
+
+// ValidationError - некорректные входные данные запроса или заказа.
+type ValidationError struct {
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return "validation failed: " + e.Reason
+}
+
+// PoolStats - снимок состояния пула соединений PostgreSQL для мониторинга.
+type PoolStats struct {
+	MaxConns                int32  `json:"max_conns"`
+	TotalConns              int32  `json:"total_conns"`
+	IdleConns               int32  `json:"idle_conns"`
+	AcquiredConns           int32  `json:"acquired_conns"`
+	ConstructingConns       int32  `json:"constructing_conns"`
+	AcquireCount            int64  `json:"acquire_count"`
+	AcquireDuration         string `json:"acquire_duration"`
+	EmptyAcquireCount       int64  `json:"empty_acquire_count"`
+	CanceledAcquireCount    int64  `json:"canceled_acquire_count"`
+	NewConnsCount           int64  `json:"new_conns_count"`
+	MaxLifetimeDestroyCount int64  `json:"max_lifetime_destroy_count"`
+	MaxIdleDestroyCount     int64  `json:"max_idle_destroy_count"`
 }
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLimiter(t *testing.T) (*Limiter, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	mr.SetTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewLimiter(client), mr
+}
+
+func TestAllow_BurstThenReject(t *testing.T) {
+	limiter, _ := newTestLimiter(t)
+	ctx := context.Background()
+	limit := Limit{Rate: 1, Burst: 3}
+
+	for i := 2; i >= 0; i-- {
+		res, err := limiter.Allow(ctx, "client", limit)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, i, res.Remaining)
+	}
+
+	res, err := limiter.Allow(ctx, "client", limit)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 3, res.Limit)
+	assert.Equal(t, time.Second, res.RetryAfter)
+	assert.Equal(t, 3*time.Second, res.Reset)
+}
+
+func TestAllow_Refills(t *testing.T) {
+	limiter, mr := newTestLimiter(t)
+	ctx := context.Background()
+	limit := Limit{Rate: 2, Burst: 1}
+
+	res, err := limiter.Allow(ctx, "client", limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	res, err = limiter.Allow(ctx, "client", limit)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+
+	mr.SetTime(time.Date(2024, 1, 1, 0, 0, 0, int(500*time.Millisecond), time.UTC))
+	res, err = limiter.Allow(ctx, "client", limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
+
+func TestAllow_KeysAreIndependent(t *testing.T) {
+	limiter, _ := newTestLimiter(t)
+	ctx := context.Background()
+	limit := Limit{Rate: 1, Burst: 1}
+
+	res, err := limiter.Allow(ctx, "a", limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	res, err = limiter.Allow(ctx, "b", limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
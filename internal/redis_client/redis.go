@@ -1,6 +1,7 @@
 package redisClient
 
 import (
+	"L0/internal/fieldcrypt"
 	"L0/internal/models"
 	"context"
 	"encoding/json"
@@ -13,15 +14,18 @@ import (
 type RedisCmdable interface {
 	Set(ctx context.Context, key string, value any, expiration time.Duration) *redis.StatusCmd
 	Get(ctx context.Context, key string) *redis.StringCmd
+	Del(ctx context.Context, keys ...string) *redis.IntCmd
 	Close() error
 }
 
 type RedisClient struct {
 	client RedisCmdable
+	cipher *fieldcrypt.Cipher
 	log    *zap.Logger
 }
 
-func NewRedisClient(ctx context.Context, addr string, password string, db int, log *zap.Logger) (*RedisClient, error) {
+// NewRedisClient подключается к Redis. cipher может быть nil, тогда данные доставки кэшируются открыто.
+func NewRedisClient(ctx context.Context, addr string, password string, db int, cipher *fieldcrypt.Cipher, log *zap.Logger) (*RedisClient, error) {
 	client := redis.NewClient(&redis.Options{
 		Addr:     addr,
 		Password: password,
@@ -32,12 +36,19 @@ func NewRedisClient(ctx context.Context, addr string, password string, db int, l
 		log.Error("failed to connect to redis_client", zap.Error(err))
 		return nil, fmt.Errorf("failed to connect to redis_client: %w", err)
 	}
-	return &RedisClient{client: client, log: log}, nil
+	return &RedisClient{client: client, cipher: cipher, log: log}, nil
 }
 
 func (rc *RedisClient) SetOrder(ctx context.Context, order *models.Order, ttl time.Duration, key string) error {
 	rc.log.Debug("setting order", zap.String("key", key), zap.Duration("ttl", ttl))
-	jsonOrder, err := json.Marshal(order)
+	cached := *order
+	delivery, err := rc.cipher.EncryptDelivery(order.Delivery)
+	if err != nil {
+		rc.log.Error("failed to encrypt delivery", zap.Error(err))
+		return err
+	}
+	cached.Delivery = delivery
+	jsonOrder, err := json.Marshal(&cached)
 	if err != nil {
 		rc.log.Error("failed to marshal order", zap.Error(err))
 		return fmt.Errorf("failed to marshal order: %w", err)
@@ -57,9 +68,25 @@ func (rc *RedisClient) GetOrder(ctx context.Context, uid string, key string) (*m
 		rc.log.Error("failed to unmarshal order", zap.String("uid", uid), zap.Error(err))
 		return nil, fmt.Errorf("failed to unmarshal order: %w", err)
 	}
+	if err := rc.cipher.DecryptDelivery(&order.Delivery); err != nil {
+		rc.log.Error("failed to decrypt delivery", zap.String("uid", uid), zap.Error(err))
+		return nil, err
+	}
 	return &order, nil
 }
 
+// DeleteOrders удаляет заказы из кэша, отсутствующие ключи не считаются ошибкой.
+func (rc *RedisClient) DeleteOrders(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if err := rc.client.Del(ctx, keys...).Err(); err != nil {
+		rc.log.Error("failed to delete orders", zap.Int("keys", len(keys)), zap.Error(err))
+		return fmt.Errorf("failed to delete orders: %w", err)
+	}
+	return nil
+}
+
 func (rc *RedisClient) Close() {
 	if rc.client != nil {
 		rc.client.Close()
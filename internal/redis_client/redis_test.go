@@ -1,8 +1,11 @@
 package redisClient
 
 import (
+	"L0/internal/config"
+	"L0/internal/fieldcrypt"
 	"L0/internal/models"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"github.com/redis/go-redis/v9"
@@ -48,6 +51,17 @@ func (m *mockRedis) Get(ctx context.Context, key string) *redis.StringCmd {
 	return redis.NewStringResult(val, nil)
 }
 
+func (m *mockRedis) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	var deleted int64
+	for _, key := range keys {
+		if _, ok := m.data[key]; ok {
+			delete(m.data, key)
+			deleted++
+		}
+	}
+	return redis.NewIntResult(deleted, nil)
+}
+
 func (m *mockRedis) Close() error {
 	return nil
 }
@@ -100,6 +114,44 @@ func TestRedisClient_GetOrder_NotFound(t *testing.T) {
 	assert.Contains(t, err.Error(), "failed to get order")
 }
 
+func TestRedisClient_EncryptsDelivery(t *testing.T) {
+	cipher, err := fieldcrypt.New(config.Encryption{
+		Enabled:       true,
+		PrimaryKeyID:  "k1",
+		Keys:          map[string]string{"k1": base64.StdEncoding.EncodeToString(make([]byte, 32))},
+		BlindIndexKey: base64.StdEncoding.EncodeToString(make([]byte, 32)),
+	})
+	assert.NoError(t, err)
+	rc := newTestRedisClient()
+	rc.cipher = cipher
+	order := &models.Order{OrderUID: "123", Delivery: models.Delivery{Phone: "+9720000000", City: "Kiryat Mozkin"}}
+
+	err = rc.SetOrder(context.Background(), order, time.Minute, "order:123")
+
+	assert.NoError(t, err)
+	stored := rc.client.(*mockRedis).data["order:123"]
+	assert.NotContains(t, stored, "+9720000000")
+	assert.Contains(t, stored, "Kiryat Mozkin")
+	assert.Equal(t, "+9720000000", order.Delivery.Phone)
+
+	got, err := rc.GetOrder(context.Background(), "123", "order:123")
+	assert.NoError(t, err)
+	assert.Equal(t, order.Delivery, got.Delivery)
+}
+
+func TestRedisClient_DeleteOrders(t *testing.T) {
+	rc := newTestRedisClient()
+	mock := rc.client.(*mockRedis)
+	mock.data["order:1"] = "{}"
+	mock.data["order:2"] = "{}"
+
+	err := rc.DeleteOrders(context.Background(), "order:1", "order:missing")
+
+	assert.NoError(t, err)
+	assert.NotContains(t, mock.data, "order:1")
+	assert.Contains(t, mock.data, "order:2")
+}
+
 func TestRedisClient_Close(t *testing.T) {
 	rc := newTestRedisClient()
 	rc.Close()
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"L0/internal/models"
+	"L0/pkg/logger"
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	archivableDaysQuery = `
+        SELECT DISTINCT date_trunc('day', date_created AT TIME ZONE 'UTC') AT TIME ZONE 'UTC' AS day
+        FROM orders
+        WHERE date_created < $1
+        ORDER BY day
+    `
+	ordersByDateQueryGet = orderSelect + `
+        WHERE o.date_created >= $1 AND o.date_created < $2
+        ORDER BY o.date_created, o.order_uid`
+	deleteOrdersQuery = `
+        DELETE FROM orders
+        WHERE (order_uid, date_created) IN (
+            SELECT * FROM unnest($1::text[], $2::timestamptz[])
+        )
+    `
+)
+
+// ArchivableDays возвращает дни (UTC), в которых есть заказы старше before.
+// Запросы архивации идут в primary, чтобы удаление опиралось на актуальные данные.
+func (r *Repository) ArchivableDays(ctx context.Context, before time.Time) ([]time.Time, error) {
+	log := logger.FromContext(ctx, r.log)
+	rows, err := r.db.Query(ctx, archivableDaysQuery, before)
+	if err != nil {
+		log.Error("Error getting archivable days", zap.Error(err))
+		return nil, fmt.Errorf("error getting archivable days: %w", err)
+	}
+	defer rows.Close()
+
+	var days []time.Time
+	for rows.Next() {
+		var day time.Time
+		if err := rows.Scan(&day); err != nil {
+			return nil, fmt.Errorf("failed to scan day: %w", err)
+		}
+		days = append(days, day.UTC())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating days: %w", err)
+	}
+	return days, nil
+}
+
+// StreamOrders построчно передаёт в fn заказы с date_created в [from, to), не загружая их в память целиком.
+// Данные доставки не расшифровываются: в архив попадает тот же шифртекст, что хранится в таблице.
+// Таймаут чтения не применяется: выгрузка дня может быть долгой, её ограничивает ctx вызывающего.
+func (r *Repository) StreamOrders(ctx context.Context, from time.Time, to time.Time, fn func(order *models.Order) error) error {
+	log := logger.FromContext(ctx, r.log)
+	rows, err := r.db.Query(ctx, ordersByDateQueryGet, from, to)
+	if err != nil {
+		log.Error("Error streaming orders", zap.Error(err))
+		return fmt.Errorf("error streaming orders: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan order: %w", err)
+		}
+		if err := fn(order); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating orders: %w", err)
+	}
+	return nil
+}
+
+// RestoreOrder сохраняет заказ из архива. Данные доставки в архиве зашифрованы ключом, действовавшим
+// при записи, поэтому они расшифровываются и сохраняются заново: с primary ключом и слепыми индексами.
+// Ключи, которыми зашифрованы архивы, нужно держать в конфиге, пока архивы могут понадобиться.
+// Если данные покупателя стирались после создания заказа, SaveOrder сохранит доставку обезличенной.
+func (r *Repository) RestoreOrder(ctx context.Context, order *models.Order) error {
+	if err := r.decrypt(order); err != nil {
+		return err
+	}
+	return r.SaveOrder(ctx, order)
+}
+
+// DeleteOrders удаляет заказы по ключам вместе с зависимыми строками (ON DELETE CASCADE).
+func (r *Repository) DeleteOrders(ctx context.Context, keys []models.OrderKey) (int64, error) {
+	log := logger.FromContext(ctx, r.log)
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	ctx, cancel := withTimeout(ctx, r.writeTimeout)
+	defer cancel()
+
+	uids := make([]string, len(keys))
+	dates := make([]time.Time, len(keys))
+	for i, key := range keys {
+		uids[i] = key.OrderUID
+		dates[i] = key.DateCreated
+	}
+	tag, err := r.db.Exec(ctx, deleteOrdersQuery, uids, dates)
+	if err != nil {
+		log.Error("Error deleting orders", zap.Int("count", len(keys)), zap.Error(err))
+		return 0, fmt.Errorf("error deleting orders: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
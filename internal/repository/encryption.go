@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"L0/internal/models"
+	"L0/pkg/logger"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	// Выбираются доставки, где хотя бы одно непустое поле не зашифровано primary ключом ($1 - префикс)
+	// или не посчитан слепой индекс. Строки идут по ключу (order_uid, date_created) после $2, $3:
+	// строка, которую не удаётся исправить (телефон без цифр не даёт слепого индекса), не выбирается снова.
+	staleDeliveriesQuery = `
+        SELECT order_uid, date_created, name, phone, address, COALESCE(email, '')
+        FROM deliveries
+        WHERE (order_uid, date_created) > ($2, $3)
+          AND ((name <> '' AND NOT starts_with(name, $1))
+           OR (phone <> '' AND (NOT starts_with(phone, $1) OR phone_bidx IS NULL))
+           OR (address <> '' AND NOT starts_with(address, $1))
+           OR (COALESCE(email, '') <> '' AND (NOT starts_with(email, $1) OR email_bidx IS NULL)))
+        ORDER BY order_uid, date_created
+        LIMIT $4
+    `
+	updateDeliveryQuery = `
+        UPDATE deliveries
+        SET name = $3, phone = $4, address = $5, email = $6, phone_bidx = $7, email_bidx = $8
+        WHERE order_uid = $1 AND date_created = $2
+    `
+)
+
+// RotateDeliveryEncryption перешифровывает primary ключом данные доставки, записанные открыто
+// или старыми ключами, и досчитывает слепые индексы. Работает пачками по batchSize строк,
+// каждая пачка в своей транзакции, и проходит таблицу один раз. Возвращает число обновлённых строк.
+func (r *Repository) RotateDeliveryEncryption(ctx context.Context, batchSize int) (int, error) {
+	log := logger.FromContext(ctx, r.log)
+	if r.cipher == nil {
+		return 0, fmt.Errorf("encryption is disabled")
+	}
+	prefix := "enc:v1:" + r.cipher.PrimaryKeyID() + ":"
+	total := 0
+	var after models.OrderKey
+	for {
+		updated, last, err := r.rotateBatch(ctx, prefix, after, batchSize)
+		total += updated
+		if err != nil {
+			return total, err
+		}
+		if updated == 0 {
+			return total, nil
+		}
+		log.Info("Rotated delivery encryption batch", zap.Int("rows", updated), zap.Int("total", total))
+		after = last
+	}
+}
+
+type staleDelivery struct {
+	orderUID    string
+	dateCreated time.Time
+	delivery    models.Delivery
+}
+
+// rotateBatch обновляет до batchSize строк после ключа after и возвращает ключ последней из них.
+func (r *Repository) rotateBatch(ctx context.Context, prefix string, after models.OrderKey, batchSize int) (int, models.OrderKey, error) {
+	log := logger.FromContext(ctx, r.log)
+	ctx, cancel := withTimeout(ctx, r.writeTimeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		log.Error("Error begin transaction", zap.Error(err))
+		return 0, after, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, staleDeliveriesQuery, prefix, after.OrderUID, after.DateCreated, batchSize)
+	if err != nil {
+		log.Error("Error selecting deliveries to rotate", zap.Error(err))
+		return 0, after, fmt.Errorf("failed to select deliveries: %w", err)
+	}
+	stale, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (staleDelivery, error) {
+		var s staleDelivery
+		err := row.Scan(&s.orderUID, &s.dateCreated, &s.delivery.Name, &s.delivery.Phone, &s.delivery.Address, &s.delivery.Email)
+		return s, err
+	})
+	if err != nil {
+		return 0, after, fmt.Errorf("failed to scan deliveries: %w", err)
+	}
+
+	for _, s := range stale {
+		if err := r.cipher.DecryptDelivery(&s.delivery); err != nil {
+			log.Error("Error decrypting delivery", zap.String("order_uid", s.orderUID), zap.Error(err))
+			return 0, after, fmt.Errorf("order %s: %w", s.orderUID, err)
+		}
+		encrypted, err := r.cipher.EncryptDelivery(s.delivery)
+		if err != nil {
+			return 0, after, err
+		}
+		_, err = tx.Exec(ctx, updateDeliveryQuery, s.orderUID, s.dateCreated,
+			encrypted.Name, encrypted.Phone, encrypted.Address, encrypted.Email,
+			nullIfEmpty(r.cipher.BlindIndex(s.delivery.Phone)),
+			nullIfEmpty(r.cipher.BlindIndex(s.delivery.Email)))
+		if err != nil {
+			log.Error("Error updating delivery", zap.String("order_uid", s.orderUID), zap.Error(err))
+			return 0, after, fmt.Errorf("failed to update delivery: %w", err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, after, fmt.Errorf("failed to commit rotation: %w", err)
+	}
+	if len(stale) == 0 {
+		return 0, after, nil
+	}
+	last := stale[len(stale)-1]
+	return len(stale), models.OrderKey{OrderUID: last.orderUID, DateCreated: last.dateCreated}, nil
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"L0/internal/config"
+	"L0/internal/fieldcrypt"
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_RotateDeliveryEncryption(t *testing.T) {
+	storage := testStorage(t)
+	ctx := context.Background()
+	created := time.Now().UTC().Truncate(time.Second)
+	plain := storage.NewRepository(nil)
+	for _, uid := range []string{"uid-rotate-1", "uid-rotate-2", "uid-rotate-3"} {
+		require.NoError(t, plain.SaveOrder(ctx, testOrder(uid, created)))
+	}
+	// Телефон без цифр не даёт слепого индекса, и строка остаётся "недосчитанной"
+	_, err := plain.db.Exec(ctx, `UPDATE deliveries SET phone = 'n/a' WHERE order_uid = 'uid-rotate-2'`)
+	require.NoError(t, err)
+
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+	cipher, err := fieldcrypt.New(config.Encryption{Enabled: true, PrimaryKeyID: "k_1",
+		Keys: map[string]string{"k_1": key}, BlindIndexKey: key})
+	require.NoError(t, err)
+	repo := storage.NewRepository(cipher)
+
+	rotated, err := repo.RotateDeliveryEncryption(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, rotated)
+
+	rotated, err = repo.RotateDeliveryEncryption(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, rotated, "снова выбирается только строка без слепого индекса")
+
+	var name string
+	require.NoError(t, repo.db.QueryRow(ctx, `SELECT name FROM deliveries WHERE order_uid = 'uid-rotate-1'`).Scan(&name))
+	assert.True(t, strings.HasPrefix(name, "enc:v1:k_1:"))
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"L0/internal/models"
+	"L0/pkg/logger"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	// Стираются контакты и адрес, город и регион остаются для статистики доставки.
+	erasePIIQuery = `
+        UPDATE deliveries d
+        SET name = '', phone = '', zip = '', address = '', email = '', phone_bidx = NULL, email_bidx = NULL
+        FROM orders o
+        WHERE o.order_uid = d.order_uid AND o.date_created = d.date_created AND o.customer_id = $1
+        RETURNING d.order_uid
+    `
+	erasureAuditQuery = `
+        INSERT INTO pii_erasures (customer_id, orders_affected, source)
+        VALUES ($1, $2, $3)
+        RETURNING erased_at
+    `
+	erasedSinceQuery = `
+        SELECT EXISTS (SELECT 1 FROM pii_erasures WHERE customer_id = $1 AND erased_at >= $2)
+    `
+)
+
+// ErasePII обезличивает данные доставки во всех заказах покупателя и записывает факт стирания в pii_erasures
+// в одной транзакции. source - откуда пришёл запрос (api, cli).
+func (r *Repository) ErasePII(ctx context.Context, customerID string, source string) (*models.ErasureResult, error) {
+	log := logger.FromContext(ctx, r.log)
+	ctx, cancel := withTimeout(ctx, r.writeTimeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		log.Error("Error begin transaction", zap.Error(err))
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, erasePIIQuery, customerID)
+	if err != nil {
+		log.Error("Error erasing delivery data", zap.String("customer_id", customerID), zap.Error(err))
+		return nil, fmt.Errorf("failed to erase delivery data: %w", err)
+	}
+	uids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		log.Error("Error erasing delivery data", zap.String("customer_id", customerID), zap.Error(err))
+		return nil, fmt.Errorf("failed to erase delivery data: %w", err)
+	}
+
+	result := &models.ErasureResult{CustomerID: customerID, OrdersAffected: len(uids), OrderUIDs: uids}
+	if err := tx.QueryRow(ctx, erasureAuditQuery, customerID, len(uids), source).Scan(&result.ErasedAt); err != nil {
+		log.Error("Error recording erasure", zap.String("customer_id", customerID), zap.Error(err))
+		return nil, fmt.Errorf("failed to record erasure: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit erasure: %w", err)
+	}
+	log.Info("Erased customer PII", zap.String("customer_id", customerID), zap.Int("orders", len(uids)), zap.String("source", source))
+	return result, nil
+}
+
+// erasedSince сообщает, стирались ли данные покупателя после since. Проверяется в транзакции SaveOrder:
+// повторно доставленное сообщение или файл архива не должны вернуть стёртые данные.
+func erasedSince(ctx context.Context, db querier, customerID string, since time.Time) (bool, error) {
+	var erased bool
+	if err := db.QueryRow(ctx, erasedSinceQuery, customerID, since).Scan(&erased); err != nil {
+		return false, fmt.Errorf("failed to check erasures: %w", err)
+	}
+	return erased, nil
+}
+
+// eraseDelivery обезличивает доставку так же, как erasePIIQuery.
+func eraseDelivery(delivery *models.Delivery) {
+	delivery.Name = ""
+	delivery.Phone = ""
+	delivery.Zip = ""
+	delivery.Address = ""
+	delivery.Email = ""
+}
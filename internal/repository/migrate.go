@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"L0/migrations"
+	"errors"
+	"fmt"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"go.uber.org/zap"
+	"io/fs"
+)
+
+var ErrSchemaVersionMismatch = errors.New("schema version mismatch")
+
+type Migrator struct {
+	m        *migrate.Migrate
+	expected uint
+	log      *zap.Logger
+}
+
+// ConnString собирает DSN PostgreSQL из параметров конфига.
+func ConnString(user string, password string, host string, port string, dbname string, sslmode string) string {
+	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s", user, password, host, port, dbname, sslmode)
+}
+
+// NewMigrator использует миграции, встроенные в бинарник (пакет migrations).
+func NewMigrator(connStr string, log *zap.Logger) (*Migrator, error) {
+	log = log.Named("migrator")
+	expected, err := latestVersion(migrations.FS)
+	if err != nil {
+		log.Error("Failed to read embedded migrations", zap.Error(err))
+		return nil, fmt.Errorf("failed to read embedded migrations: %w", err)
+	}
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedded migrations: %w", err)
+	}
+	m, err := migrate.NewWithSourceInstance("iofs", src, connStr)
+	if err != nil {
+		log.Error("Failed to initialize migrations", zap.Error(err))
+		return nil, fmt.Errorf("start migrations error %v", err)
+	}
+	return &Migrator{m: m, expected: expected, log: log}, nil
+}
+
+func (m *Migrator) Up() error {
+	m.log.Info("Applying migrations")
+	if err := m.m.Up(); err != nil {
+		if errors.Is(err, migrate.ErrNoChange) {
+			m.log.Info("No new migrations")
+			return nil
+		}
+		return fmt.Errorf("migration up error: %v", err)
+	}
+	return nil
+}
+
+// Down откатывает steps миграций.
+func (m *Migrator) Down(steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("migration down error: steps must be positive, got %d", steps)
+	}
+	m.log.Info("Rolling back migrations", zap.Int("steps", steps))
+	return downError(m.m.Steps(-steps))
+}
+
+// DownAll откатывает все миграции и удаляет все таблицы сервиса.
+func (m *Migrator) DownAll() error {
+	m.log.Warn("Rolling back all migrations")
+	return downError(m.m.Down())
+}
+
+func downError(err error) error {
+	if err != nil {
+		if errors.Is(err, migrate.ErrNoChange) {
+			return nil
+		}
+		return fmt.Errorf("migration down error: %v", err)
+	}
+	return nil
+}
+
+// To мигрирует схему вверх или вниз до указанной версии.
+func (m *Migrator) To(version uint) error {
+	m.log.Info("Migrating to version", zap.Uint("version", version))
+	if err := m.m.Migrate(version); err != nil {
+		if errors.Is(err, migrate.ErrNoChange) {
+			return nil
+		}
+		return fmt.Errorf("migration to version %d error: %v", version, err)
+	}
+	return nil
+}
+
+// Force выставляет версию и снимает флаг dirty без выполнения миграций.
+func (m *Migrator) Force(version int) error {
+	m.log.Warn("Forcing migration version", zap.Int("version", version))
+	if err := m.m.Force(version); err != nil {
+		return fmt.Errorf("force version %d error: %v", version, err)
+	}
+	return nil
+}
+
+// Version возвращает текущую версию схемы. Для пустой БД version равна 0.
+func (m *Migrator) Version() (version uint, dirty bool, err error) {
+	version, dirty, err = m.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read migration version: %w", err)
+	}
+	return version, dirty, nil
+}
+
+// ExpectedVersion - последняя версия среди встроенных миграций, с которой совместим код.
+func (m *Migrator) ExpectedVersion() uint {
+	return m.expected
+}
+
+// CheckVersion возвращает ErrSchemaVersionMismatch, если схема не совпадает с ожидаемой или находится в состоянии dirty.
+func (m *Migrator) CheckVersion() error {
+	version, dirty, err := m.Version()
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("%w: version %d is dirty", ErrSchemaVersionMismatch, version)
+	}
+	if version != m.expected {
+		return fmt.Errorf("%w: database is at %d, code expects %d", ErrSchemaVersionMismatch, version, m.expected)
+	}
+	return nil
+}
+
+func (m *Migrator) Close() error {
+	sourceErr, dbErr := m.m.Close()
+	if sourceErr != nil {
+		return sourceErr
+	}
+	return dbErr
+}
+
+func latestVersion(fsys fs.FS) (uint, error) {
+	src, err := iofs.New(fsys, ".")
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	version, err := src.First()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
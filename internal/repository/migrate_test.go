@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"L0/migrations"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatestVersion_Embedded(t *testing.T) {
+	version, err := latestVersion(migrations.FS)
+
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, version, uint(1))
+}
+
+func TestLatestVersion_PicksHighest(t *testing.T) {
+	fsys := fstest.MapFS{
+		"001_init.up.sql":     {Data: []byte("")},
+		"001_init.down.sql":   {Data: []byte("")},
+		"002_next.up.sql":     {Data: []byte("")},
+		"010_latest.up.sql":   {Data: []byte("")},
+		"010_latest.down.sql": {Data: []byte("")},
+	}
+
+	version, err := latestVersion(fsys)
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint(10), version)
+}
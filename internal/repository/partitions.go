@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"L0/internal/config"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+	"regexp"
+	"time"
+)
+
+const (
+	RetentionDrop   = "drop"
+	RetentionDetach = "detach"
+
+	defaultPartitionInterval = time.Hour
+	defaultPremakeMonths     = 2
+
+	// Произвольный ключ advisory lock, чтобы maintenance выполняла только одна реплика.
+	partitionLockKey = 7264011
+
+	createPartitionsQuery = `SELECT create_order_partitions($1)`
+	listPartitionsQuery   = `
+        SELECT c.relname
+        FROM pg_inherits i
+        JOIN pg_class c ON c.oid = i.inhrelid
+        JOIN pg_class p ON p.oid = i.inhparent
+        WHERE p.relname = 'orders'
+    `
+	foreignKeysQuery = `SELECT conname FROM pg_constraint WHERE conrelid = $1::regclass AND contype = 'f'`
+)
+
+// Дочерние таблицы идут первыми: партицию orders нельзя отсоединить, пока на неё ссылаются внешние ключи.
+var partitionedTables = []string{"items", "payments", "deliveries", "orders"}
+
+var partitionNameRe = regexp.MustCompile(`^orders_p(\d{4})_(\d{2})$`)
+
+// PartitionManager заранее создаёт помесячные партиции таблиц заказов и
+// отсоединяет или удаляет партиции старше срока хранения.
+type PartitionManager struct {
+	db              *pgxpool.Pool
+	interval        time.Duration
+	premakeMonths   int
+	retentionMonths int
+	retentionMode   string
+	log             *zap.Logger
+}
+
+func (s *Storage) NewPartitionManager(cfg config.Partitioning) (*PartitionManager, error) {
+	m := &PartitionManager{
+		db:              s.db,
+		interval:        cfg.Interval,
+		premakeMonths:   cfg.PremakeMonths,
+		retentionMonths: cfg.RetentionMonths,
+		retentionMode:   cfg.RetentionMode,
+		log:             s.log.Named("partitions"),
+	}
+	if m.interval <= 0 {
+		m.interval = defaultPartitionInterval
+	}
+	if m.premakeMonths <= 0 {
+		m.premakeMonths = defaultPremakeMonths
+	}
+	if m.retentionMode == "" {
+		m.retentionMode = RetentionDetach
+	}
+	if m.retentionMode != RetentionDrop && m.retentionMode != RetentionDetach {
+		return nil, fmt.Errorf("unknown retention mode %q", cfg.RetentionMode)
+	}
+	return m, nil
+}
+
+// Run выполняет обслуживание сразу и затем с заданным интервалом до отмены ctx.
+func (m *PartitionManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		if err := m.Maintain(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			m.log.Error("Partition maintenance failed", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Maintain создаёт партиции на premakeMonths вперёд и применяет политику хранения. Каждый месяц
+// обрабатывается в своей транзакции: ошибка одного месяца не мешает остальным и следующим запускам.
+// Если обслуживание уже выполняет другой процесс, вызов ничего не делает.
+func (m *PartitionManager) Maintain(ctx context.Context, now time.Time) error {
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, partitionLockKey).Scan(&locked); err != nil {
+		return fmt.Errorf("failed to acquire maintenance lock: %w", err)
+	}
+	if !locked {
+		m.log.Debug("Partition maintenance is running elsewhere, skipping")
+		return nil
+	}
+	defer func() {
+		// Блокировка сессионная: если её не снять, соединение нельзя возвращать в пул.
+		if _, err := conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, partitionLockKey); err != nil {
+			m.log.Error("Failed to release maintenance lock", zap.Error(err))
+			conn.Conn().Close(context.WithoutCancel(ctx))
+		}
+	}()
+
+	var errs []error
+	current := monthStart(now)
+	for i := 0; i <= m.premakeMonths; i++ {
+		month := current.AddDate(0, i, 0)
+		err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			_, err := tx.Exec(ctx, createPartitionsQuery, month)
+			return err
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to create partitions for %s: %w", month.Format("2006-01"), err))
+		}
+	}
+
+	if m.retentionMonths > 0 {
+		expired, err := m.expiredMonths(ctx, conn, now)
+		if err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		for _, month := range expired {
+			if err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error { return m.retire(ctx, tx, month) }); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *PartitionManager) expiredMonths(ctx context.Context, db querier, now time.Time) ([]time.Time, error) {
+	rows, err := db.Query(ctx, listPartitionsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+	return expiredPartitionMonths(names, retentionCutoff(now, m.retentionMonths)), nil
+}
+
+func (m *PartitionManager) retire(ctx context.Context, tx pgx.Tx, month time.Time) error {
+	for _, table := range partitionedTables {
+		partition := partitionName(table, month)
+		detach := fmt.Sprintf("ALTER TABLE %s DETACH PARTITION %s", pgx.Identifier{table}.Sanitize(), pgx.Identifier{partition}.Sanitize())
+		if _, err := tx.Exec(ctx, detach); err != nil {
+			return fmt.Errorf("failed to detach partition %s: %w", partition, err)
+		}
+		if m.retentionMode == RetentionDrop {
+			if _, err := tx.Exec(ctx, "DROP TABLE "+pgx.Identifier{partition}.Sanitize()); err != nil {
+				return fmt.Errorf("failed to drop partition %s: %w", partition, err)
+			}
+			continue
+		}
+		// Отсоединённая таблица сохраняет внешний ключ на orders, и он не дал бы отсоединить партицию orders.
+		if err := dropForeignKeys(ctx, tx, partition); err != nil {
+			return err
+		}
+	}
+	m.log.Info("Retired order partitions", zap.String("month", month.Format("2006-01")), zap.String("mode", m.retentionMode))
+	return nil
+}
+
+func dropForeignKeys(ctx context.Context, tx pgx.Tx, table string) error {
+	rows, err := tx.Query(ctx, foreignKeysQuery, pgx.Identifier{table}.Sanitize())
+	if err != nil {
+		return fmt.Errorf("failed to list foreign keys of %s: %w", table, err)
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return fmt.Errorf("failed to list foreign keys of %s: %w", table, err)
+	}
+	for _, name := range names {
+		drop := fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", pgx.Identifier{table}.Sanitize(), pgx.Identifier{name}.Sanitize())
+		if _, err := tx.Exec(ctx, drop); err != nil {
+			return fmt.Errorf("failed to drop foreign key %s of %s: %w", name, table, err)
+		}
+	}
+	return nil
+}
+
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// retentionCutoff - начало самого старого месяца, который ещё хранится.
+func retentionCutoff(now time.Time, retentionMonths int) time.Time {
+	return monthStart(now).AddDate(0, -retentionMonths, 0)
+}
+
+func partitionName(table string, month time.Time) string {
+	return fmt.Sprintf("%s_p%s", table, month.Format("2006_01"))
+}
+
+// expiredPartitionMonths разбирает имена партиций orders_pYYYY_MM и возвращает месяцы раньше cutoff.
+func expiredPartitionMonths(names []string, cutoff time.Time) []time.Time {
+	var months []time.Time
+	for _, name := range names {
+		match := partitionNameRe.FindStringSubmatch(name)
+		if match == nil {
+			continue
+		}
+		month, err := time.Parse("2006_01", match[1]+"_"+match[2])
+		if err != nil {
+			continue
+		}
+		if month.Before(cutoff) {
+			months = append(months, month)
+		}
+	}
+	return months
+}
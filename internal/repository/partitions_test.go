@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"L0/internal/config"
+	"L0/internal/models"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetentionCutoff(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), retentionCutoff(now, 2))
+	assert.Equal(t, time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC), retentionCutoff(now, 12))
+}
+
+func TestPartitionName(t *testing.T) {
+	month := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, "orders_p2024_02", partitionName("orders", month))
+	assert.Equal(t, "items_p2024_02", partitionName("items", month))
+}
+
+func TestExpiredPartitionMonths(t *testing.T) {
+	names := []string{
+		"orders_default",
+		"orders_p2023_11",
+		"orders_p2023_12",
+		"orders_p2024_01",
+		"orders_p2024_02",
+		"orders_archive",
+	}
+	cutoff := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	months := expiredPartitionMonths(names, cutoff)
+
+	assert.Equal(t, []time.Time{
+		time.Date(2023, 11, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC),
+	}, months)
+}
+
+// dropTestPartitions удаляет партиции месяцев year, присоединённые и отсоединённые, чтобы тест можно было повторить.
+func dropTestPartitions(t *testing.T, storage *Storage, year int) {
+	t.Cleanup(func() {
+		ctx := context.Background()
+		for month := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC); month.Year() == year; month = month.AddDate(0, 1, 0) {
+			for _, table := range partitionedTables {
+				partition := partitionName(table, month)
+				_, _ = storage.db.Exec(ctx, "ALTER TABLE "+table+" DETACH PARTITION "+partition)
+				_, err := storage.db.Exec(ctx, "DROP TABLE IF EXISTS "+partition)
+				require.NoError(t, err)
+			}
+		}
+	})
+}
+
+// partitionOf возвращает таблицу, в которой лежит строка заказа.
+func partitionOf(t *testing.T, storage *Storage, table string, orderUID string) string {
+	var partition string
+	err := storage.db.QueryRow(context.Background(),
+		"SELECT tableoid::regclass::text FROM "+table+" WHERE order_uid = $1", orderUID).Scan(&partition)
+	require.NoError(t, err)
+	return partition
+}
+
+func TestPartitionManager_MaintainMovesRowsFromDefault(t *testing.T) {
+	storage := testStorage(t)
+	dropTestPartitions(t, storage, 2002)
+	repo := storage.NewRepository(nil)
+	ctx := context.Background()
+
+	m, err := storage.NewPartitionManager(config.Partitioning{PremakeMonths: 2})
+	require.NoError(t, err)
+	require.NoError(t, m.Maintain(ctx, time.Date(2002, 1, 15, 0, 0, 0, 0, time.UTC)))
+
+	// Май 2002 дальше premake_months, заказ попадает в DEFAULT партиции.
+	early := testOrder("uid-early", time.Date(2002, 5, 10, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, repo.SaveOrder(ctx, early))
+	assert.Equal(t, "orders_default", partitionOf(t, storage, "orders", early.OrderUID))
+
+	require.NoError(t, m.Maintain(ctx, time.Date(2002, 3, 15, 0, 0, 0, 0, time.UTC)))
+
+	for _, table := range partitionedTables {
+		assert.Equal(t, partitionName(table, time.Date(2002, 5, 1, 0, 0, 0, 0, time.UTC)), partitionOf(t, storage, table, early.OrderUID))
+	}
+	got, err := repo.GetOrderByUID(ctx, early.OrderUID)
+	require.NoError(t, err)
+	assert.Len(t, got.Items, 2)
+	assert.Equal(t, early.Delivery, got.Delivery)
+
+	// Повторный запуск ничего не меняет.
+	require.NoError(t, m.Maintain(ctx, time.Date(2002, 3, 15, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestPartitionManager_RetentionDetach(t *testing.T) {
+	storage := testStorage(t)
+	dropTestPartitions(t, storage, 2001)
+	repo := storage.NewRepository(nil)
+	ctx := context.Background()
+
+	m, err := storage.NewPartitionManager(config.Partitioning{PremakeMonths: 2})
+	require.NoError(t, err)
+	require.NoError(t, m.Maintain(ctx, time.Date(2001, 1, 15, 0, 0, 0, 0, time.UTC)))
+	old := testOrder("uid-old", time.Date(2001, 1, 10, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, repo.SaveOrder(ctx, old))
+
+	m, err = storage.NewPartitionManager(config.Partitioning{PremakeMonths: 2, RetentionMonths: 2, RetentionMode: RetentionDetach})
+	require.NoError(t, err)
+	require.NoError(t, m.Maintain(ctx, time.Date(2001, 6, 15, 0, 0, 0, 0, time.UTC)))
+
+	_, err = repo.GetOrderByUID(ctx, old.OrderUID)
+	assert.ErrorIs(t, err, models.OrderNotFoundError)
+
+	january := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, table := range partitionedTables {
+		partition := partitionName(table, january)
+		var attached bool
+		err := storage.db.QueryRow(ctx, `SELECT relispartition FROM pg_class WHERE relname = $1`, partition).Scan(&attached)
+		require.NoError(t, err, "отсоединённая партиция %s остаётся таблицей", partition)
+		assert.False(t, attached)
+		var foreignKeys int
+		require.NoError(t, storage.db.QueryRow(ctx, `SELECT count(*) FROM pg_constraint WHERE conrelid = $1::regclass AND contype = 'f'`, partition).Scan(&foreignKeys))
+		assert.Zero(t, foreignKeys)
+	}
+	var count int
+	require.NoError(t, storage.db.QueryRow(ctx, `SELECT count(*) FROM items_p2001_01 WHERE order_uid = $1`, old.OrderUID).Scan(&count))
+	assert.Equal(t, 2, count, "данные остаются в отсоединённой таблице для архивации")
+}
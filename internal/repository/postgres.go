@@ -1,21 +1,31 @@
 package repository
 
 import (
+	"L0/internal/fieldcrypt"
 	"L0/internal/models"
+	"L0/pkg/logger"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
+	"time"
 )
 
 const (
-	orderQuery = `
+	// orderLockQuery сериализует сохранения одного заказа до конца транзакции: без неё две версии
+	// с разными датами могли бы удалить друг друга до вставки и обе остаться в таблице.
+	orderLockQuery = `SELECT pg_advisory_xact_lock(hashtext($1))`
+	// staleOrderQuery удаляет прежнюю версию заказа с другой date_created: она лежит в другой партиции,
+	// и ON CONFLICT (order_uid, date_created) её не находит. Зависимые строки удаляются каскадом.
+	staleOrderQuery = `DELETE FROM orders WHERE order_uid = $1 AND date_created <> $2`
+	orderQuery      = `
         INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, 
                           customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-        ON CONFLICT (order_uid) DO UPDATE SET
+        ON CONFLICT (order_uid, date_created) DO UPDATE SET
             track_number = EXCLUDED.track_number,
             entry = EXCLUDED.entry,
             locale = EXCLUDED.locale,
@@ -24,26 +34,28 @@ const (
             delivery_service = EXCLUDED.delivery_service,
             shardkey = EXCLUDED.shardkey,
             sm_id = EXCLUDED.sm_id,
-            date_created = EXCLUDED.date_created,
             oof_shard = EXCLUDED.oof_shard
     `
 	deliveryQuery = `
-        INSERT INTO deliveries (order_uid, name, phone, zip, city, address, region, email)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-        ON CONFLICT (order_uid) DO UPDATE SET
+        INSERT INTO deliveries (order_uid, date_created, name, phone, zip, city, address, region, email,
+                                phone_bidx, email_bidx)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+        ON CONFLICT (order_uid, date_created) DO UPDATE SET
             name = EXCLUDED.name,
             phone = EXCLUDED.phone,
             zip = EXCLUDED.zip,
             city = EXCLUDED.city,
             address = EXCLUDED.address,
             region = EXCLUDED.region,
-            email = EXCLUDED.email
+            email = EXCLUDED.email,
+            phone_bidx = EXCLUDED.phone_bidx,
+            email_bidx = EXCLUDED.email_bidx
     `
 	paymentQuery = `
-        INSERT INTO payments (order_uid, transaction, request_id, currency, provider, 
+        INSERT INTO payments (order_uid, date_created, transaction, request_id, currency, provider, 
                              amount, payment_dt, bank, delivery_cost, goods_total, custom_fee)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-        ON CONFLICT (order_uid) DO UPDATE SET
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+        ON CONFLICT (order_uid, date_created) DO UPDATE SET
             transaction = EXCLUDED.transaction,
             request_id = EXCLUDED.request_id,
             currency = EXCLUDED.currency,
@@ -57,10 +69,10 @@ const (
     `
 	itemsQuery = `
         INSERT INTO items (
-            order_uid, chrt_id, track_number, price, rid, name,
+            order_uid, date_created, chrt_id, track_number, price, rid, name,
             sale, size, total_price, nm_id, brand, status
-        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-        ON CONFLICT (order_uid, rid) DO UPDATE SET
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+        ON CONFLICT (order_uid, date_created, rid) DO UPDATE SET
             chrt_id = EXCLUDED.chrt_id,
             track_number = EXCLUDED.track_number,
             price = EXCLUDED.price,
@@ -72,49 +84,109 @@ const (
             brand = EXCLUDED.brand,
             status = EXCLUDED.status
     `
-	orderQueryGet = `
-        SELECT order_uid, track_number, entry, locale, internal_signature, 
-               customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
-        FROM orders 
-        WHERE order_uid = $1
-    `
-	deliveryQueryGet = `
-        SELECT name, phone, zip, city, address, region, email
-        FROM deliveries 
-        WHERE order_uid = $1
+	orderColumns = `
+        SELECT o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature,
+               o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
+               d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
+               p.transaction, p.request_id, p.currency, p.provider, p.amount,
+               p.payment_dt, p.bank, p.delivery_cost, p.goods_total, p.custom_fee,
     `
-	paymentQueryGet = `
-        SELECT transaction, request_id, currency, provider, amount, 
-               payment_dt, bank, delivery_cost, goods_total, custom_fee
-        FROM payments 
-        WHERE order_uid = $1
+	itemObject = `json_build_object(
+                       'chrt_id', i.chrt_id,
+                       'track_number', i.track_number,
+                       'price', i.price,
+                       'rid', i.rid,
+                       'name', i.name,
+                       'sale', i.sale,
+                       'size', i.size,
+                       'total_price', i.total_price,
+                       'nm_id', i.nm_id,
+                       'brand', i.brand,
+                       'status', i.status
+                   )`
+	orderFrom = `
+        FROM orders o
+        JOIN deliveries d ON d.order_uid = o.order_uid AND d.date_created = o.date_created
+        JOIN payments p ON p.order_uid = o.order_uid AND p.date_created = o.date_created
     `
-	itemsQueryGet = `
-        SELECT chrt_id, track_number, price, rid, name, sale, size, 
-               total_price, nm_id, brand, status
-        FROM items 
-        WHERE order_uid = $1
-        ORDER BY id
-    `
-	recentGetQuery = `
-		SELECT order_uid FROM orders ORDER BY date_created DESC LIMIT $1
-`
+	// orderSelect собирает заказ целиком одним запросом: один statement видит согласованный снимок,
+	// поэтому параллельный SaveOrder не может дать "порванное" чтение.
+	orderSelect = orderColumns + `
+               COALESCE((
+                   SELECT json_agg(` + itemObject + ` ORDER BY i.id)
+                   FROM items i
+                   WHERE i.order_uid = o.order_uid AND i.date_created = o.date_created
+               ), '[]'::json) AS items` + orderFrom
+	// orderItemsPageSelect читает только страницу товаров: $2 - сколько пропустить, $3 - сколько взять (NULL - все).
+	orderItemsPageSelect = orderColumns + `
+               COALESCE((
+                   SELECT json_agg(` + itemObject + ` ORDER BY i.id)
+                   FROM (
+                       SELECT * FROM items
+                       WHERE order_uid = o.order_uid AND date_created = o.date_created
+                       ORDER BY id OFFSET $2 LIMIT $3
+                   ) i
+               ), '[]'::json) AS items` + orderFrom
+	orderWithoutItemsSelect = orderColumns + `
+               '[]'::json AS items` + orderFrom
+	// Таблицы партиционированы по date_created, поэтому order_uid уникален только в паре с ним.
+	// SaveOrder держит одну версию заказа, ORDER BY страхует от строк, сохранённых до этого.
+	orderQueryGet             = orderSelect + ` WHERE o.order_uid = $1 ORDER BY o.date_created DESC LIMIT 1`
+	orderItemsPageQueryGet    = orderItemsPageSelect + ` WHERE o.order_uid = $1 ORDER BY o.date_created DESC LIMIT 1`
+	orderWithoutItemsQueryGet = orderWithoutItemsSelect + ` WHERE o.order_uid = $1 ORDER BY o.date_created DESC LIMIT 1`
+	recentGetQuery            = orderSelect + ` ORDER BY o.date_created DESC LIMIT $1`
+	// ordersListQuery - страница списка по ключу (date_created, order_uid): $3, $4 - последний заказ
+	// предыдущей страницы или NULL для первой.
+	ordersListQuery             = orderSelect + ordersListWhere
+	ordersWithoutItemsListQuery = orderWithoutItemsSelect + ordersListWhere
+	ordersListWhere             = `
+        WHERE ($1 = '' OR o.customer_id = $1)
+          AND ($2 = '' OR o.delivery_service = $2)
+          AND ($3::timestamptz IS NULL OR (o.date_created, o.order_uid) < ($3, $4))
+        ORDER BY o.date_created DESC, o.order_uid DESC
+        LIMIT $5`
+	// itemsByOrdersQuery читает товары нескольких заказов одним запросом, idx - номер заказа в $1, $2 с единицы.
+	itemsByOrdersQuery = `
+        SELECT k.idx, ` + itemObject + `
+        FROM unnest($1::text[], $2::timestamptz[]) WITH ORDINALITY AS k(order_uid, date_created, idx)
+        JOIN items i ON i.order_uid = k.order_uid AND i.date_created = k.date_created
+        ORDER BY k.idx, i.id`
 )
 
 type Repository struct {
-	db  *pgxpool.Pool
-	log *zap.Logger
+	db           *pgxpool.Pool
+	replicas     *replicaSet
+	cipher       *fieldcrypt.Cipher
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	log          *zap.Logger
 }
 
-func (s *Storage) NewRepository() *Repository {
-	return &Repository{db: s.db, log: s.log.Named("Repository")}
+// NewRepository создаёт репозиторий. cipher может быть nil, тогда данные доставки хранятся открыто.
+func (s *Storage) NewRepository(cipher *fieldcrypt.Cipher) *Repository {
+	return &Repository{
+		db:           s.db,
+		replicas:     s.replicas,
+		cipher:       cipher,
+		readTimeout:  s.readTimeout,
+		writeTimeout: s.writeTimeout,
+		log:          s.log.Named("Repository"),
+	}
 }
 
+// SaveOrder сохраняет заказ. Если данные покупателя стирались после создания заказа (повторная доставка
+// сообщения, replay, загрузка из архива), доставка обезличивается в order до записи, так что кэш и
+// observers получают уже обезличенный заказ.
 func (r *Repository) SaveOrder(ctx context.Context, order *models.Order) error {
-	r.log.Debug("Saving Order", zap.Any("order", order))
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Saving Order", zap.Any("order", order))
+
+	ctx, cancel := withTimeout(ctx, r.writeTimeout)
+	defer cancel()
+
 	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
-		r.log.Error("Error begin transaction", zap.Error(err))
+		log.Error("Error begin transaction", zap.Error(err))
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer func() {
@@ -123,6 +195,29 @@ func (r *Repository) SaveOrder(ctx context.Context, order *models.Order) error {
 		}
 	}()
 
+	if _, err = tx.Exec(ctx, orderLockQuery, order.OrderUID); err != nil {
+		log.Error("Error locking order", zap.Error(err))
+		return fmt.Errorf("failed to lock order: %w", err)
+	}
+	if _, err = tx.Exec(ctx, staleOrderQuery, order.OrderUID, order.DateCreated); err != nil {
+		log.Error("Error deleting previous order version", zap.Error(err))
+		return fmt.Errorf("failed to delete previous order version: %w", err)
+	}
+
+	erased, err := erasedSince(ctx, tx, order.CustomerID, order.DateCreated)
+	if err != nil {
+		log.Error("Error checking erasures", zap.Error(err))
+		return err
+	}
+	if erased {
+		eraseDelivery(&order.Delivery)
+	}
+	delivery, err := r.cipher.EncryptDelivery(order.Delivery)
+	if err != nil {
+		log.Error("Error encrypting delivery", zap.Error(err))
+		return err
+	}
+
 	_, err = tx.Exec(ctx, orderQuery,
 		order.OrderUID,
 		order.TrackNumber,
@@ -137,27 +232,31 @@ func (r *Repository) SaveOrder(ctx context.Context, order *models.Order) error {
 		order.OofShard,
 	)
 	if err != nil {
-		r.log.Error("Error saving order", zap.Error(err))
+		log.Error("Error saving order", zap.Error(err))
 		return fmt.Errorf("failed to save order: %w", err)
 	}
 
 	_, err = tx.Exec(ctx, deliveryQuery,
 		order.OrderUID,
-		order.Delivery.Name,
-		order.Delivery.Phone,
-		order.Delivery.Zip,
-		order.Delivery.City,
-		order.Delivery.Address,
-		order.Delivery.Region,
-		order.Delivery.Email,
+		order.DateCreated,
+		delivery.Name,
+		delivery.Phone,
+		delivery.Zip,
+		delivery.City,
+		delivery.Address,
+		delivery.Region,
+		delivery.Email,
+		nullIfEmpty(r.cipher.BlindIndex(order.Delivery.Phone)),
+		nullIfEmpty(r.cipher.BlindIndex(order.Delivery.Email)),
 	)
 	if err != nil {
-		r.log.Error("Error saving delivery order", zap.Error(err))
+		log.Error("Error saving delivery order", zap.Error(err))
 		return fmt.Errorf("failed to save delivery: %w", err)
 	}
 
 	_, err = tx.Exec(ctx, paymentQuery,
 		order.OrderUID,
+		order.DateCreated,
 		order.Payment.Transaction,
 		order.Payment.RequestID,
 		order.Payment.Currency,
@@ -170,13 +269,14 @@ func (r *Repository) SaveOrder(ctx context.Context, order *models.Order) error {
 		order.Payment.CustomFee,
 	)
 	if err != nil {
-		r.log.Error("Error saving payment", zap.Error(err))
+		log.Error("Error saving payment", zap.Error(err))
 		return fmt.Errorf("failed to save payment: %w", err)
 	}
 
 	for _, item := range order.Items {
-		_, err := tx.Exec(ctx, itemsQuery,
+		_, err = tx.Exec(ctx, itemsQuery,
 			order.OrderUID,
+			order.DateCreated,
 			item.ChrtID,
 			item.TrackNumber,
 			item.Price,
@@ -190,18 +290,257 @@ func (r *Repository) SaveOrder(ctx context.Context, order *models.Order) error {
 			item.Status,
 		)
 		if err != nil {
-			r.log.Error("Error saving item", zap.Error(err))
+			log.Error("Error saving item", zap.Error(err))
 			return fmt.Errorf("failed to save item: %w", err)
 		}
 	}
-	r.log.Debug("Saved Order", zap.Any("order", order))
+	log.Debug("Saved Order", zap.Any("order", order))
 	return tx.Commit(ctx)
 }
 
 func (r *Repository) GetOrderByUID(ctx context.Context, orderUID string) (*models.Order, error) {
+	log := logger.FromContext(ctx, r.log)
+	ctx, cancel := withTimeout(ctx, r.readTimeout)
+	defer cancel()
+
+	var order *models.Order
+	err := r.read(ctx, func(db querier) error {
+		var err error
+		order, err = scanOrder(db.QueryRow(ctx, orderQueryGet, orderUID))
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.Warn("Order not found", zap.String("order_uid", orderUID))
+			return nil, models.OrderNotFoundError
+		}
+		log.Error("Error getting order", zap.Error(err))
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+	if err := r.decrypt(order); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// GetOrderView читает часть заказа: без товаров или только страницу товаров, не загружая остальные.
+func (r *Repository) GetOrderView(ctx context.Context, orderUID string, view models.OrderView) (*models.OrderPage, error) {
+	if view.Full() {
+		order, err := r.GetOrderByUID(ctx, orderUID)
+		if err != nil {
+			return nil, err
+		}
+		return &models.OrderPage{Order: order}, nil
+	}
+	log := logger.FromContext(ctx, r.log)
+	ctx, cancel := withTimeout(ctx, r.readTimeout)
+	defer cancel()
+
+	query, args := orderWithoutItemsQueryGet, []any{orderUID}
+	if !view.SkipItems {
+		// Лишний товар показывает, есть ли следующая страница.
+		var limit *int
+		if view.ItemsLimit > 0 {
+			limit = new(int)
+			*limit = view.ItemsLimit + 1
+		}
+		query, args = orderItemsPageQueryGet, []any{orderUID, view.ItemsOffset, limit}
+	}
+
+	var order *models.Order
+	err := r.read(ctx, func(db querier) error {
+		var err error
+		order, err = scanOrder(db.QueryRow(ctx, query, args...))
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.Warn("Order not found", zap.String("order_uid", orderUID))
+			return nil, models.OrderNotFoundError
+		}
+		log.Error("Error getting order view", zap.Error(err))
+		return nil, fmt.Errorf("failed to get order view: %w", err)
+	}
+	if err := r.decrypt(order); err != nil {
+		return nil, err
+	}
+
+	page := &models.OrderPage{Order: order}
+	if view.SkipItems {
+		order.Items = nil
+	} else if view.ItemsLimit > 0 && len(order.Items) > view.ItemsLimit {
+		order.Items = order.Items[:view.ItemsLimit]
+		page.HasMoreItems = true
+	}
+	return page, nil
+}
+
+func (r *Repository) GetRecentOrders(ctx context.Context, limit int) ([]*models.Order, error) {
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Getting recent orders ", zap.Int("limit", limit))
+	ctx, cancel := withTimeout(ctx, r.readTimeout)
+	defer cancel()
+
+	var orders []*models.Order
+	err := r.read(ctx, func(db querier) error {
+		var err error
+		orders, err = queryOrders(ctx, db, recentGetQuery, limit)
+		return err
+	})
+	if err != nil {
+		log.Error("Error getting recent orders", zap.Error(err))
+		return nil, fmt.Errorf("error getting recent orders: %w", err)
+	}
+	if err := r.decrypt(orders...); err != nil {
+		return nil, err
+	}
+	log.Debug("Received recent orders ", zap.Int("orders", len(orders)))
+	return orders, nil
+}
+
+// ListOrders возвращает до limit заказов по filter от новых к старым, начиная после after (nil - с начала).
+func (r *Repository) ListOrders(ctx context.Context, filter models.OrderFilter, after *models.OrderKey, limit int) ([]*models.Order, error) {
+	return r.listOrders(ctx, ordersListQuery, filter, after, limit)
+}
+
+// ListOrdersWithoutItems - то же, что ListOrders, но с пустыми Items: товары читаются отдельно через GetItemsByOrders.
+func (r *Repository) ListOrdersWithoutItems(ctx context.Context, filter models.OrderFilter, after *models.OrderKey, limit int) ([]*models.Order, error) {
+	orders, err := r.listOrders(ctx, ordersWithoutItemsListQuery, filter, after, limit)
+	for _, order := range orders {
+		order.Items = nil
+	}
+	return orders, err
+}
+
+func (r *Repository) listOrders(ctx context.Context, query string, filter models.OrderFilter, after *models.OrderKey, limit int) ([]*models.Order, error) {
+	log := logger.FromContext(ctx, r.log)
+	ctx, cancel := withTimeout(ctx, r.readTimeout)
+	defer cancel()
 
+	var afterDate *time.Time
+	var afterUID string
+	if after != nil {
+		afterDate, afterUID = &after.DateCreated, after.OrderUID
+	}
+	var orders []*models.Order
+	err := r.read(ctx, func(db querier) error {
+		var err error
+		orders, err = queryOrders(ctx, db, query, filter.CustomerID, filter.DeliveryService, afterDate, afterUID, limit)
+		return err
+	})
+	if err != nil {
+		log.Error("Error listing orders", zap.Error(err))
+		return nil, fmt.Errorf("error listing orders: %w", err)
+	}
+	if err := r.decrypt(orders...); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// GetItemsByOrders возвращает товары заказов keys: i-й элемент результата - товары keys[i].
+func (r *Repository) GetItemsByOrders(ctx context.Context, keys []models.OrderKey) ([][]models.Item, error) {
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Getting items by orders", zap.Int("count", len(keys)))
+	result := make([][]models.Item, len(keys))
+	if len(keys) == 0 {
+		return result, nil
+	}
+	ctx, cancel := withTimeout(ctx, r.readTimeout)
+	defer cancel()
+
+	uids := make([]string, len(keys))
+	dates := make([]time.Time, len(keys))
+	for i, key := range keys {
+		uids[i], dates[i] = key.OrderUID, key.DateCreated
+	}
+	err := r.read(ctx, func(db querier) error {
+		for i := range result {
+			result[i] = []models.Item{}
+		}
+		rows, err := db.Query(ctx, itemsByOrdersQuery, uids, dates)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var idx int
+			var data []byte
+			if err := rows.Scan(&idx, &data); err != nil {
+				return fmt.Errorf("failed to scan item: %w", err)
+			}
+			var item models.Item
+			if err := json.Unmarshal(data, &item); err != nil {
+				return fmt.Errorf("failed to decode item: %w", err)
+			}
+			result[idx-1] = append(result[idx-1], item)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		log.Error("Error getting items by orders", zap.Error(err))
+		return nil, fmt.Errorf("error getting items by orders: %w", err)
+	}
+	return result, nil
+}
+
+// decrypt расшифровывает данные доставки прочитанных заказов.
+func (r *Repository) decrypt(orders ...*models.Order) error {
+	for _, order := range orders {
+		if err := r.cipher.DecryptDelivery(&order.Delivery); err != nil {
+			r.log.Error("Error decrypting delivery", zap.String("order_uid", order.OrderUID), zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
+
+func nullIfEmpty(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// read выполняет чтение на здоровой реплике, а при её недоступности или ошибке - на primary.
+func (r *Repository) read(ctx context.Context, fn func(db querier) error) error {
+	if rep := r.replicas.pick(); rep != nil {
+		err := fn(rep.db)
+		if !shouldFallback(ctx, err) {
+			return err
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			r.replicas.markUnhealthy(rep, err)
+		}
+	}
+	return fn(r.db)
+}
+
+func queryOrders(ctx context.Context, db querier, query string, args ...any) ([]*models.Order, error) {
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []*models.Order
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating orders: %w", err)
+	}
+	return orders, nil
+}
+
+func scanOrder(row pgx.Row) (*models.Order, error) {
 	var order models.Order
-	err := r.db.QueryRow(ctx, orderQueryGet, orderUID).Scan(
+	var items []byte
+	err := row.Scan(
 		&order.OrderUID,
 		&order.TrackNumber,
 		&order.Entry,
@@ -213,17 +552,6 @@ func (r *Repository) GetOrderByUID(ctx context.Context, orderUID string) (*model
 		&order.SmID,
 		&order.DateCreated,
 		&order.OofShard,
-	)
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			r.log.Warn("Order not found", zap.String("order_uid", orderUID))
-			return nil, models.OrderNotFoundError
-		}
-		r.log.Error("Error getting order", zap.Error(err))
-		return nil, fmt.Errorf("failed to get order: %w", err)
-	}
-
-	err = r.db.QueryRow(ctx, deliveryQueryGet, orderUID).Scan(
 		&order.Delivery.Name,
 		&order.Delivery.Phone,
 		&order.Delivery.Zip,
@@ -231,13 +559,6 @@ func (r *Repository) GetOrderByUID(ctx context.Context, orderUID string) (*model
 		&order.Delivery.Address,
 		&order.Delivery.Region,
 		&order.Delivery.Email,
-	)
-	if err != nil {
-		r.log.Error("Error getting delivery order", zap.Error(err))
-		return nil, fmt.Errorf("failed to get delivery: %w", err)
-	}
-
-	err = r.db.QueryRow(ctx, paymentQueryGet, orderUID).Scan(
 		&order.Payment.Transaction,
 		&order.Payment.RequestID,
 		&order.Payment.Currency,
@@ -248,88 +569,24 @@ func (r *Repository) GetOrderByUID(ctx context.Context, orderUID string) (*model
 		&order.Payment.DeliveryCost,
 		&order.Payment.GoodsTotal,
 		&order.Payment.CustomFee,
+		&items,
 	)
 	if err != nil {
-		r.log.Error("Error getting payment", zap.Error(err))
-		return nil, fmt.Errorf("failed to get payment: %w", err)
-	}
-
-	rows, err := r.db.Query(ctx, itemsQueryGet, orderUID)
-	if err != nil {
-		r.log.Error("Error getting items", zap.Error(err))
-		return nil, fmt.Errorf("failed to get items: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
-
 	order.Items = make([]models.Item, 0)
-	for rows.Next() {
-		var item models.Item
-		err := rows.Scan(
-			&item.ChrtID,
-			&item.TrackNumber,
-			&item.Price,
-			&item.Rid,
-			&item.Name,
-			&item.Sale,
-			&item.Size,
-			&item.TotalPrice,
-			&item.NmID,
-			&item.Brand,
-			&item.Status,
-		)
-		if err != nil {
-			r.log.Error("Error getting item", zap.Error(err))
-			return nil, fmt.Errorf("failed to scan item: %w", err)
-		}
-		order.Items = append(order.Items, item)
+	if err := json.Unmarshal(items, &order.Items); err != nil {
+		return nil, fmt.Errorf("failed to decode items: %w", err)
 	}
-
-	if err := rows.Err(); err != nil {
-		r.log.Error("Error iterating items", zap.Error(err))
-		return nil, fmt.Errorf("error iterating items: %w", err)
-	}
-
 	return &order, nil
 }
 
-func (r *Repository) GetRecentOrders(ctx context.Context, limit int) ([]*models.Order, error) {
-	r.log.Debug("Getting recent orders ", zap.Int("limit", limit))
-	var orderUIDs []string
-	rows, err := r.db.Query(ctx, recentGetQuery, limit)
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			r.log.Error("Error getting recent orders", zap.Error(err))
-			return nil, fmt.Errorf("error getting recent orders: %w", err)
-		}
-		r.log.Error("Error getting recent orders", zap.Error(err))
-		return nil, fmt.Errorf("error getting recent orders: %w", err)
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var orderUID string
-		if err := rows.Scan(&orderUID); err != nil {
-			return nil, fmt.Errorf("failed to scan order UID: %w", err)
-		}
-		orderUIDs = append(orderUIDs, orderUID)
-	}
-
-	var orders []*models.Order
-	for _, uuid := range orderUIDs {
-		order, err := r.GetOrderByUID(ctx, uuid)
-		if err != nil {
-			r.log.Error("Error getting order", zap.Error(err))
-			return nil, fmt.Errorf("error getting order: %w", err)
-		}
-		orders = append(orders, order)
-	}
-	r.log.Debug("Received recent orders ", zap.Int("orders", len(orders)))
-	return orders, nil
-
+func (r *Repository) PoolStats() models.PoolStats {
+	return poolStats(r.db)
 }
 
 func (r *Repository) Close() {
 	r.log.Info("Closing database")
+	r.replicas.close()
 	r.db.Close()
 }
@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"L0/internal/config"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultReplicaMaxLag      = 5 * time.Second
+	defaultReplicaCheckPeriod = 5 * time.Second
+	replicaCheckTimeout       = 2 * time.Second
+
+	// replicaLagQuery возвращает отставание в секундах или NULL, если реплика не получает WAL: без
+	// процесса walreceiver принятый и проигранный LSN совпадают навсегда, и реплика выглядела бы догнавшей.
+	// Проигранный весь полученный WAL при работающем walreceiver - отставания нет, даже если primary
+	// давно не писал. status виден только ролям с pg_read_all_stats, для остальных он NULL, и тогда
+	// достаточно наличия процесса.
+	replicaLagQuery = `
+        SELECT CASE
+            WHEN NOT pg_is_in_recovery() THEN 0
+            WHEN NOT EXISTS (
+                SELECT 1 FROM pg_stat_wal_receiver WHERE COALESCE(status, 'streaming') = 'streaming'
+            ) THEN NULL
+            WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+            ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+        END::float8
+    `
+)
+
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+type replicaDB interface {
+	querier
+	Close()
+}
+
+type replica struct {
+	name    string
+	db      replicaDB
+	healthy atomic.Bool
+	lag     atomic.Int64
+}
+
+// replicaSet распределяет чтения по здоровым репликам round-robin'ом.
+// Реплика считается здоровой, если последняя проверка прошла и отставание не превышает maxLag.
+type replicaSet struct {
+	replicas []*replica
+	maxLag   time.Duration
+	next     atomic.Uint32
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	log      *zap.Logger
+}
+
+func newReplicaSet(ctx context.Context, cfg config.Storage, log *zap.Logger) (*replicaSet, error) {
+	set := &replicaSet{
+		maxLag: cfg.ReplicaMaxLag,
+		log:    log.Named("replicas"),
+	}
+	if set.maxLag <= 0 {
+		set.maxLag = defaultReplicaMaxLag
+	}
+	for _, dsn := range cfg.Replicas {
+		poolConfig, err := newPoolConfig(dsn, cfg)
+		if err != nil {
+			set.close()
+			return nil, fmt.Errorf("failed to parse replica DSN: %w", err)
+		}
+		name := fmt.Sprintf("%s:%d", poolConfig.ConnConfig.Host, poolConfig.ConnConfig.Port)
+		pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+		if err != nil {
+			set.close()
+			return nil, fmt.Errorf("failed to create replica pool %s: %w", name, err)
+		}
+		set.replicas = append(set.replicas, &replica{name: name, db: pool})
+	}
+	if len(set.replicas) == 0 {
+		return set, nil
+	}
+
+	set.checkAll(ctx)
+
+	period := cfg.ReplicaCheckPeriod
+	if period <= 0 {
+		period = defaultReplicaCheckPeriod
+	}
+	checkCtx, cancel := context.WithCancel(context.Background())
+	set.cancel = cancel
+	set.wg.Add(1)
+	go func() {
+		defer set.wg.Done()
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-checkCtx.Done():
+				return
+			case <-ticker.C:
+				set.checkAll(checkCtx)
+			}
+		}
+	}()
+	return set, nil
+}
+
+// pick возвращает следующую здоровую реплику или nil, если таких нет.
+func (s *replicaSet) pick() *replica {
+	if s == nil || len(s.replicas) == 0 {
+		return nil
+	}
+	start := s.next.Add(1)
+	for i := 0; i < len(s.replicas); i++ {
+		rep := s.replicas[(int(start)+i)%len(s.replicas)]
+		if rep.healthy.Load() {
+			return rep
+		}
+	}
+	return nil
+}
+
+func (s *replicaSet) checkAll(ctx context.Context) {
+	for _, rep := range s.replicas {
+		s.check(ctx, rep)
+	}
+}
+
+func (s *replicaSet) check(ctx context.Context, rep *replica) {
+	ctx, cancel := context.WithTimeout(ctx, replicaCheckTimeout)
+	defer cancel()
+
+	var lagSeconds *float64
+	if err := rep.db.QueryRow(ctx, replicaLagQuery).Scan(&lagSeconds); err != nil {
+		if rep.healthy.Swap(false) {
+			s.log.Warn("Replica check failed, routing reads to other nodes", zap.String("replica", rep.name), zap.Error(err))
+		}
+		return
+	}
+	if lagSeconds == nil {
+		if rep.healthy.Swap(false) {
+			s.log.Warn("Replica is not receiving WAL, routing reads to other nodes", zap.String("replica", rep.name))
+		}
+		return
+	}
+	lag := time.Duration(*lagSeconds * float64(time.Second))
+	rep.lag.Store(int64(lag))
+
+	healthy := lag <= s.maxLag
+	if rep.healthy.Swap(healthy) != healthy {
+		s.log.Info("Replica state changed",
+			zap.String("replica", rep.name),
+			zap.Bool("healthy", healthy),
+			zap.Duration("lag", lag))
+	}
+}
+
+func (s *replicaSet) markUnhealthy(rep *replica, err error) {
+	if rep.healthy.Swap(false) {
+		s.log.Warn("Replica query failed, falling back to primary", zap.String("replica", rep.name), zap.Error(err))
+	}
+}
+
+func (s *replicaSet) close() {
+	if s == nil {
+		return
+	}
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+	for _, rep := range s.replicas {
+		rep.db.Close()
+	}
+}
+
+// shouldFallback сообщает, нужно ли повторить чтение на primary.
+// ErrNoRows тоже повторяется: заказ мог ещё не доехать до реплики.
+func shouldFallback(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// Ошибки данных и синтаксиса повторятся и на primary.
+		class := pgErr.Code[:2]
+		return class != "22" && class != "42"
+	}
+	return true
+}
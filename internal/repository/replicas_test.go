@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// fakeReplicaDB отдаёт заданное отставание в секундах или ошибку, noReceiver - NULL вместо отставания
+type fakeReplicaDB struct {
+	lag        float64
+	noReceiver bool
+	err        error
+}
+
+func (f *fakeReplicaDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return nil, f.err
+}
+
+func (f *fakeReplicaDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return &lagRow{lag: f.lag, noReceiver: f.noReceiver, err: f.err}
+}
+
+func (f *fakeReplicaDB) Close() {}
+
+type lagRow struct {
+	lag        float64
+	noReceiver bool
+	err        error
+}
+
+func (r *lagRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	if r.noReceiver {
+		*dest[0].(**float64) = nil
+		return nil
+	}
+	lag := r.lag
+	*dest[0].(**float64) = &lag
+	return nil
+}
+
+func newTestReplicaSet(dbs ...*fakeReplicaDB) *replicaSet {
+	set := &replicaSet{maxLag: time.Second, log: zap.NewNop()}
+	for i, db := range dbs {
+		set.replicas = append(set.replicas, &replica{name: string(rune('a' + i)), db: db})
+	}
+	return set
+}
+
+func TestReplicaSet_CheckAppliesLagGuard(t *testing.T) {
+	set := newTestReplicaSet(&fakeReplicaDB{lag: 0.2}, &fakeReplicaDB{lag: 10}, &fakeReplicaDB{err: errors.New("down")}, &fakeReplicaDB{noReceiver: true})
+	set.replicas[3].healthy.Store(true)
+
+	set.checkAll(context.Background())
+
+	assert.True(t, set.replicas[0].healthy.Load())
+	assert.False(t, set.replicas[1].healthy.Load())
+	assert.False(t, set.replicas[2].healthy.Load())
+	assert.False(t, set.replicas[3].healthy.Load(), "реплика без walreceiver не догоняет primary")
+}
+
+func TestReplicaSet_PickSkipsUnhealthy(t *testing.T) {
+	set := newTestReplicaSet(&fakeReplicaDB{}, &fakeReplicaDB{}, &fakeReplicaDB{})
+	set.replicas[0].healthy.Store(true)
+	set.replicas[2].healthy.Store(true)
+
+	picked := map[string]int{}
+	for i := 0; i < 10; i++ {
+		picked[set.pick().name]++
+	}
+
+	assert.Equal(t, 0, picked["b"])
+	assert.Greater(t, picked["a"], 0)
+	assert.Greater(t, picked["c"], 0)
+}
+
+func TestReplicaSet_PickNone(t *testing.T) {
+	var nilSet *replicaSet
+	assert.Nil(t, nilSet.pick())
+	assert.Nil(t, newTestReplicaSet(&fakeReplicaDB{}).pick())
+}
+
+func TestRepository_ReadFallsBackToPrimary(t *testing.T) {
+	set := newTestReplicaSet(&fakeReplicaDB{})
+	set.replicas[0].healthy.Store(true)
+	repo := &Repository{replicas: set, log: zap.NewNop()}
+
+	calls := 0
+	err := repo.read(context.Background(), func(db querier) error {
+		calls++
+		if _, ok := db.(*fakeReplicaDB); ok {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.False(t, set.replicas[0].healthy.Load())
+}
+
+func TestShouldFallback(t *testing.T) {
+	ctx := context.Background()
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	assert.False(t, shouldFallback(ctx, nil))
+	assert.True(t, shouldFallback(ctx, pgx.ErrNoRows))
+	assert.True(t, shouldFallback(ctx, &pgconn.PgError{Code: "40001"}))
+	assert.False(t, shouldFallback(ctx, &pgconn.PgError{Code: "42P01"}))
+	assert.False(t, shouldFallback(canceled, errors.New("canceled")))
+}
@@ -1,43 +1,49 @@
 package repository
 
 import (
+	"L0/internal/config"
+	"L0/internal/models"
 	"context"
-	"errors"
 	"fmt"
-	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/lib/pq"
 	"go.uber.org/zap"
-	"os"
-	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMaxConns = 10
+	defaultMinConns = 2
 )
 
 type Storage struct {
-	db  *pgxpool.Pool
-	log *zap.Logger
+	db           *pgxpool.Pool
+	replicas     *replicaSet
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	log          *zap.Logger
 }
 
-func NewStorage(ctx context.Context, user string, password string, host string, port string, dbname string, sslmode string, log *zap.Logger) (*Storage, error) {
-	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s", user, password, host, port, dbname, sslmode)
+// NewStorage подключается к PostgreSQL. При cfg.AutoMigrate применяет встроенные миграции,
+// после чего проверяет, что версия схемы совпадает с ожидаемой кодом.
+func NewStorage(ctx context.Context, cfg config.Storage, log *zap.Logger) (*Storage, error) {
+	connStr := ConnString(cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.DBName, cfg.SSLMode)
 	log = log.With(zap.String("type", "Storage"))
 
 	log.Info("Connecting to PostgreSQL database",
-		zap.String("dbname", dbname),
-		zap.String("user", user),
-		zap.String("sslmode", sslmode))
+		zap.String("dbname", cfg.DBName),
+		zap.String("user", cfg.User),
+		zap.String("sslmode", cfg.SSLMode))
 
-	config, err := pgxpool.ParseConfig(connStr)
+	poolConfig, err := newPoolConfig(connStr, cfg)
 	if err != nil {
 		log.Error("Error parsing connection string", zap.Error(err))
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
-	config.MaxConns = 10
-	config.MinConns = 2
-
-	db, err := pgxpool.NewWithConfig(ctx, config)
+	db, err := pgxpool.NewWithConfig(ctx, poolConfig)
 
 	if err != nil {
 		log.Error("Error connecting to PostgreSQL database", zap.Error(err))
@@ -45,46 +51,123 @@ func NewStorage(ctx context.Context, user string, password string, host string,
 	}
 
 	log.Info("Testing database connection")
-	if err := db.Ping(context.Background()); err != nil {
-		log.Error("Failed to ping PostgreSQL database", zap.String("dbname", dbname), zap.Error(err))
+	pingCtx, cancel := withTimeout(ctx, cfg.ConnectTimeout)
+	defer cancel()
+	if err := db.Ping(pingCtx); err != nil {
+		log.Error("Failed to ping PostgreSQL database", zap.String("dbname", cfg.DBName), zap.Error(err))
+		db.Close()
 		return nil, fmt.Errorf("failed to ping PostgreSQL database: %w", err)
 	}
 
 	log.Info("Successfully connected to database")
 
-	log.Info("Starting database migrations")
+	if err := prepareSchema(connStr, cfg.AutoMigrate, log); err != nil {
+		db.Close()
+		return nil, err
+	}
 
-	if err := runMigrations(connStr); err != nil {
-		log.Error("Failed to run migrations", zap.Error(err))
-		return nil, fmt.Errorf("failed to run migration: %w", err)
+	replicas, err := newReplicaSet(ctx, cfg, log)
+	if err != nil {
+		log.Error("Failed to initialize read replicas", zap.Error(err))
+		db.Close()
+		return nil, err
+	}
+	if len(cfg.Replicas) > 0 {
+		log.Info("Read replicas configured", zap.Int("replicas", len(cfg.Replicas)), zap.Duration("max_lag", replicas.maxLag))
 	}
-	log.Info("Successfully migrated database")
+
 	return &Storage{
-		db:  db,
-		log: log,
+		db:           db,
+		replicas:     replicas,
+		readTimeout:  cfg.ReadTimeout,
+		writeTimeout: cfg.WriteTimeout,
+		log:          log,
 	}, nil
 }
 
-func runMigrations(connStr string) error {
-	migratePath := os.Getenv("MIGRATE_PATH")
-	if migratePath == "" {
-		migratePath = "./migrations"
-	}
-	absPath, err := filepath.Abs(migratePath)
+// newPoolConfig переносит настройки пула из конфига. Нулевые значения оставляют дефолты pgxpool,
+// кроме размера пула, для которого сохранены прежние значения 10/2.
+func newPoolConfig(connStr string, cfg config.Storage) (*pgxpool.Config, error) {
+	poolConfig, err := pgxpool.ParseConfig(connStr)
 	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %w", err)
+		return nil, err
+	}
+
+	poolConfig.MaxConns = defaultMaxConns
+	if cfg.MaxConns > 0 {
+		poolConfig.MaxConns = cfg.MaxConns
+	}
+	poolConfig.MinConns = defaultMinConns
+	if cfg.MinConns > 0 {
+		poolConfig.MinConns = cfg.MinConns
+	}
+	if poolConfig.MinConns > poolConfig.MaxConns {
+		return nil, fmt.Errorf("min_conns (%d) is greater than max_conns (%d)", poolConfig.MinConns, poolConfig.MaxConns)
+	}
+	if cfg.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = cfg.MaxConnLifetime
+	}
+	if cfg.MaxConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = cfg.MaxConnIdleTime
 	}
-	absPath = filepath.ToSlash(absPath)
-	migrateUrl := fmt.Sprintf("file://%s", absPath)
-	m, err := migrate.New(migrateUrl, connStr)
+	if cfg.HealthCheckPeriod > 0 {
+		poolConfig.HealthCheckPeriod = cfg.HealthCheckPeriod
+	}
+	if cfg.ConnectTimeout > 0 {
+		poolConfig.ConnConfig.ConnectTimeout = cfg.ConnectTimeout
+	}
+	if cfg.StatementTimeout > 0 {
+		poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+	return poolConfig, nil
+}
+
+func poolStats(db *pgxpool.Pool) models.PoolStats {
+	stat := db.Stat()
+	return models.PoolStats{
+		MaxConns:                stat.MaxConns(),
+		TotalConns:              stat.TotalConns(),
+		IdleConns:               stat.IdleConns(),
+		AcquiredConns:           stat.AcquiredConns(),
+		ConstructingConns:       stat.ConstructingConns(),
+		AcquireCount:            stat.AcquireCount(),
+		AcquireDuration:         stat.AcquireDuration().String(),
+		EmptyAcquireCount:       stat.EmptyAcquireCount(),
+		CanceledAcquireCount:    stat.CanceledAcquireCount(),
+		NewConnsCount:           stat.NewConnsCount(),
+		MaxLifetimeDestroyCount: stat.MaxLifetimeDestroyCount(),
+		MaxIdleDestroyCount:     stat.MaxIdleDestroyCount(),
+	}
+}
+
+// withTimeout ограничивает ctx дедлайном, если timeout задан.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func prepareSchema(connStr string, autoMigrate bool, log *zap.Logger) error {
+	m, err := NewMigrator(connStr, log)
 	if err != nil {
-		return fmt.Errorf("start migrations error %v", err)
+		log.Error("Failed to initialize migrator", zap.Error(err))
+		return fmt.Errorf("failed to initialize migrator: %w", err)
 	}
-	if err := m.Up(); err != nil {
-		if errors.Is(err, migrate.ErrNoChange) {
-			return nil
+	defer m.Close()
+
+	if autoMigrate {
+		log.Info("Starting database migrations")
+		if err := m.Up(); err != nil {
+			log.Error("Failed to run migrations", zap.Error(err))
+			return fmt.Errorf("failed to run migration: %w", err)
 		}
-		return fmt.Errorf("migration up error: %v", err)
+		log.Info("Successfully migrated database")
+	}
+
+	if err := m.CheckVersion(); err != nil {
+		log.Error("Database schema is not compatible", zap.Error(err))
+		return err
 	}
 	return nil
 }
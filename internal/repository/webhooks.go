@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"L0/internal/models"
+	"L0/pkg/logger"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	webhookColumns     = `id, url, secret, events, COALESCE(delivery_service, ''), COALESCE(customer_id, ''), created_at`
+	createWebhookQuery = `
+        INSERT INTO webhook_subscriptions (url, secret, events, delivery_service, customer_id)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at
+    `
+	listWebhooksQuery  = `SELECT ` + webhookColumns + ` FROM webhook_subscriptions ORDER BY id`
+	deleteWebhookQuery = `DELETE FROM webhook_subscriptions WHERE id = $1`
+	webhookExistsQuery = `SELECT EXISTS (SELECT 1 FROM webhook_subscriptions WHERE id = $1)`
+	logDeliveryQuery   = `
+        INSERT INTO webhook_deliveries (subscription_id, event_id, event, order_uid, attempt, status_code, error, duration_ms, success)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        RETURNING id, created_at
+    `
+	listDeliveriesQuery = `
+        SELECT id, subscription_id, event_id, event, order_uid, attempt, COALESCE(status_code, 0), error, duration_ms, success, created_at
+        FROM webhook_deliveries
+        WHERE subscription_id = $1
+        ORDER BY id DESC
+        LIMIT $2
+    `
+)
+
+// CreateWebhook сохраняет подписку и заполняет ID и CreatedAt. Секрет шифруется так же, как данные доставки.
+func (r *Repository) CreateWebhook(ctx context.Context, sub *models.WebhookSubscription) error {
+	log := logger.FromContext(ctx, r.log)
+	ctx, cancel := withTimeout(ctx, r.writeTimeout)
+	defer cancel()
+
+	secret, err := r.cipher.Encrypt(sub.Secret)
+	if err != nil {
+		log.Error("Error encrypting webhook secret", zap.Error(err))
+		return fmt.Errorf("failed to encrypt webhook secret: %w", err)
+	}
+	events := sub.Events
+	if events == nil {
+		events = []string{}
+	}
+	err = r.db.QueryRow(ctx, createWebhookQuery, sub.URL, secret, events,
+		nullIfEmpty(sub.DeliveryService), nullIfEmpty(sub.CustomerID)).Scan(&sub.ID, &sub.CreatedAt)
+	if err != nil {
+		log.Error("Error creating webhook", zap.Error(err))
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+	log.Info("Created webhook", zap.Int64("webhook_id", sub.ID), zap.String("url", sub.URL))
+	return nil
+}
+
+// ListWebhooks возвращает все подписки без секретов.
+func (r *Repository) ListWebhooks(ctx context.Context) ([]models.WebhookSubscription, error) {
+	subs, err := r.webhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	return subs, nil
+}
+
+// ActiveWebhooks возвращает все подписки с расшифрованными секретами для подписи доставок.
+func (r *Repository) ActiveWebhooks(ctx context.Context) ([]models.WebhookSubscription, error) {
+	log := logger.FromContext(ctx, r.log)
+	subs, err := r.webhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range subs {
+		if subs[i].Secret, err = r.cipher.Decrypt(subs[i].Secret); err != nil {
+			log.Error("Error decrypting webhook secret", zap.Int64("webhook_id", subs[i].ID), zap.Error(err))
+			return nil, fmt.Errorf("failed to decrypt webhook secret: %w", err)
+		}
+	}
+	return subs, nil
+}
+
+// Подписки читаются только с primary: только что созданная подписка должна сразу получать события.
+func (r *Repository) webhooks(ctx context.Context) ([]models.WebhookSubscription, error) {
+	log := logger.FromContext(ctx, r.log)
+	ctx, cancel := withTimeout(ctx, r.readTimeout)
+	defer cancel()
+
+	rows, err := r.db.Query(ctx, listWebhooksQuery)
+	if err != nil {
+		log.Error("Error listing webhooks", zap.Error(err))
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	subs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.WebhookSubscription, error) {
+		var sub models.WebhookSubscription
+		err := row.Scan(&sub.ID, &sub.URL, &sub.Secret, &sub.Events, &sub.DeliveryService, &sub.CustomerID, &sub.CreatedAt)
+		return sub, err
+	})
+	if err != nil {
+		log.Error("Error listing webhooks", zap.Error(err))
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	return subs, nil
+}
+
+// DeleteWebhook удаляет подписку вместе с журналом её доставок.
+func (r *Repository) DeleteWebhook(ctx context.Context, id int64) error {
+	log := logger.FromContext(ctx, r.log)
+	ctx, cancel := withTimeout(ctx, r.writeTimeout)
+	defer cancel()
+
+	tag, err := r.db.Exec(ctx, deleteWebhookQuery, id)
+	if err != nil {
+		log.Error("Error deleting webhook", zap.Int64("webhook_id", id), zap.Error(err))
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return models.WebhookNotFoundError
+	}
+	log.Info("Deleted webhook", zap.Int64("webhook_id", id))
+	return nil
+}
+
+// LogWebhookDelivery записывает попытку доставки и заполняет ID и CreatedAt.
+func (r *Repository) LogWebhookDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	log := logger.FromContext(ctx, r.log)
+	ctx, cancel := withTimeout(ctx, r.writeTimeout)
+	defer cancel()
+
+	var statusCode *int
+	if d.StatusCode != 0 {
+		statusCode = &d.StatusCode
+	}
+	err := r.db.QueryRow(ctx, logDeliveryQuery, d.SubscriptionID, d.EventID, d.Event, d.OrderUID, d.Attempt,
+		statusCode, d.Error, d.DurationMs, d.Success).Scan(&d.ID, &d.CreatedAt)
+	if err != nil {
+		log.Error("Error logging webhook delivery", zap.Int64("webhook_id", d.SubscriptionID), zap.Error(err))
+		return fmt.Errorf("failed to log webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// ListWebhookDeliveries возвращает последние limit попыток доставки подписки, новые первыми.
+func (r *Repository) ListWebhookDeliveries(ctx context.Context, id int64, limit int) ([]models.WebhookDelivery, error) {
+	log := logger.FromContext(ctx, r.log)
+	ctx, cancel := withTimeout(ctx, r.readTimeout)
+	defer cancel()
+
+	var exists bool
+	if err := r.db.QueryRow(ctx, webhookExistsQuery, id).Scan(&exists); err != nil {
+		log.Error("Error checking webhook", zap.Int64("webhook_id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to check webhook: %w", err)
+	}
+	if !exists {
+		return nil, models.WebhookNotFoundError
+	}
+	rows, err := r.db.Query(ctx, listDeliveriesQuery, id, limit)
+	if err != nil {
+		log.Error("Error listing webhook deliveries", zap.Int64("webhook_id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	deliveries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.WebhookDelivery, error) {
+		var d models.WebhookDelivery
+		err := row.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.Event, &d.OrderUID, &d.Attempt,
+			&d.StatusCode, &d.Error, &d.DurationMs, &d.Success, &d.CreatedAt)
+		return d, err
+	})
+	if err != nil {
+		log.Error("Error listing webhook deliveries", zap.Int64("webhook_id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
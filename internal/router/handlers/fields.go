@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"L0/internal/models"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxItemsLimit - наибольший размер страницы товаров.
+const maxItemsLimit = 1000
+
+// fieldTree - набор запрошенных полей: ключ - имя поля в JSON, nil - поле целиком.
+type fieldTree map[string]fieldTree
+
+// orderFields - допустимые пути полей заказа, включая вложенные поля delivery, payment и items.
+var orderFields = func() map[string]bool {
+	data, _ := json.Marshal(models.Order{Items: []models.Item{{}}})
+	var doc map[string]any
+	_ = json.Unmarshal(data, &doc)
+	paths := map[string]bool{}
+	for name, value := range doc {
+		paths[name] = true
+		if list, ok := value.([]any); ok && len(list) > 0 {
+			value = list[0]
+		}
+		if nested, ok := value.(map[string]any); ok {
+			for child := range nested {
+				paths[name+"."+child] = true
+			}
+		}
+	}
+	return paths
+}()
+
+// orderQuery - параметры fields, items_limit и items_cursor запроса заказа.
+type orderQuery struct {
+	// fields == nil - все поля.
+	fields fieldTree
+	view   models.OrderView
+}
+
+func parseOrderQuery(fields string, itemsLimit string, itemsCursor string) (orderQuery, error) {
+	var q orderQuery
+	if fields != "" {
+		q.fields = fieldTree{}
+		for _, path := range strings.Split(fields, ",") {
+			path = strings.TrimSpace(path)
+			if path == "" {
+				continue
+			}
+			if !orderFields[path] {
+				return q, &models.ValidationError{Reason: fmt.Sprintf("unknown field %q", path)}
+			}
+			q.fields.add(path)
+		}
+		_, withItems := q.fields["items"]
+		q.view.SkipItems = !withItems
+	}
+	if itemsLimit != "" {
+		limit, err := strconv.Atoi(itemsLimit)
+		if err != nil || limit < 1 || limit > maxItemsLimit {
+			return q, &models.ValidationError{Reason: fmt.Sprintf("items_limit must be 1-%d", maxItemsLimit)}
+		}
+		q.view.ItemsLimit = limit
+	}
+	if itemsCursor != "" {
+		offset, err := decodeItemsCursor(itemsCursor)
+		if err != nil {
+			return q, &models.ValidationError{Reason: "invalid items_cursor"}
+		}
+		q.view.ItemsOffset = offset
+	}
+	return q, nil
+}
+
+func (t fieldTree) add(path string) {
+	name, child, nested := strings.Cut(path, ".")
+	sub, seen := t[name]
+	switch {
+	case !nested:
+		t[name] = nil
+	case seen && sub == nil:
+		// Поле уже запрошено целиком.
+	default:
+		if sub == nil {
+			sub = fieldTree{}
+			t[name] = sub
+		}
+		sub[child] = nil
+	}
+}
+
+// project оставляет в JSON документе только запрошенные поля, для массивов - в каждом элементе.
+func (t fieldTree) project(value any) any {
+	if t == nil {
+		return value
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(t))
+		for name, sub := range t {
+			if field, ok := v[name]; ok {
+				result[name] = sub.project(field)
+			}
+		}
+		return result
+	case []any:
+		for i := range v {
+			v[i] = t.project(v[i])
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+// Курсор товаров непрозрачен для клиента, внутри - смещение следующей страницы.
+func encodeItemsCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeItemsCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	offset, err := strconv.Atoi(string(raw))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid offset %q", raw)
+	}
+	return offset, nil
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"L0/internal/graphqlapi"
+	"L0/internal/models"
+	"L0/internal/router/problem"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+)
+
+// GraphQLHandlers принимает запросы GraphQL.
+type GraphQLHandlers struct {
+	server *graphqlapi.Server
+	log    *zap.Logger
+}
+
+func NewGraphQLHandlers(server *graphqlapi.Server, log *zap.Logger) *GraphQLHandlers {
+	return &GraphQLHandlers{server: server, log: log.Named("graphql")}
+}
+
+// Query выполняет запрос GraphQL: order(uid) и orders(filter, first, after) в виде Relay connection.
+// Эндпоинт /graphql лежит вне /api/v1 и не описан в Swagger, схема доступна через introspection.
+// Ошибки запроса и резолверов приходят со статусом 200 в "errors", 400 - только если тело не запрос GraphQL.
+func (h *GraphQLHandlers) Query(c *gin.Context) {
+	var req models.GraphQLRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Query) == "" {
+		problem.Error(c, &models.ValidationError{Reason: "body must be a JSON object with a non-empty query"})
+		return
+	}
+	c.JSON(http.StatusOK, h.server.Execute(c.Request.Context(), c.GetString("role"), req))
+}
+
+// Playground отдаёт GraphiQL для отладки запросов, маршрут есть только при log_level debug.
+func (h *GraphQLHandlers) Playground(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", graphqlapi.Playground(c.Request.URL.Path))
+}
@@ -1,50 +1,209 @@
 package handlers
 
 import (
+	"L0/internal/masking"
 	"L0/internal/models"
+	"L0/internal/router/middleware"
+	"L0/internal/router/problem"
 	"L0/internal/service"
+	"L0/pkg/logger"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 
 	"go.uber.org/zap"
 	"net/http"
+	"time"
 )
 
+// maxIDLength - ограничение на длину идентификаторов в пути, в базе они не длиннее.
+const maxIDLength = 64
+
 type OrderHandlers struct {
 	orderService *service.OrderService
+	masker       *masking.Masker
+	log          *zap.Logger
 }
 
-func NewOrderHandlers(orderService *service.OrderService) *OrderHandlers {
-	return &OrderHandlers{orderService: orderService}
+func NewOrderHandlers(orderService *service.OrderService, masker *masking.Masker, log *zap.Logger) *OrderHandlers {
+	return &OrderHandlers{orderService: orderService, masker: masker, log: log.Named("handlers")}
 }
 
 // GetOrder godoc
 // @Summary Get an order by UID
-// @Description Retrieves order details by its UID
+// @Description Retrieves order details by its UID. Delivery contacts are masked according to the caller role.
+// @Description When items are paginated and more remain, the response contains items_next_cursor.
 // @Tags orders
 // @Accept json
-// @Produce json
+// @Produce json,application/problem+json
 // @Param orderUID path string true "Order UID"
+// @Param fields query string false "Comma separated fields to return, e.g. order_uid,delivery,payment.amount. Items are not loaded unless requested"
+// @Param items_limit query int false "Page size for items, 1-1000"
+// @Param items_cursor query string false "items_next_cursor from the previous page"
+// @Param If-None-Match header string false "ETag from a previous response"
 // @Success 200 {object} models.Order
-// @Failure 404 {object} nil "Not Found"
-// @Failure 500 {object} nil "Internal Error"
+// @Header 200 {string} ETag "Strong validator of the response body"
+// @Header 200 {string} Last-Modified "Order creation time"
+// @Success 304 "Not Modified, the cached copy is still valid"
+// @Failure 400 {object} models.Problem "validation_failed"
+// @Failure 401 {object} models.Problem "unauthorized"
+// @Failure 403 {object} models.Problem "forbidden"
+// @Failure 404 {object} models.Problem "order_not_found"
+// @Failure 429 {object} models.Problem "rate_limited, see Retry-After"
+// @Failure 500 {object} models.Problem "internal_error"
+// @Failure 503 {object} models.Problem "dependency_unavailable"
+// @Failure 504 {object} models.Problem "timeout"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /orders/{orderUID} [get]
 func (h *OrderHandlers) GetOrder(c *gin.Context) {
-	log := c.Value("logger").(*zap.Logger)
+	log := logger.FromContext(c.Request.Context(), h.log)
 	log.Info("Handling getting order")
 
 	orderID := c.Param("orderUID")
+	if err := validateID("orderUID", orderID); err != nil {
+		problem.Error(c, err)
+		return
+	}
 
-	order, err := h.orderService.GetOrderByUID(c.Request.Context(), orderID)
+	query, err := parseOrderQuery(c.Query("fields"), c.Query("items_limit"), c.Query("items_cursor"))
+	if err != nil {
+		problem.Error(c, err)
+		return
+	}
+
+	page, err := h.orderService.GetOrderView(c.Request.Context(), orderID, query.view)
 	if err != nil {
 		if errors.Is(err, models.OrderNotFoundError) {
 			log.Warn("Order not found", zap.String("order_uid", orderID))
-			c.Status(http.StatusNotFound)
+		} else {
+			log.Error("Error getting order", zap.Error(err))
+		}
+		problem.Error(c, err)
+		return
+	}
+
+	order := h.masker.Order(c.GetString("role"), page.Order)
+	var body any = order
+	if page.HasMoreItems || query.fields != nil {
+		resp := orderResponse{Order: order}
+		if page.HasMoreItems {
+			resp.ItemsNextCursor = encodeItemsCursor(query.view.ItemsOffset + len(order.Items))
+		}
+		if body, err = project(resp, query.fields); err != nil {
+			problem.Error(c, err)
 			return
 		}
-		log.Error("Error getting order", zap.Error(err))
-		c.Status(http.StatusInternalServerError)
+	}
+	h.writeOrder(c, body, order.DateCreated)
+}
+
+// orderResponse - заказ со ссылкой на следующую страницу товаров.
+type orderResponse struct {
+	*models.Order
+	ItemsNextCursor string `json:"items_next_cursor,omitempty"`
+}
+
+// project применяет fields к ответу; курсор следующей страницы сохраняется всегда.
+func project(resp orderResponse, fields fieldTree) (any, error) {
+	if fields == nil {
+		return resp, nil
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode order: %w", err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode order: %w", err)
+	}
+	result := fields.project(doc).(map[string]any)
+	if resp.ItemsNextCursor != "" {
+		result["items_next_cursor"] = resp.ItemsNextCursor
+	}
+	return result, nil
+}
+
+// writeOrder отдаёт заказ с сильным ETag - хэшем тела ответа. Хэш берётся после маскирования,
+// поэтому у разных ролей и после стирания PII или ротации ключей ETag разный.
+// Last-Modified - время создания заказа; If-Modified-Since не проверяется, так как стирание PII
+// меняет заказ, но не дату, и ответ 304 по дате отдал бы клиенту старые данные.
+func (h *OrderHandlers) writeOrder(c *gin.Context, body any, dateCreated time.Time) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		problem.Error(c, fmt.Errorf("failed to encode order: %w", err))
+		return
+	}
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	if !dateCreated.IsZero() {
+		c.Header("Last-Modified", dateCreated.UTC().Format(http.TimeFormat))
+	}
+	if middleware.ETagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// GetDBStats godoc
+// @Summary PostgreSQL pool stats
+// @Description Returns a snapshot of the database connection pool for monitoring
+// @Tags monitoring
+// @Produce json,application/problem+json
+// @Success 200 {object} models.PoolStats
+// @Failure 401 {object} models.Problem "unauthorized"
+// @Failure 403 {object} models.Problem "forbidden"
+// @Failure 429 {object} models.Problem "rate_limited, see Retry-After"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /stats/db [get]
+func (h *OrderHandlers) GetDBStats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.orderService.DBStats())
+}
+
+// ErasePII godoc
+// @Summary Erase customer PII
+// @Description Anonymizes delivery data in all orders of the customer, evicts them from cache and records an audit entry. Payment and items are kept.
+// @Tags customers
+// @Produce json,application/problem+json
+// @Param customerID path string true "Customer ID"
+// @Success 200 {object} models.ErasureResult
+// @Failure 400 {object} models.Problem "validation_failed"
+// @Failure 401 {object} models.Problem "unauthorized"
+// @Failure 403 {object} models.Problem "forbidden"
+// @Failure 429 {object} models.Problem "rate_limited, see Retry-After"
+// @Failure 500 {object} models.Problem "internal_error"
+// @Failure 503 {object} models.Problem "dependency_unavailable"
+// @Failure 504 {object} models.Problem "timeout"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /customers/{customerID}/pii [delete]
+func (h *OrderHandlers) ErasePII(c *gin.Context) {
+	log := logger.FromContext(c.Request.Context(), h.log)
+	customerID := c.Param("customerID")
+	log.Info("Handling PII erasure", zap.String("customer_id", customerID))
+	if err := validateID("customerID", customerID); err != nil {
+		problem.Error(c, err)
+		return
+	}
+
+	result, err := h.orderService.ErasePII(c.Request.Context(), customerID, "api")
+	if err != nil {
+		log.Error("Error erasing PII", zap.Error(err))
+		problem.Error(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, order)
+	c.JSON(http.StatusOK, result)
+}
+
+func validateID(name string, value string) error {
+	if value == "" || len(value) > maxIDLength {
+		return &models.ValidationError{Reason: fmt.Sprintf("%s must be 1-%d characters", name, maxIDLength)}
+	}
+	return nil
 }
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"L0/internal/events"
+	"L0/internal/models"
+	"L0/internal/router/problem"
+	"L0/pkg/logger"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+// writeWait - сколько ждать записи в WebSocket, прежде чем считать клиента отвалившимся.
+const writeWait = 10 * time.Second
+
+// StreamHandlers отдаёт поток сводок обработанных заказов.
+type StreamHandlers struct {
+	hub       *events.Hub
+	heartbeat time.Duration
+	upgrader  websocket.Upgrader
+	log       *zap.Logger
+}
+
+// NewStreamHandlers создаёт обработчики потока. heartbeat - период пустых сообщений, которые не дают
+// прокси закрыть простаивающее соединение.
+func NewStreamHandlers(hub *events.Hub, heartbeat time.Duration, log *zap.Logger) *StreamHandlers {
+	if heartbeat <= 0 {
+		heartbeat = 15 * time.Second
+	}
+	return &StreamHandlers{hub: hub, heartbeat: heartbeat, log: log.Named("stream")}
+}
+
+// StreamOrders godoc
+// @Summary Stream of newly processed orders
+// @Description Server-Sent Events: each "order" event carries a summary of an order processed by the consumer.
+// @Description A client that does not keep up receives a "dropped" event and is disconnected.
+// @Description The same stream is available over WebSocket at /orders/stream/ws.
+// @Tags orders
+// @Produce text/event-stream,application/problem+json
+// @Param delivery_service query string false "Only orders of this delivery service"
+// @Param customer_id query string false "Only orders of this customer"
+// @Success 200 {object} models.OrderSummary "Event stream, data of each event is an order summary"
+// @Failure 400 {object} models.Problem "validation_failed"
+// @Failure 401 {object} models.Problem "unauthorized"
+// @Failure 403 {object} models.Problem "forbidden"
+// @Failure 429 {object} models.Problem "rate_limited, see Retry-After"
+// @Failure 503 {object} models.Problem "dependency_unavailable, too many subscribers"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /orders/stream [get]
+func (h *StreamHandlers) StreamOrders(c *gin.Context) {
+	sub, ok := h.subscribe(c)
+	if !ok {
+		return
+	}
+	defer sub.Close()
+	log := logger.FromContext(c.Request.Context(), h.log)
+	log.Info("SSE client subscribed", zap.Int("subscribers", h.hub.Subscribers()))
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-ticker.C:
+			fmt.Fprint(c.Writer, ": ping\n\n")
+			c.Writer.Flush()
+		case summary, ok := <-sub.Events():
+			if !ok {
+				if sub.Dropped() {
+					log.Warn("SSE client dropped as too slow")
+					fmt.Fprint(c.Writer, "event: dropped\ndata: {\"reason\":\"slow consumer\"}\n\n")
+					c.Writer.Flush()
+				}
+				return
+			}
+			data, err := json.Marshal(summary)
+			if err != nil {
+				log.Error("Error encoding order summary", zap.Error(err))
+				continue
+			}
+			fmt.Fprintf(c.Writer, "event: order\nid: %s\ndata: %s\n\n", summary.OrderUID, data)
+			c.Writer.Flush()
+		}
+	}
+}
+
+// StreamOrdersWS - тот же поток через WebSocket: каждая сводка - отдельное текстовое JSON сообщение.
+// Медленный клиент закрывается с кодом 1013 (try again later).
+func (h *StreamHandlers) StreamOrdersWS(c *gin.Context) {
+	sub, ok := h.subscribe(c)
+	if !ok {
+		return
+	}
+	defer sub.Close()
+	log := logger.FromContext(c.Request.Context(), h.log)
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade уже ответил клиенту ошибкой.
+		log.Warn("WebSocket upgrade failed", zap.Error(err))
+		return
+	}
+	defer conn.Close()
+	log.Info("WebSocket client subscribed", zap.Int("subscribers", h.hub.Subscribers()))
+
+	// Чтение нужно, чтобы обрабатывать ping/close от клиента; входящие сообщения игнорируются.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return
+			}
+		case summary, ok := <-sub.Events():
+			if !ok {
+				code, reason := websocket.CloseGoingAway, "server shutting down"
+				if sub.Dropped() {
+					log.Warn("WebSocket client dropped as too slow")
+					code, reason = websocket.CloseTryAgainLater, "slow consumer"
+				}
+				_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(writeWait))
+				return
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteJSON(summary); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// subscribe проверяет фильтры и подписывает клиента, при ошибке ответ уже отправлен.
+func (h *StreamHandlers) subscribe(c *gin.Context) (*events.Subscription, bool) {
+	filter := events.Filter{DeliveryService: c.Query("delivery_service"), CustomerID: c.Query("customer_id")}
+	if len(filter.DeliveryService) > maxIDLength || len(filter.CustomerID) > maxIDLength {
+		problem.Error(c, &models.ValidationError{Reason: fmt.Sprintf("filters must be at most %d characters", maxIDLength)})
+		return nil, false
+	}
+	sub, err := h.hub.Subscribe(filter)
+	if err != nil {
+		detail := "order stream is shutting down"
+		if errors.Is(err, events.ErrTooManySubscribers) {
+			detail = "too many stream subscribers, retry later"
+		}
+		c.Header("Retry-After", "5")
+		problem.Write(c, http.StatusServiceUnavailable, problem.CodeUnavailable, detail)
+		return nil, false
+	}
+	return sub, true
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"L0/internal/models"
+	"L0/internal/router/problem"
+	"L0/internal/webhook"
+	"L0/pkg/logger"
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+)
+
+const (
+	maxURLLength       = 2048
+	minSecretLength    = 16
+	defaultDeliveries  = 50
+	maxDeliveriesLimit = 500
+)
+
+// webhookEvents - события, на которые можно подписаться.
+var webhookEvents = []string{models.EventOrderCreated}
+
+// WebhookStore - хранилище подписок на вебхуки.
+type WebhookStore interface {
+	CreateWebhook(ctx context.Context, sub *models.WebhookSubscription) error
+	ListWebhooks(ctx context.Context) ([]models.WebhookSubscription, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	ListWebhookDeliveries(ctx context.Context, id int64, limit int) ([]models.WebhookDelivery, error)
+}
+
+// WebhookHandlers управляет подписками на вебхуки.
+type WebhookHandlers struct {
+	store WebhookStore
+	guard *webhook.Guard
+	log   *zap.Logger
+}
+
+func NewWebhookHandlers(store WebhookStore, guard *webhook.Guard, log *zap.Logger) *WebhookHandlers {
+	return &WebhookHandlers{store: store, guard: guard, log: log.Named("webhooks")}
+}
+
+// CreateWebhook godoc
+// @Summary Create a webhook subscription
+// @Description Subscribes a URL to order events. Each delivery is a POST signed in X-Webhook-Signature: t=<unix>,v1=<hex HMAC-SHA256 of "<t>.<body>">.
+// @Description The secret is returned only in this response. Empty events and filters mean all.
+// @Tags webhooks
+// @Accept json
+// @Produce json,application/problem+json
+// @Param subscription body models.WebhookRequest true "Subscription"
+// @Success 201 {object} models.WebhookSubscription
+// @Failure 400 {object} models.Problem "validation_failed"
+// @Failure 401 {object} models.Problem "unauthorized"
+// @Failure 403 {object} models.Problem "forbidden"
+// @Failure 429 {object} models.Problem "rate_limited, see Retry-After"
+// @Failure 500 {object} models.Problem "internal_error"
+// @Failure 503 {object} models.Problem "dependency_unavailable"
+// @Failure 504 {object} models.Problem "timeout"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /webhooks [post]
+func (h *WebhookHandlers) CreateWebhook(c *gin.Context) {
+	log := logger.FromContext(c.Request.Context(), h.log)
+	var req models.WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Error(c, &models.ValidationError{Reason: "invalid request body"})
+		return
+	}
+	if err := validateWebhook(req); err != nil {
+		problem.Error(c, err)
+		return
+	}
+	if err := h.guard.CheckURL(c.Request.Context(), req.URL); err != nil {
+		log.Warn("Webhook target rejected", zap.String("url", req.URL), zap.Error(err))
+		problem.Error(c, &models.ValidationError{Reason: "url must resolve to a public address"})
+		return
+	}
+	sub := &models.WebhookSubscription{
+		URL:             req.URL,
+		Secret:          req.Secret,
+		Events:          req.Events,
+		DeliveryService: req.DeliveryService,
+		CustomerID:      req.CustomerID,
+	}
+	if sub.Secret == "" {
+		secret, err := webhook.NewSecret()
+		if err != nil {
+			log.Error("Error generating webhook secret", zap.Error(err))
+			problem.Error(c, err)
+			return
+		}
+		sub.Secret = secret
+	}
+	if sub.Events == nil {
+		sub.Events = []string{}
+	}
+	if err := h.store.CreateWebhook(c.Request.Context(), sub); err != nil {
+		log.Error("Error creating webhook", zap.Error(err))
+		problem.Error(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, sub)
+}
+
+// ListWebhooks godoc
+// @Summary List webhook subscriptions
+// @Description Secrets are not returned.
+// @Tags webhooks
+// @Produce json,application/problem+json
+// @Success 200 {array} models.WebhookSubscription
+// @Failure 401 {object} models.Problem "unauthorized"
+// @Failure 403 {object} models.Problem "forbidden"
+// @Failure 429 {object} models.Problem "rate_limited, see Retry-After"
+// @Failure 500 {object} models.Problem "internal_error"
+// @Failure 503 {object} models.Problem "dependency_unavailable"
+// @Failure 504 {object} models.Problem "timeout"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /webhooks [get]
+func (h *WebhookHandlers) ListWebhooks(c *gin.Context) {
+	log := logger.FromContext(c.Request.Context(), h.log)
+	subs, err := h.store.ListWebhooks(c.Request.Context())
+	if err != nil {
+		log.Error("Error listing webhooks", zap.Error(err))
+		problem.Error(c, err)
+		return
+	}
+	if subs == nil {
+		subs = []models.WebhookSubscription{}
+	}
+	c.JSON(http.StatusOK, subs)
+}
+
+// DeleteWebhook godoc
+// @Summary Delete a webhook subscription
+// @Description Deletes the subscription together with its delivery log. Deliveries already in flight may still arrive.
+// @Tags webhooks
+// @Produce application/problem+json
+// @Param webhookID path int true "Webhook ID"
+// @Success 204 "Deleted"
+// @Failure 400 {object} models.Problem "validation_failed"
+// @Failure 401 {object} models.Problem "unauthorized"
+// @Failure 403 {object} models.Problem "forbidden"
+// @Failure 404 {object} models.Problem "webhook_not_found"
+// @Failure 429 {object} models.Problem "rate_limited, see Retry-After"
+// @Failure 500 {object} models.Problem "internal_error"
+// @Failure 503 {object} models.Problem "dependency_unavailable"
+// @Failure 504 {object} models.Problem "timeout"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /webhooks/{webhookID} [delete]
+func (h *WebhookHandlers) DeleteWebhook(c *gin.Context) {
+	log := logger.FromContext(c.Request.Context(), h.log)
+	id, err := parseWebhookID(c.Param("webhookID"))
+	if err != nil {
+		problem.Error(c, err)
+		return
+	}
+	if err := h.store.DeleteWebhook(c.Request.Context(), id); err != nil {
+		log.Error("Error deleting webhook", zap.Int64("webhook_id", id), zap.Error(err))
+		problem.Error(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries godoc
+// @Summary Webhook delivery log
+// @Description Latest delivery attempts of the subscription, newest first. Every retry is a separate entry with the same event_id.
+// @Tags webhooks
+// @Produce json,application/problem+json
+// @Param webhookID path int true "Webhook ID"
+// @Param limit query int false "Number of entries, 1-500, default 50"
+// @Success 200 {array} models.WebhookDelivery
+// @Failure 400 {object} models.Problem "validation_failed"
+// @Failure 401 {object} models.Problem "unauthorized"
+// @Failure 403 {object} models.Problem "forbidden"
+// @Failure 404 {object} models.Problem "webhook_not_found"
+// @Failure 429 {object} models.Problem "rate_limited, see Retry-After"
+// @Failure 500 {object} models.Problem "internal_error"
+// @Failure 503 {object} models.Problem "dependency_unavailable"
+// @Failure 504 {object} models.Problem "timeout"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /webhooks/{webhookID}/deliveries [get]
+func (h *WebhookHandlers) ListWebhookDeliveries(c *gin.Context) {
+	log := logger.FromContext(c.Request.Context(), h.log)
+	id, err := parseWebhookID(c.Param("webhookID"))
+	if err != nil {
+		problem.Error(c, err)
+		return
+	}
+	limit := defaultDeliveries
+	if value := c.Query("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxDeliveriesLimit {
+			problem.Error(c, &models.ValidationError{Reason: fmt.Sprintf("limit must be 1-%d", maxDeliveriesLimit)})
+			return
+		}
+	}
+	deliveries, err := h.store.ListWebhookDeliveries(c.Request.Context(), id, limit)
+	if err != nil {
+		log.Error("Error listing webhook deliveries", zap.Int64("webhook_id", id), zap.Error(err))
+		problem.Error(c, err)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []models.WebhookDelivery{}
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+func parseWebhookID(value string) (int64, error) {
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 1 {
+		return 0, &models.ValidationError{Reason: "webhookID must be a positive integer"}
+	}
+	return id, nil
+}
+
+func validateWebhook(req models.WebhookRequest) error {
+	target, err := url.Parse(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" || len(req.URL) > maxURLLength {
+		return &models.ValidationError{Reason: fmt.Sprintf("url must be an absolute http(s) URL of at most %d characters", maxURLLength)}
+	}
+	if req.Secret != "" && len(req.Secret) < minSecretLength {
+		return &models.ValidationError{Reason: fmt.Sprintf("secret must be at least %d characters", minSecretLength)}
+	}
+	for _, event := range req.Events {
+		if !slices.Contains(webhookEvents, event) {
+			return &models.ValidationError{Reason: fmt.Sprintf("unknown event %q", event)}
+		}
+	}
+	if len(req.DeliveryService) > maxIDLength || len(req.CustomerID) > maxIDLength {
+		return &models.ValidationError{Reason: fmt.Sprintf("filters must be at most %d characters", maxIDLength)}
+	}
+	return nil
+}
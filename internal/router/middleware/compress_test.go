@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"L0/internal/config"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	assert.Equal(t, "br", negotiateEncoding("gzip, deflate, br"))
+	assert.Equal(t, "gzip", negotiateEncoding("gzip, br;q=0"))
+	assert.Equal(t, "gzip", negotiateEncoding("GZIP;q=0.5"))
+	assert.Equal(t, "", negotiateEncoding("identity"))
+	assert.Equal(t, "", negotiateEncoding(""))
+}
+
+func TestCompressionMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	large := strings.Repeat(`{"order_uid":"b563feb7b2b84b6test"}`, 100)
+	engine := gin.New()
+	engine.Use(CompressionMiddleware(config.Compression{Enabled: true, MinSize: 256}))
+	engine.GET("/large", func(c *gin.Context) {
+		c.Header("ETag", `"abc"`)
+		c.Data(http.StatusOK, "application/json; charset=utf-8", []byte(large))
+	})
+	engine.GET("/small", func(c *gin.Context) { c.Data(http.StatusOK, "application/json", []byte(`{}`)) })
+	engine.GET("/binary", func(c *gin.Context) { c.Data(http.StatusOK, "image/png", []byte(large)) })
+
+	get := func(path string, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/large", "gzip, br")
+	assert.Equal(t, "br", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, `"abc-br"`, w.Header().Get("ETag"))
+	assert.Contains(t, w.Header().Values("Vary"), "Accept-Encoding")
+	body, err := io.ReadAll(brotli.NewReader(w.Body))
+	require.NoError(t, err)
+	assert.Equal(t, large, string(body))
+
+	w = get("/large", "gzip")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	zr, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	body, err = io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, large, string(body))
+
+	w = get("/large", "")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, large, w.Body.String())
+
+	w = get("/small", "br")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, `{}`, w.Body.String())
+
+	w = get("/binary", "br")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, large, w.Body.String())
+}
+
+func TestETagMatches(t *testing.T) {
+	assert.True(t, ETagMatches(`"abc"`, `"abc"`))
+	assert.True(t, ETagMatches(`"x", "abc-gzip"`, `"abc"`))
+	assert.True(t, ETagMatches(`W/"abc-br"`, `"abc"`))
+	assert.True(t, ETagMatches(`*`, `"abc"`))
+	assert.False(t, ETagMatches(`"abd"`, `"abc"`))
+	assert.False(t, ETagMatches(``, `"abc"`))
+}
@@ -1,22 +1,265 @@
 package middleware
 
 import (
+	"L0/internal/auth"
+	"L0/internal/config"
+	"L0/internal/ratelimit"
+	"L0/internal/router/problem"
+	"L0/pkg/logger"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
-func LoggingMiddleware(log *zap.Logger) gin.HandlerFunc {
+// RequestIDHeader - заголовок с correlation id запроса. Пришедший от клиента id сохраняется,
+// иначе генерируется новый; в ответ id возвращается в том же заголовке.
+const RequestIDHeader = "X-Request-ID"
+
+func RequestIDMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		requestLog := log.With(
+		requestID := c.GetHeader(RequestIDHeader)
+		if !logger.ValidRequestID(requestID) {
+			requestID = logger.NewRequestID()
+		}
+		c.Header(RequestIDHeader, requestID)
+		c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), requestID))
+		c.Next()
+	}
+}
+
+// LoggingMiddleware добавляет параметры запроса к логгеру в контексте запроса (обработчики, сервис и
+// репозиторий получают его через logger.FromContext) и по завершении пишет одну запись журнала
+// со статусом, временем ответа, размером тела и шаблоном маршрута.
+func LoggingMiddleware(log *zap.Logger, cfg config.AccessLog) gin.HandlerFunc {
+	var successCount atomic.Uint64
+	return func(c *gin.Context) {
+		start := time.Now()
+		ctx := logger.WithFields(c.Request.Context(),
 			zap.String("method", c.Request.Method),
 			zap.String("path", c.Request.URL.Path),
 			zap.String("remote_addr", c.Request.RemoteAddr),
 		)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if excludedPath(c.Request.URL.Path, cfg.ExcludePaths) {
+			return
+		}
+		latency := time.Since(start)
+		status := c.Writer.Status()
+		slow := cfg.SlowThreshold > 0 && latency > cfg.SlowThreshold && !streaming(c)
+		if status < 300 && !slow && cfg.SuccessSampleEvery > 1 &&
+			successCount.Add(1)%uint64(cfg.SuccessSampleEvery) != 1 {
+			return
+		}
 
-		requestLog.Info("Request started")
+		fields := []zap.Field{
+			zap.String("route", c.FullPath()),
+			zap.Int("status", status),
+			zap.Duration("latency", latency),
+			zap.Int("bytes", max(c.Writer.Size(), 0)),
+		}
+		if len(c.Errors) > 0 {
+			fields = append(fields, zap.String("errors", c.Errors.String()))
+		}
+		requestLog := logger.FromContext(ctx, log)
+		switch {
+		case status >= 500:
+			requestLog.Error("Request completed", fields...)
+		case slow:
+			requestLog.Warn("Slow request", fields...)
+		case status >= 400:
+			requestLog.Warn("Request completed", fields...)
+		default:
+			requestLog.Info("Request completed", fields...)
+		}
+	}
+}
 
-		c.Set("logger", requestLog)
+// streaming - долгоживущие SSE и WebSocket соединения, их длительность не считается медленным ответом.
+func streaming(c *gin.Context) bool {
+	return c.IsWebsocket() || strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "text/event-stream")
+}
+
+func excludedPath(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// RecoveryMiddleware отвечает 500 на панику в обработчике и пишет её в лог запроса.
+func RecoveryMiddleware(log *zap.Logger) gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, recovered any) {
+		logger.FromContext(c.Request.Context(), log).Error("Panic recovered", zap.Any("panic", recovered), zap.Stack("stack"))
+		problem.Write(c, http.StatusInternalServerError, problem.CodeInternal, "internal error")
+	})
+}
+
+// DeprecationMiddleware помечает устаревший маршрут заголовками Deprecation (RFC 9745), Sunset (RFC 8594)
+// и ссылкой на замену. Нулевые since и sunset не выставляют свой заголовок. В successor параметры
+// вида :name подставляются из пути запроса.
+func DeprecationMiddleware(since time.Time, sunset time.Time, successor string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		link := successor
+		for _, p := range c.Params {
+			link = strings.Replace(link, ":"+p.Key, url.PathEscape(p.Value), 1)
+		}
+		if !since.IsZero() {
+			c.Header("Deprecation", "@"+strconv.FormatInt(since.Unix(), 10))
+		}
+		if !sunset.IsZero() {
+			c.Header("Sunset", sunset.UTC().Format(http.TimeFormat))
+		}
+		c.Header("Link", "<"+link+`>; rel="successor-version"`)
 		c.Next()
-		requestLog.Info("Request completed")
 	}
 }
+
+// CacheControlMiddleware выставляет Cache-Control ответа. Содержимое зависит от учётных данных
+// (роль определяет маскирование), поэтому они перечислены в Vary. Ошибки problem.Write
+// помечает no-store, так что 404 для ещё не доехавшего заказа не закэшируется.
+func CacheControlMiddleware(value string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", value)
+		c.Writer.Header().Add("Vary", auth.APIKeyHeader+", Authorization")
+		c.Next()
+	}
+}
+
+// RoleMiddleware кладёт в контекст роль вызывающего для маскирования ответов. Роль из учётных данных
+// (AuthMiddleware) не переопределяется, аутентифицированный вызывающий без роли получает defaultRole.
+// Заголовок header, который должен выставлять доверенный шлюз, читается только для запросов без
+// учётных данных, поэтому при включённой аутентификации его не нужно передавать.
+func RoleMiddleware(header string, defaultRole string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("role"); ok {
+			c.Next()
+			return
+		}
+		role := defaultRole
+		if _, authenticated := c.Get("principal"); !authenticated && header != "" {
+			if value := c.GetHeader(header); value != "" {
+				role = value
+			}
+		}
+		c.Set("role", role)
+		c.Next()
+	}
+}
+
+// AuthMiddleware проверяет учётные данные и кладёт в контекст *auth.Principal под ключом "principal"
+// и его роль. Неверные учётные данные сразу дают 401, запрос без них проходит дальше:
+// доступ к конкретному маршруту решает RequireScope.
+func AuthMiddleware(authn *auth.Authenticator, log *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, err := authn.Authenticate(c.Request)
+		if errors.Is(err, auth.ErrNoCredentials) {
+			c.Next()
+			return
+		}
+		if err != nil {
+			logger.FromContext(c.Request.Context(), log).Warn("Authentication failed", zap.Error(err))
+			c.Header("WWW-Authenticate", "Bearer")
+			problem.Write(c, http.StatusUnauthorized, problem.CodeUnauthorized, "invalid credentials")
+			return
+		}
+		c.Set("principal", principal)
+		if principal.Role != "" {
+			c.Set("role", principal.Role)
+		}
+		c.Next()
+	}
+}
+
+// RequireScope пропускает запрос, только если у вызывающего есть scope (или admin).
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := c.Value("principal").(*auth.Principal)
+		if !ok {
+			c.Header("WWW-Authenticate", "Bearer")
+			problem.Write(c, http.StatusUnauthorized, problem.CodeUnauthorized, "authentication required")
+			return
+		}
+		if !principal.HasScope(scope) {
+			problem.Write(c, http.StatusForbidden, problem.CodeForbidden, "missing scope "+scope)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RateLimitMiddleware ограничивает частоту запросов клиента к маршруту. Клиент - аутентифицированный
+// субъект, иначе IP. При недоступности Redis запрос пропускается: лимит защищает хранилище,
+// а не должен класть API вместе с Redis.
+func RateLimitMiddleware(limiter *ratelimit.Limiter, cfg config.RateLimit, log *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.Request.Method + " " + c.FullPath()
+		rule, ok := cfg.Routes[route]
+		if !ok {
+			rule = cfg.Default
+		}
+		if rule.Rate <= 0 || rule.Burst <= 0 {
+			c.Next()
+			return
+		}
+
+		client := "ip:" + c.ClientIP()
+		if principal, ok := c.Value("principal").(*auth.Principal); ok {
+			client = "sub:" + principal.Subject
+		}
+		res, err := limiter.Allow(c.Request.Context(), route+":"+client, ratelimit.Limit{Rate: rule.Rate, Burst: rule.Burst})
+		if err != nil {
+			logger.FromContext(c.Request.Context(), log).Warn("Rate limiter unavailable, request allowed", zap.String("route", route), zap.Error(err))
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		if !res.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			problem.Write(c, http.StatusTooManyRequests, problem.CodeRateLimited, "rate limit exceeded")
+			return
+		}
+		c.Next()
+	}
+}
+
+// IPRateLimitMiddleware ограничивает все запросы с одного IP и ставится до AuthMiddleware: лимит маршрута
+// считается по субъекту, и без этого запросы с неверными ключами не расходовали бы ничьё ведро.
+func IPRateLimitMiddleware(limiter *ratelimit.Limiter, rule config.RateLimitRule, log *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rule.Rate <= 0 || rule.Burst <= 0 {
+			c.Next()
+			return
+		}
+		res, err := limiter.Allow(c.Request.Context(), "ip:"+c.ClientIP(), ratelimit.Limit{Rate: rule.Rate, Burst: rule.Burst})
+		if err != nil {
+			logger.FromContext(c.Request.Context(), log).Warn("Rate limiter unavailable, request allowed", zap.Error(err))
+			c.Next()
+			return
+		}
+		if !res.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			problem.Write(c, http.StatusTooManyRequests, problem.CodeRateLimited, "rate limit exceeded")
+			return
+		}
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,222 @@
+package middleware
+
+import (
+	"L0/internal/auth"
+	"L0/internal/config"
+	"L0/internal/ratelimit"
+	"L0/pkg/logger"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newAuthEngine(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	authn, err := auth.New(config.Auth{APIKeys: []config.APIKey{
+		{Name: "reader", Hash: auth.HashAPIKey("read-key"), Scopes: []string{auth.ScopeOrdersRead}},
+		{Name: "admin", Hash: auth.HashAPIKey("admin-key"), Scopes: []string{auth.ScopeAdmin}, Role: "admin"},
+	}})
+	require.NoError(t, err)
+
+	engine := gin.New()
+	engine.Use(AuthMiddleware(authn, zap.NewNop()), RoleMiddleware("", "support"))
+	engine.GET("/order", RequireScope(auth.ScopeOrdersRead), func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("role"))
+	})
+	engine.GET("/stats", RequireScope(auth.ScopeAdmin), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return engine
+}
+
+func serve(engine *gin.Engine, path string, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if key != "" {
+		req.Header.Set(auth.APIKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequireScope(t *testing.T) {
+	engine := newAuthEngine(t)
+
+	tests := []struct {
+		name   string
+		path   string
+		key    string
+		status int
+	}{
+		{"no credentials", "/order", "", http.StatusUnauthorized},
+		{"invalid key", "/order", "wrong", http.StatusUnauthorized},
+		{"reader reads orders", "/order", "read-key", http.StatusOK},
+		{"reader is not admin", "/stats", "read-key", http.StatusForbidden},
+		{"admin has every scope", "/order", "admin-key", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.status, serve(engine, tt.path, tt.key).Code)
+		})
+	}
+}
+
+func TestRoleMiddleware_PrefersPrincipalRole(t *testing.T) {
+	engine := newAuthEngine(t)
+
+	assert.Equal(t, "admin", serve(engine, "/order", "admin-key").Body.String())
+	assert.Equal(t, "support", serve(engine, "/order", "read-key").Body.String())
+}
+
+func TestRoleMiddleware_HeaderIgnoredForPrincipal(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authn, err := auth.New(config.Auth{APIKeys: []config.APIKey{
+		{Name: "reader", Hash: auth.HashAPIKey("read-key"), Scopes: []string{auth.ScopeOrdersRead}},
+	}})
+	require.NoError(t, err)
+	engine := gin.New()
+	engine.Use(AuthMiddleware(authn, zap.NewNop()), RoleMiddleware("X-Role", "support"))
+	engine.GET("/order", func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("role"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/order", nil)
+	req.Header.Set(auth.APIKeyHeader, "read-key")
+	req.Header.Set("X-Role", "admin")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, "support", w.Body.String(), "роль аутентифицированного вызывающего не берётся из заголовка")
+
+	req = httptest.NewRequest(http.MethodGet, "/order", nil)
+	req.Header.Set("X-Role", "admin")
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, "admin", w.Body.String(), "без аутентификации заголовок шлюза читается")
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	engine := gin.New()
+	engine.Use(RateLimitMiddleware(ratelimit.NewLimiter(client), config.RateLimit{
+		Default: config.RateLimitRule{Rate: 1, Burst: 1},
+		Routes:  map[string]config.RateLimitRule{"GET /free": {}},
+	}, zap.NewNop()))
+	engine.GET("/order/:orderUID", func(c *gin.Context) { c.Status(http.StatusOK) })
+	engine.GET("/free", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	first := serve(engine, "/order/1", "")
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, "1", first.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", first.Header().Get("X-RateLimit-Remaining"))
+
+	second := serve(engine, "/order/2", "")
+	assert.Equal(t, http.StatusTooManyRequests, second.Code)
+	assert.Equal(t, "1", second.Header().Get("Retry-After"))
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, serve(engine, "/free", "").Code)
+	}
+
+	mr.Close()
+	assert.Equal(t, http.StatusOK, serve(engine, "/order/3", "").Code, "requests pass when Redis is down")
+}
+
+func TestIPRateLimitMiddleware_ChargesFailedAuth(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	authn, err := auth.New(config.Auth{APIKeys: []config.APIKey{
+		{Name: "reader", Hash: auth.HashAPIKey("read-key"), Scopes: []string{auth.ScopeOrdersRead}},
+	}})
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(IPRateLimitMiddleware(ratelimit.NewLimiter(client), config.RateLimitRule{Rate: 1, Burst: 2}, zap.NewNop()),
+		AuthMiddleware(authn, zap.NewNop()))
+	engine.GET("/order", RequireScope(auth.ScopeOrdersRead), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	assert.Equal(t, http.StatusUnauthorized, serve(engine, "/order", "guess-1").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(engine, "/order", "guess-2").Code)
+	limited := serve(engine, "/order", "read-key")
+	assert.Equal(t, http.StatusTooManyRequests, limited.Code, "the key is not checked once the IP is out of tokens")
+	assert.Equal(t, "1", limited.Header().Get("Retry-After"))
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(RequestIDMiddleware())
+	engine.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, logger.RequestID(c.Request.Context()))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "client-id-1")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, "client-id-1", w.Header().Get(RequestIDHeader))
+	assert.Equal(t, "client-id-1", w.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "bad id\n")
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	generated := w.Header().Get(RequestIDHeader)
+	assert.Len(t, generated, 32)
+	assert.Equal(t, generated, w.Body.String())
+}
+
+func TestLoggingMiddleware_AccessLog(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(zap.DebugLevel)
+	engine := gin.New()
+	engine.Use(RequestIDMiddleware(), LoggingMiddleware(zap.New(core), config.AccessLog{
+		SuccessSampleEvery: 2,
+		SlowThreshold:      20 * time.Millisecond,
+		ExcludePaths:       []string{"/swagger"},
+	}))
+	engine.GET("/order/:orderUID", func(c *gin.Context) {
+		if c.Param("orderUID") == "missing" {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		if c.Param("orderUID") == "slow" {
+			time.Sleep(30 * time.Millisecond)
+		}
+		c.String(http.StatusOK, "ok")
+	})
+	engine.GET("/swagger/*any", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, path := range []string{"/order/1", "/order/2", "/order/3", "/order/missing", "/order/slow", "/swagger/index.html"} {
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	entries := logs.All()
+	var paths []string
+	for _, e := range entries {
+		paths = append(paths, e.ContextMap()["path"].(string))
+	}
+	assert.Equal(t, []string{"/order/1", "/order/3", "/order/missing", "/order/slow"}, paths)
+
+	first := entries[0].ContextMap()
+	assert.Equal(t, "/order/:orderUID", first["route"])
+	assert.EqualValues(t, http.StatusOK, first["status"])
+	assert.EqualValues(t, 2, first["bytes"])
+	assert.NotEmpty(t, first["request_id"])
+	assert.Equal(t, zap.WarnLevel, entries[2].Level)
+	assert.Equal(t, "Slow request", entries[3].Message)
+}
@@ -0,0 +1,94 @@
+// Package problem формирует ошибки HTTP API в формате RFC 7807 (application/problem+json).
+// Поле code стабильно и предназначено для клиентов, title и detail - для людей.
+package problem
+
+import (
+	"L0/internal/models"
+	"L0/pkg/logger"
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"net"
+	"net/http"
+)
+
+const ContentType = "application/problem+json"
+
+// Стабильные коды ошибок. Менять значения нельзя: на них завязаны клиенты.
+const (
+	CodeOrderNotFound   = "order_not_found"
+	CodeWebhookNotFound = "webhook_not_found"
+	CodeNotFound        = "not_found"
+	CodeValidation      = "validation_failed"
+	CodeUnauthorized    = "unauthorized"
+	CodeForbidden       = "forbidden"
+	CodeRateLimited     = "rate_limited"
+	CodeTimeout         = "timeout"
+	CodeUnavailable     = "dependency_unavailable"
+	CodeInternal        = "internal_error"
+)
+
+// Write отвечает ошибкой с заданным статусом и кодом и прерывает цепочку обработчиков.
+func Write(c *gin.Context, status int, code string, detail string) {
+	c.Header("Content-Type", ContentType)
+	c.Header("Cache-Control", "no-store")
+	c.AbortWithStatusJSON(status, models.Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  c.Request.URL.Path,
+		Code:      code,
+		RequestID: logger.RequestID(c.Request.Context()),
+	})
+}
+
+// Error переводит ошибку сервиса в ответ. Текст внутренних ошибок клиенту не отдаётся.
+func Error(c *gin.Context, err error) {
+	_ = c.Error(err)
+	status, code, detail := Classify(err)
+	Write(c, status, code, detail)
+}
+
+// Classify сопоставляет доменной ошибке HTTP статус, код и описание для клиента.
+func Classify(err error) (status int, code string, detail string) {
+	var validationErr *models.ValidationError
+	switch {
+	case errors.Is(err, models.OrderNotFoundError):
+		return http.StatusNotFound, CodeOrderNotFound, "order not found"
+	case errors.Is(err, models.WebhookNotFoundError):
+		return http.StatusNotFound, CodeWebhookNotFound, "webhook not found"
+	case errors.As(err, &validationErr):
+		return http.StatusBadRequest, CodeValidation, validationErr.Error()
+	case isTimeout(err):
+		return http.StatusGatewayTimeout, CodeTimeout, "storage did not respond in time"
+	case isUnavailable(err):
+		return http.StatusServiceUnavailable, CodeUnavailable, "storage is temporarily unavailable"
+	default:
+		return http.StatusInternalServerError, CodeInternal, "internal error"
+	}
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	// 57014 query_canceled - в том числе сработавший statement_timeout.
+	return errors.As(err, &pgErr) && pgErr.Code == "57014"
+}
+
+func isUnavailable(err error) bool {
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// 08 - ошибки соединения, 57P01-57P03 - сервер останавливается или ещё не принимает соединения.
+		return pgErr.Code[:2] == "08" || pgErr.Code == "57P01" || pgErr.Code == "57P02" || pgErr.Code == "57P03"
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,70 @@
+package problem
+
+import (
+	"L0/internal/models"
+	"L0/pkg/logger"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"not found", fmt.Errorf("error getting order in postgres: %w", models.OrderNotFoundError), http.StatusNotFound, CodeOrderNotFound},
+		{"webhook not found", models.WebhookNotFoundError, http.StatusNotFound, CodeWebhookNotFound},
+		{"validation", &models.ValidationError{Reason: "orderUID must be 1-64 characters"}, http.StatusBadRequest, CodeValidation},
+		{"deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, CodeTimeout},
+		{"statement timeout", &pgconn.PgError{Code: "57014"}, http.StatusGatewayTimeout, CodeTimeout},
+		{"postgres shutting down", &pgconn.PgError{Code: "57P01"}, http.StatusServiceUnavailable, CodeUnavailable},
+		{"connection refused", &pgconn.ConnectError{}, http.StatusServiceUnavailable, CodeUnavailable},
+		{"other pg error", &pgconn.PgError{Code: "42P01"}, http.StatusInternalServerError, CodeInternal},
+		{"unknown", errors.New("boom"), http.StatusInternalServerError, CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, code, _ := Classify(tt.err)
+			assert.Equal(t, tt.status, status)
+			assert.Equal(t, tt.code, code)
+		})
+	}
+}
+
+func TestError_WritesProblem(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/order/:orderUID", func(c *gin.Context) {
+		c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), "req-1"))
+		Error(c, fmt.Errorf("secret dsn in message: %w", errors.New("boom")))
+	})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/order/1", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	var body models.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, models.Problem{
+		Type:      "about:blank",
+		Title:     "Internal Server Error",
+		Status:    http.StatusInternalServerError,
+		Detail:    "internal error",
+		Instance:  "/order/1",
+		Code:      CodeInternal,
+		RequestID: "req-1",
+	}, body)
+}
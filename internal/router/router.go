@@ -2,22 +2,57 @@ package router
 
 import (
 	_ "L0/docs"
+	"L0/internal/auth"
+	"L0/internal/config"
+	"L0/internal/ratelimit"
 	"L0/internal/router/handlers"
 	"L0/internal/router/middleware"
+	"L0/internal/router/problem"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"go.uber.org/zap"
 	"net/http"
+	"strconv"
+	"time"
 )
 
+// APIPrefix - префикс текущей версии API, он же basePath в docs/swagger.json.
+const APIPrefix = "/api/v1"
+
 type Router struct {
 	rout    *gin.Engine
 	handler *handlers.OrderHandlers
+	opts    Options
 	log     *zap.Logger
 }
 
-func NewRouter(handler *handlers.OrderHandlers, mode string, log *zap.Logger) *Router {
+// Options - необязательные компоненты роутера.
+type Options struct {
+	// Auth == nil отключает аутентификацию, все маршруты открыты.
+	Auth    *auth.Authenticator
+	Masking config.Masking
+	// Limiter == nil отключает ограничение частоты запросов.
+	Limiter   *ratelimit.Limiter
+	RateLimit config.RateLimit
+	AccessLog config.AccessLog
+	// CacheMaxAge попадает в Cache-Control ответов с заказом.
+	CacheMaxAge time.Duration
+	Compression config.Compression
+	// Stream == nil отключает поток новых заказов.
+	Stream *handlers.StreamHandlers
+	// Webhooks == nil отключает управление подписками на вебхуки.
+	Webhooks *handlers.WebhookHandlers
+	// GraphQL == nil отключает /graphql.
+	GraphQL *handlers.GraphQLHandlers
+	// LegacyRoutes - даты Deprecation и Sunset для путей без версии.
+	LegacyRoutes config.LegacyRoutes
+	// TrustedProxies - CIDR прокси, чьим заголовкам X-Forwarded-For верит ClientIP, пустой - никаким.
+	TrustedProxies []string
+}
+
+func NewRouter(handler *handlers.OrderHandlers, mode string, log *zap.Logger, opts Options) (*Router, error) {
 	switch mode {
 	case "debug":
 		gin.SetMode(gin.DebugMode)
@@ -25,24 +60,101 @@ func NewRouter(handler *handlers.OrderHandlers, mode string, log *zap.Logger) *R
 		gin.SetMode(gin.ReleaseMode)
 	}
 	router := &Router{
-		rout:    gin.Default(),
+		rout:    gin.New(),
 		handler: handler,
+		opts:    opts,
 		log:     log,
 	}
+	// gin по умолчанию верит X-Forwarded-For от любого адреса, и подделанный заголовок обходил бы
+	// ограничения частоты по IP.
+	if err := router.rout.SetTrustedProxies(opts.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
 	router.setupRouter()
 
-	return router
+	return router, nil
 }
 func (r *Router) setupRouter() {
 
-	r.rout.Use(middleware.LoggingMiddleware(r.log))
-	r.rout.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
-	r.rout.GET("/order/:orderUID", r.handler.GetOrder)
+	r.rout.Use(middleware.RequestIDMiddleware())
+	r.rout.Use(middleware.LoggingMiddleware(r.log, r.opts.AccessLog))
+	r.rout.Use(middleware.RecoveryMiddleware(r.log))
+	if r.opts.Compression.Enabled {
+		r.rout.Use(middleware.CompressionMiddleware(r.opts.Compression))
+	}
+	if r.opts.Limiter != nil {
+		r.rout.Use(middleware.IPRateLimitMiddleware(r.opts.Limiter, r.opts.RateLimit.PerIP, r.log))
+	}
+	if r.opts.Auth != nil {
+		r.rout.Use(middleware.AuthMiddleware(r.opts.Auth, r.log))
+	}
+	if r.opts.Limiter != nil {
+		r.rout.Use(middleware.RateLimitMiddleware(r.opts.Limiter, r.opts.RateLimit, r.log))
+	}
+	// С включённой аутентификацией роль берётся только из учётных данных.
+	roleHeader := r.opts.Masking.RoleHeader
+	if r.opts.Auth != nil {
+		roleHeader = ""
+	}
+	r.rout.Use(middleware.RoleMiddleware(roleHeader, r.opts.Masking.DefaultRole))
+	r.rout.GET("/swagger/*any", r.require(auth.ScopeOrdersRead), ginSwagger.WrapHandler(swaggerFiles.Handler))
+
+	v1 := r.rout.Group(APIPrefix)
+	v1.GET("/orders/:orderUID", r.require(auth.ScopeOrdersRead), r.orderCache(), r.handler.GetOrder)
+	v1.GET("/stats/db", r.require(auth.ScopeAdmin), r.handler.GetDBStats)
+	v1.DELETE("/customers/:customerID/pii", r.require(auth.ScopeAdmin), r.handler.ErasePII)
+	if r.opts.Stream != nil {
+		v1.GET("/orders/stream", r.require(auth.ScopeOrdersRead), r.opts.Stream.StreamOrders)
+		v1.GET("/orders/stream/ws", r.require(auth.ScopeOrdersRead), r.opts.Stream.StreamOrdersWS)
+	}
+	if r.opts.Webhooks != nil {
+		v1.POST("/webhooks", r.require(auth.ScopeAdmin), r.opts.Webhooks.CreateWebhook)
+		v1.GET("/webhooks", r.require(auth.ScopeAdmin), r.opts.Webhooks.ListWebhooks)
+		v1.DELETE("/webhooks/:webhookID", r.require(auth.ScopeAdmin), r.opts.Webhooks.DeleteWebhook)
+		v1.GET("/webhooks/:webhookID/deliveries", r.require(auth.ScopeAdmin), r.opts.Webhooks.ListWebhookDeliveries)
+	}
+	// GraphQL версионируется схемой, а не путём, поэтому живёт вне APIPrefix.
+	if r.opts.GraphQL != nil {
+		r.rout.POST("/graphql", r.require(auth.ScopeOrdersRead), r.opts.GraphQL.Query)
+		// Playground нужен только при разработке, в release режиме маршрута нет.
+		if gin.IsDebugging() {
+			r.rout.GET("/graphql", r.require(auth.ScopeOrdersRead), r.opts.GraphQL.Playground)
+		}
+	}
+
+	// Старый путь без версии оставлен на время миграции клиентов и отвечает заголовком Deprecation.
+	r.rout.GET("/order/:orderUID", r.deprecated("/orders/:orderUID"), r.require(auth.ScopeOrdersRead), r.orderCache(), r.handler.GetOrder)
+
+	r.rout.NoRoute(func(c *gin.Context) {
+		problem.Write(c, http.StatusNotFound, problem.CodeNotFound, "no such route")
+	})
 	r.rout.LoadHTMLGlob("static/*")
 	r.rout.GET("/", func(c *gin.Context) {
 		c.HTML(http.StatusOK, "index.html", nil)
 	})
 }
+
+func (r *Router) deprecated(successor string) gin.HandlerFunc {
+	return middleware.DeprecationMiddleware(r.opts.LegacyRoutes.DeprecatedAt, r.opts.LegacyRoutes.SunsetAt, APIPrefix+successor)
+}
+
+// orderCache разрешает хранить заказ только в кэше клиента: ответ зависит от роли вызывающего.
+func (r *Router) orderCache() gin.HandlerFunc {
+	value := "private, no-cache"
+	if r.opts.CacheMaxAge > 0 {
+		value = "private, max-age=" + strconv.Itoa(int(r.opts.CacheMaxAge.Seconds()))
+	}
+	return middleware.CacheControlMiddleware(value)
+}
+
+// require проверяет scope маршрута, если аутентификация включена.
+func (r *Router) require(scope string) gin.HandlerFunc {
+	if r.opts.Auth == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return middleware.RequireScope(scope)
+}
+
 func (r *Router) GetHTTPHandler() *gin.Engine {
 	return r.rout
 }
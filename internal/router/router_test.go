@@ -0,0 +1,548 @@
+package router
+
+import (
+	"L0/internal/auth"
+	"L0/internal/config"
+	"L0/internal/events"
+	"L0/internal/graphqlapi"
+	"L0/internal/masking"
+	"L0/internal/models"
+	"L0/internal/ratelimit"
+	"L0/internal/router/handlers"
+	"L0/internal/router/problem"
+	"L0/internal/service"
+	"L0/internal/webhook"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeRepo отвечает в зависимости от идентификатора: ok, missing, timeout, down, broken.
+type fakeRepo struct{}
+
+func (fakeRepo) SaveOrder(context.Context, *models.Order) error { return nil }
+func (fakeRepo) GetOrderByUID(_ context.Context, orderUID string) (*models.Order, error) {
+	if err := fakeError(orderUID); err != nil {
+		return nil, err
+	}
+	return &models.Order{
+		OrderUID: orderUID,
+		Delivery: models.Delivery{Name: "Test Testov", Phone: "+79990000000"},
+		Payment:  models.Payment{Amount: 1817, Currency: "USD"},
+		Items:    []models.Item{{Rid: "r1", Price: 10}, {Rid: "r2", Price: 20}, {Rid: "r3", Price: 30}},
+	}, nil
+}
+func (r fakeRepo) GetOrderView(ctx context.Context, orderUID string, view models.OrderView) (*models.OrderPage, error) {
+	order, err := r.GetOrderByUID(ctx, orderUID)
+	if err != nil {
+		return nil, err
+	}
+	return view.Page(order), nil
+}
+func (fakeRepo) GetRecentOrders(context.Context, int) ([]*models.Order, error) { return nil, nil }
+func (fakeRepo) ListOrders(context.Context, models.OrderFilter, *models.OrderKey, int) ([]*models.Order, error) {
+	return nil, nil
+}
+func (fakeRepo) ListOrdersWithoutItems(_ context.Context, filter models.OrderFilter, _ *models.OrderKey, _ int) ([]*models.Order, error) {
+	if err := fakeError(filter.CustomerID); err != nil {
+		return nil, err
+	}
+	return []*models.Order{{OrderUID: "ok", Delivery: models.Delivery{Phone: "+79990000000"}}}, nil
+}
+func (fakeRepo) GetItemsByOrders(_ context.Context, keys []models.OrderKey) ([][]models.Item, error) {
+	items := make([][]models.Item, len(keys))
+	for i := range keys {
+		items[i] = []models.Item{{Rid: "r1", Price: 10}}
+	}
+	return items, nil
+}
+func (fakeRepo) ErasePII(_ context.Context, customerID string, _ string) (*models.ErasureResult, error) {
+	if err := fakeError(customerID); err != nil {
+		return nil, err
+	}
+	return &models.ErasureResult{CustomerID: customerID}, nil
+}
+func (fakeRepo) PoolStats() models.PoolStats { return models.PoolStats{} }
+func (fakeRepo) Close()                      {}
+
+func fakeError(id string) error {
+	switch id {
+	case "missing":
+		return models.OrderNotFoundError
+	case "timeout":
+		return context.DeadlineExceeded
+	case "down":
+		return &pgconn.ConnectError{}
+	case "broken":
+		return errors.New("broken")
+	}
+	return nil
+}
+
+// missingWebhookID - подписка, которой нет в fakeWebhooks.
+const missingWebhookID = 999
+
+// fakeWebhooks возвращает fail из всех методов, если он задан, иначе отвечает как пустое хранилище.
+type fakeWebhooks struct {
+	fail error
+}
+
+func (s fakeWebhooks) CreateWebhook(_ context.Context, sub *models.WebhookSubscription) error {
+	sub.ID = 1
+	return s.fail
+}
+func (s fakeWebhooks) ListWebhooks(context.Context) ([]models.WebhookSubscription, error) {
+	return nil, s.fail
+}
+func (s fakeWebhooks) DeleteWebhook(_ context.Context, id int64) error {
+	return s.webhookError(id)
+}
+func (s fakeWebhooks) ListWebhookDeliveries(_ context.Context, id int64, _ int) ([]models.WebhookDelivery, error) {
+	return nil, s.webhookError(id)
+}
+func (s fakeWebhooks) webhookError(id int64) error {
+	if s.fail == nil && id == missingWebhookID {
+		return models.WebhookNotFoundError
+	}
+	return s.fail
+}
+
+type fakeCache struct{}
+
+func (fakeCache) SetOrder(context.Context, *models.Order, time.Duration, string) error { return nil }
+func (fakeCache) GetOrder(context.Context, string, string) (*models.Order, error) {
+	return nil, redis.Nil
+}
+func (fakeCache) DeleteOrders(context.Context, ...string) error { return nil }
+func (fakeCache) Close()                                        {}
+
+type swaggerSpec struct {
+	BasePath string                                 `json:"basePath"`
+	Paths    map[string]map[string]swaggerOperation `json:"paths"`
+}
+
+type swaggerOperation struct {
+	Responses map[string]json.RawMessage `json:"responses"`
+}
+
+var swaggerParam = regexp.MustCompile(`\{(\w+)\}`)
+
+func loadSpec(t *testing.T) swaggerSpec {
+	data, err := os.ReadFile("docs/swagger.json")
+	require.NoError(t, err)
+	var spec swaggerSpec
+	require.NoError(t, json.Unmarshal(data, &spec))
+	require.Equal(t, APIPrefix, spec.BasePath)
+	return spec
+}
+
+// newContractRouter собирает роутер целиком, как в runApp, но с фейковыми хранилищами.
+func newContractRouter(t *testing.T, rateLimit config.RateLimit) *Router {
+	return newContractRouterWithHub(t, rateLimit, events.NewHub(16, 0, zap.NewNop()))
+}
+
+func newContractRouterWithHub(t *testing.T, rateLimit config.RateLimit, hub *events.Hub) *Router {
+	return newTestRouter(t, rateLimit, hub, fakeWebhooks{})
+}
+
+func newTestRouter(t *testing.T, rateLimit config.RateLimit, hub *events.Hub, webhooks handlers.WebhookStore) *Router {
+	authn, err := auth.New(config.Auth{APIKeys: []config.APIKey{
+		{Name: "reader", Hash: auth.HashAPIKey("read-key"), Scopes: []string{auth.ScopeOrdersRead}},
+		{Name: "writer", Hash: auth.HashAPIKey("write-key"), Scopes: []string{auth.ScopeOrdersWrite}},
+		{Name: "admin", Hash: auth.HashAPIKey("admin-key"), Scopes: []string{auth.ScopeAdmin}, Role: "admin"},
+	}})
+	require.NoError(t, err)
+	guard, err := webhook.NewGuard(nil)
+	require.NoError(t, err)
+	masker, err := masking.New(config.Masking{DefaultRole: "support", Roles: map[string]map[string]string{"support": {"phone": "partial"}, "admin": {}}})
+	require.NoError(t, err)
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	svc := service.NewOrderService(nil, fakeRepo{}, fakeCache{}, time.Minute, zap.NewNop())
+	handler := handlers.NewOrderHandlers(svc, masker, zap.NewNop())
+	graphql, err := graphqlapi.NewServer(fakeRepo{}, masker, config.GraphQL{MaxDepth: 10, MaxComplexity: 5000}, zap.NewNop())
+	require.NoError(t, err)
+	router, err := NewRouter(handler, "release", zap.NewNop(), Options{
+		Auth:        authn,
+		Masking:     config.Masking{DefaultRole: "support"},
+		Limiter:     ratelimit.NewLimiter(client),
+		RateLimit:   rateLimit,
+		Compression: config.Compression{Enabled: true},
+		Stream:      handlers.NewStreamHandlers(hub, time.Minute, zap.NewNop()),
+		Webhooks:    handlers.NewWebhookHandlers(webhooks, guard, zap.NewNop()),
+		GraphQL:     handlers.NewGraphQLHandlers(graphql, zap.NewNop()),
+		LegacyRoutes: config.LegacyRoutes{
+			DeprecatedAt: time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC),
+			SunsetAt:     time.Date(2027, 4, 1, 0, 0, 0, 0, time.UTC),
+		},
+	})
+	require.NoError(t, err)
+	return router
+}
+
+func TestSwaggerRoutesAreRegistered(t *testing.T) {
+	t.Chdir("../..")
+	spec := loadSpec(t)
+	engine := newContractRouter(t, config.RateLimit{}).GetHTTPHandler()
+
+	registered := map[string]bool{}
+	for _, route := range engine.Routes() {
+		registered[route.Method+" "+route.Path] = true
+	}
+	require.NotEmpty(t, spec.Paths)
+	for path, ops := range spec.Paths {
+		ginPath := spec.BasePath + swaggerParam.ReplaceAllString(path, ":$1")
+		for method := range ops {
+			route := strings.ToUpper(method) + " " + ginPath
+			assert.True(t, registered[route], "documented route %s is not registered", route)
+		}
+	}
+}
+
+func TestSwaggerResponsesMatchHandlers(t *testing.T) {
+	t.Chdir("../..")
+	spec := loadSpec(t)
+	tooLong := strings.Repeat("x", 65)
+
+	tests := []struct {
+		method string
+		path   string
+		// op - путь операции в swagger.json.
+		op     string
+		apiKey string
+		// engine - "" обычный роутер, "limited" - с исчерпанным лимитом, "closed" - с закрытым потоком,
+		// "webhooks-*" - с отказывающим хранилищем вебхуков.
+		engine string
+		status int
+		// ifNoneMatch - значение If-None-Match запроса.
+		ifNoneMatch string
+		body        string
+	}{
+		{http.MethodGet, "/orders/ok", "/orders/{orderUID}", "read-key", "", http.StatusOK, "", ""},
+		{http.MethodGet, "/orders/ok", "/orders/{orderUID}", "read-key", "", http.StatusNotModified, "*", ""},
+		{http.MethodGet, "/orders/" + tooLong, "/orders/{orderUID}", "read-key", "", http.StatusBadRequest, "", ""},
+		{http.MethodGet, "/orders/ok", "/orders/{orderUID}", "", "", http.StatusUnauthorized, "", ""},
+		{http.MethodGet, "/orders/ok", "/orders/{orderUID}", "write-key", "", http.StatusForbidden, "", ""},
+		{http.MethodGet, "/orders/missing", "/orders/{orderUID}", "read-key", "", http.StatusNotFound, "", ""},
+		{http.MethodGet, "/orders/ok", "/orders/{orderUID}", "read-key", "limited", http.StatusTooManyRequests, "", ""},
+		{http.MethodGet, "/orders/broken", "/orders/{orderUID}", "read-key", "", http.StatusInternalServerError, "", ""},
+		{http.MethodGet, "/orders/down", "/orders/{orderUID}", "read-key", "", http.StatusServiceUnavailable, "", ""},
+		{http.MethodGet, "/orders/timeout", "/orders/{orderUID}", "read-key", "", http.StatusGatewayTimeout, "", ""},
+		{http.MethodGet, "/orders/stream", "/orders/stream", "read-key", "", http.StatusOK, "", ""},
+		{http.MethodGet, "/orders/stream?customer_id=" + tooLong, "/orders/stream", "read-key", "", http.StatusBadRequest, "", ""},
+		{http.MethodGet, "/orders/stream", "/orders/stream", "", "", http.StatusUnauthorized, "", ""},
+		{http.MethodGet, "/orders/stream", "/orders/stream", "write-key", "", http.StatusForbidden, "", ""},
+		{http.MethodGet, "/orders/stream", "/orders/stream", "read-key", "limited", http.StatusTooManyRequests, "", ""},
+		{http.MethodGet, "/orders/stream", "/orders/stream", "read-key", "closed", http.StatusServiceUnavailable, "", ""},
+		{http.MethodGet, "/stats/db", "/stats/db", "admin-key", "", http.StatusOK, "", ""},
+		{http.MethodGet, "/stats/db", "/stats/db", "", "", http.StatusUnauthorized, "", ""},
+		{http.MethodGet, "/stats/db", "/stats/db", "read-key", "", http.StatusForbidden, "", ""},
+		{http.MethodGet, "/stats/db", "/stats/db", "admin-key", "limited", http.StatusTooManyRequests, "", ""},
+		{http.MethodDelete, "/customers/ok/pii", "/customers/{customerID}/pii", "admin-key", "", http.StatusOK, "", ""},
+		{http.MethodDelete, "/customers/" + tooLong + "/pii", "/customers/{customerID}/pii", "admin-key", "", http.StatusBadRequest, "", ""},
+		{http.MethodDelete, "/customers/ok/pii", "/customers/{customerID}/pii", "", "", http.StatusUnauthorized, "", ""},
+		{http.MethodDelete, "/customers/ok/pii", "/customers/{customerID}/pii", "read-key", "", http.StatusForbidden, "", ""},
+		{http.MethodDelete, "/customers/ok/pii", "/customers/{customerID}/pii", "admin-key", "limited", http.StatusTooManyRequests, "", ""},
+		{http.MethodDelete, "/customers/broken/pii", "/customers/{customerID}/pii", "admin-key", "", http.StatusInternalServerError, "", ""},
+		{http.MethodDelete, "/customers/down/pii", "/customers/{customerID}/pii", "admin-key", "", http.StatusServiceUnavailable, "", ""},
+		{http.MethodDelete, "/customers/timeout/pii", "/customers/{customerID}/pii", "admin-key", "", http.StatusGatewayTimeout, "", ""},
+		{http.MethodPost, "/webhooks", "/webhooks", "admin-key", "", http.StatusCreated, "", `{"url":"https://203.0.113.10/hooks","events":["order.created"]}`},
+		{http.MethodPost, "/webhooks", "/webhooks", "admin-key", "", http.StatusBadRequest, "", `{"url":"ftp://example.com"}`},
+		{http.MethodPost, "/webhooks", "/webhooks", "admin-key", "", http.StatusBadRequest, "", `{"url":"http://169.254.169.254/latest/meta-data"}`},
+		{http.MethodPost, "/webhooks", "/webhooks", "", "", http.StatusUnauthorized, "", `{"url":"https://203.0.113.10/hooks","events":["order.created"]}`},
+		{http.MethodPost, "/webhooks", "/webhooks", "read-key", "", http.StatusForbidden, "", `{"url":"https://203.0.113.10/hooks","events":["order.created"]}`},
+		{http.MethodPost, "/webhooks", "/webhooks", "admin-key", "limited", http.StatusTooManyRequests, "", `{"url":"https://203.0.113.10/hooks","events":["order.created"]}`},
+		{http.MethodPost, "/webhooks", "/webhooks", "admin-key", "webhooks-broken", http.StatusInternalServerError, "", `{"url":"https://203.0.113.10/hooks","events":["order.created"]}`},
+		{http.MethodPost, "/webhooks", "/webhooks", "admin-key", "webhooks-down", http.StatusServiceUnavailable, "", `{"url":"https://203.0.113.10/hooks","events":["order.created"]}`},
+		{http.MethodPost, "/webhooks", "/webhooks", "admin-key", "webhooks-timeout", http.StatusGatewayTimeout, "", `{"url":"https://203.0.113.10/hooks","events":["order.created"]}`},
+		{http.MethodGet, "/webhooks", "/webhooks", "admin-key", "", http.StatusOK, "", ""},
+		{http.MethodGet, "/webhooks", "/webhooks", "", "", http.StatusUnauthorized, "", ""},
+		{http.MethodGet, "/webhooks", "/webhooks", "read-key", "", http.StatusForbidden, "", ""},
+		{http.MethodGet, "/webhooks", "/webhooks", "admin-key", "limited", http.StatusTooManyRequests, "", ""},
+		{http.MethodGet, "/webhooks", "/webhooks", "admin-key", "webhooks-broken", http.StatusInternalServerError, "", ""},
+		{http.MethodGet, "/webhooks", "/webhooks", "admin-key", "webhooks-down", http.StatusServiceUnavailable, "", ""},
+		{http.MethodGet, "/webhooks", "/webhooks", "admin-key", "webhooks-timeout", http.StatusGatewayTimeout, "", ""},
+		{http.MethodDelete, "/webhooks/1", "/webhooks/{webhookID}", "admin-key", "", http.StatusNoContent, "", ""},
+		{http.MethodDelete, "/webhooks/x", "/webhooks/{webhookID}", "admin-key", "", http.StatusBadRequest, "", ""},
+		{http.MethodDelete, "/webhooks/1", "/webhooks/{webhookID}", "", "", http.StatusUnauthorized, "", ""},
+		{http.MethodDelete, "/webhooks/1", "/webhooks/{webhookID}", "read-key", "", http.StatusForbidden, "", ""},
+		{http.MethodDelete, "/webhooks/999", "/webhooks/{webhookID}", "admin-key", "", http.StatusNotFound, "", ""},
+		{http.MethodDelete, "/webhooks/1", "/webhooks/{webhookID}", "admin-key", "limited", http.StatusTooManyRequests, "", ""},
+		{http.MethodDelete, "/webhooks/1", "/webhooks/{webhookID}", "admin-key", "webhooks-broken", http.StatusInternalServerError, "", ""},
+		{http.MethodDelete, "/webhooks/1", "/webhooks/{webhookID}", "admin-key", "webhooks-down", http.StatusServiceUnavailable, "", ""},
+		{http.MethodDelete, "/webhooks/1", "/webhooks/{webhookID}", "admin-key", "webhooks-timeout", http.StatusGatewayTimeout, "", ""},
+		{http.MethodGet, "/webhooks/1/deliveries", "/webhooks/{webhookID}/deliveries", "admin-key", "", http.StatusOK, "", ""},
+		{http.MethodGet, "/webhooks/x/deliveries", "/webhooks/{webhookID}/deliveries", "admin-key", "", http.StatusBadRequest, "", ""},
+		{http.MethodGet, "/webhooks/1/deliveries", "/webhooks/{webhookID}/deliveries", "", "", http.StatusUnauthorized, "", ""},
+		{http.MethodGet, "/webhooks/1/deliveries", "/webhooks/{webhookID}/deliveries", "read-key", "", http.StatusForbidden, "", ""},
+		{http.MethodGet, "/webhooks/999/deliveries", "/webhooks/{webhookID}/deliveries", "admin-key", "", http.StatusNotFound, "", ""},
+		{http.MethodGet, "/webhooks/1/deliveries", "/webhooks/{webhookID}/deliveries", "admin-key", "limited", http.StatusTooManyRequests, "", ""},
+		{http.MethodGet, "/webhooks/1/deliveries", "/webhooks/{webhookID}/deliveries", "admin-key", "webhooks-broken", http.StatusInternalServerError, "", ""},
+		{http.MethodGet, "/webhooks/1/deliveries", "/webhooks/{webhookID}/deliveries", "admin-key", "webhooks-down", http.StatusServiceUnavailable, "", ""},
+		{http.MethodGet, "/webhooks/1/deliveries", "/webhooks/{webhookID}/deliveries", "admin-key", "webhooks-timeout", http.StatusGatewayTimeout, "", ""},
+		{http.MethodGet, "/webhooks/1/deliveries?limit=0", "/webhooks/{webhookID}/deliveries", "admin-key", "", http.StatusBadRequest, "", ""},
+	}
+
+	closedHub := events.NewHub(1, 0, zap.NewNop())
+	closedHub.Close()
+	engines := map[string]*gin.Engine{
+		"":        newContractRouter(t, config.RateLimit{}).GetHTTPHandler(),
+		"limited": newContractRouter(t, config.RateLimit{Default: config.RateLimitRule{Rate: 0.001, Burst: 1}}).GetHTTPHandler(),
+		"closed":  newContractRouterWithHub(t, config.RateLimit{}, closedHub).GetHTTPHandler(),
+	}
+	for name, err := range map[string]error{
+		"webhooks-broken":  errors.New("broken"),
+		"webhooks-down":    &pgconn.ConnectError{},
+		"webhooks-timeout": context.DeadlineExceeded,
+	} {
+		engines[name] = newTestRouter(t, config.RateLimit{}, events.NewHub(1, 0, zap.NewNop()), fakeWebhooks{fail: err}).GetHTTPHandler()
+	}
+
+	covered := map[string]bool{}
+	for _, tt := range tests {
+		engine := engines[tt.engine]
+		serve := func() *httptest.ResponseRecorder {
+			// Поток заказов бесконечен, поэтому запрос ограничен по времени.
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			req := httptest.NewRequestWithContext(ctx, tt.method, spec.BasePath+tt.path, strings.NewReader(tt.body))
+			if tt.apiKey != "" {
+				req.Header.Set(auth.APIKeyHeader, tt.apiKey)
+			}
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			return w
+		}
+		w := serve()
+		if tt.engine == "limited" {
+			w = serve()
+		}
+
+		name := tt.method + " " + tt.path
+		require.Equal(t, tt.status, w.Code, name)
+		op, ok := spec.Paths[tt.op][strings.ToLower(tt.method)]
+		require.True(t, ok, "operation %s %s is not documented", tt.method, tt.op)
+		code := strconv.Itoa(w.Code)
+		assert.Contains(t, op.Responses, code, "%s returned undocumented status", name)
+		covered[tt.method+" "+tt.op+" "+code] = true
+		if w.Code >= 400 {
+			assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"), name)
+		}
+	}
+
+	for path, ops := range spec.Paths {
+		for method, op := range ops {
+			for code := range op.Responses {
+				key := strings.ToUpper(method) + " " + path + " " + code
+				assert.True(t, covered[key], "documented response %s is not exercised", key)
+			}
+		}
+	}
+}
+
+func TestGraphQLRoute(t *testing.T) {
+	t.Chdir("../..")
+	engine := newContractRouter(t, config.RateLimit{}).GetHTTPHandler()
+	query := `{"query":"{ order(uid: \"ok\") { orderUid } }"}`
+
+	tests := []struct {
+		path   string
+		apiKey string
+		body   string
+		status int
+	}{
+		{"/graphql", "read-key", query, http.StatusOK},
+		{"/graphql", "read-key", `{"query":""}`, http.StatusBadRequest},
+		{"/graphql", "", query, http.StatusUnauthorized},
+		{"/graphql", "write-key", query, http.StatusForbidden},
+		{APIPrefix + "/graphql", "read-key", query, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+		if tt.apiKey != "" {
+			req.Header.Set(auth.APIKeyHeader, tt.apiKey)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, tt.status, w.Code, "%s with %q", tt.path, tt.apiKey)
+	}
+}
+
+func TestLegacyRoutesAreDeprecated(t *testing.T) {
+	t.Chdir("../..")
+	engine := newContractRouter(t, config.RateLimit{}).GetHTTPHandler()
+
+	req := httptest.NewRequest(http.MethodGet, "/order/ok", nil)
+	req.Header.Set(auth.APIKeyHeader, "read-key")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "@1792281600", w.Header().Get("Deprecation"))
+	assert.Equal(t, "Thu, 01 Apr 2027 00:00:00 GMT", w.Header().Get("Sunset"))
+	assert.Equal(t, `</api/v1/orders/ok>; rel="successor-version"`, w.Header().Get("Link"))
+
+	req = httptest.NewRequest(http.MethodGet, APIPrefix+"/orders/ok", nil)
+	req.Header.Set(auth.APIKeyHeader, "read-key")
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Empty(t, w.Header().Get("Deprecation"))
+	assert.Empty(t, w.Header().Get("Sunset"))
+}
+
+func TestIPRateLimitIgnoresForgedForwardedFor(t *testing.T) {
+	t.Chdir("../..")
+	engine := newContractRouter(t, config.RateLimit{PerIP: config.RateLimitRule{Rate: 0.001, Burst: 2}}).GetHTTPHandler()
+
+	codes := make([]int, 0, 3)
+	for _, forwarded := range []string{"198.51.100.1", "198.51.100.2", "198.51.100.3"} {
+		req := httptest.NewRequest(http.MethodGet, APIPrefix+"/orders/ok", nil)
+		req.Header.Set(auth.APIKeyHeader, "read-key")
+		req.Header.Set("X-Forwarded-For", forwarded)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+	}
+
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, codes,
+		"X-Forwarded-For от недоверенного адреса не меняет IP клиента")
+}
+
+func TestGetOrderConditional(t *testing.T) {
+	t.Chdir("../..")
+	engine := newContractRouter(t, config.RateLimit{}).GetHTTPHandler()
+	get := func(apiKey string, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, APIPrefix+"/orders/ok", nil)
+		req.Header.Set(auth.APIKeyHeader, apiKey)
+		req.Header.Set("Accept-Encoding", "gzip")
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	first := get("read-key", "")
+	require.Equal(t, http.StatusOK, first.Code)
+	etag := first.Header().Get("ETag")
+	assert.Regexp(t, `^"[0-9a-f]{32}-gzip"$`, etag)
+	assert.Equal(t, "private, no-cache", first.Header().Get("Cache-Control"))
+
+	second := get("read-key", etag)
+	assert.Equal(t, http.StatusNotModified, second.Code)
+	assert.Empty(t, second.Body.String())
+
+	// Админ видит заказ без маскирования, поэтому ETag читателя ему не подходит.
+	assert.Equal(t, http.StatusOK, get("admin-key", etag).Code)
+}
+
+func TestGetOrderFieldsAndItemsPages(t *testing.T) {
+	t.Chdir("../..")
+	engine := newContractRouter(t, config.RateLimit{}).GetHTTPHandler()
+	get := func(query string) (*httptest.ResponseRecorder, map[string]any) {
+		req := httptest.NewRequest(http.MethodGet, APIPrefix+"/orders/ok?"+query, nil)
+		req.Header.Set(auth.APIKeyHeader, "admin-key")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		var body map[string]any
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+		return w, body
+	}
+
+	w, body := get("fields=order_uid,delivery.name,payment.amount")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, map[string]any{
+		"order_uid": "ok",
+		"delivery":  map[string]any{"name": "Test Testov"},
+		"payment":   map[string]any{"amount": float64(1817)},
+	}, body)
+
+	_, body = get("fields=items.rid&items_limit=2")
+	assert.Equal(t, []any{map[string]any{"rid": "r1"}, map[string]any{"rid": "r2"}}, body["items"])
+	cursor, ok := body["items_next_cursor"].(string)
+	require.True(t, ok)
+
+	_, body = get("fields=items.rid&items_limit=2&items_cursor=" + cursor)
+	assert.Equal(t, []any{map[string]any{"rid": "r3"}}, body["items"])
+	assert.NotContains(t, body, "items_next_cursor")
+
+	for _, query := range []string{"fields=secret", "items_limit=0", "items_limit=1001", "items_cursor=%21"} {
+		w, body = get(query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		assert.Equal(t, problem.CodeValidation, body["code"], query)
+	}
+}
+
+func TestOrderStream(t *testing.T) {
+	t.Chdir("../..")
+	hub := events.NewHub(16, 0, zap.NewNop())
+	srv := httptest.NewServer(newContractRouterWithHub(t, config.RateLimit{}, hub).GetHTTPHandler())
+	t.Cleanup(srv.Close)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+APIPrefix+"/orders/stream?delivery_service=meest", nil)
+	require.NoError(t, err)
+	req.Header.Set(auth.APIKeyHeader, "read-key")
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+
+	wsHeader := http.Header{auth.APIKeyHeader: []string{"read-key"}}
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + APIPrefix + "/orders/stream/ws?customer_id=c1"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, wsHeader)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.Eventually(t, func() bool { return hub.Subscribers() == 2 }, time.Second, 10*time.Millisecond)
+	hub.Publish(models.OrderSummary{OrderUID: "1", DeliveryService: "dhl", CustomerID: "c1"})
+	hub.Publish(models.OrderSummary{OrderUID: "2", DeliveryService: "meest", CustomerID: "c2"})
+
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 3 {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	assert.Equal(t, "event: order", lines[0])
+	assert.Equal(t, "id: 2", lines[1])
+	assert.Contains(t, lines[2], `"order_uid":"2"`)
+
+	var summary models.OrderSummary
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	require.NoError(t, conn.ReadJSON(&summary))
+	assert.Equal(t, "1", summary.OrderUID)
+
+	hub.Close()
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "unexpected error %v", err)
+}
@@ -1,7 +1,9 @@
 package service
 
 import (
+	"L0/internal/messagebroker"
 	"L0/internal/models"
+	"L0/pkg/logger"
 	"L0/pkg/validator"
 	"context"
 	"encoding/json"
@@ -21,21 +23,39 @@ type Consumer interface {
 type OrderRepository interface {
 	SaveOrder(ctx context.Context, order *models.Order) error
 	GetOrderByUID(ctx context.Context, orderUID string) (*models.Order, error)
+	GetOrderView(ctx context.Context, orderUID string, view models.OrderView) (*models.OrderPage, error)
 	GetRecentOrders(ctx context.Context, limit int) ([]*models.Order, error)
+	ListOrders(ctx context.Context, filter models.OrderFilter, after *models.OrderKey, limit int) ([]*models.Order, error)
+	ErasePII(ctx context.Context, customerID string, source string) (*models.ErasureResult, error)
+	PoolStats() models.PoolStats
 	Close()
 }
 
 type RedisClient interface {
 	SetOrder(ctx context.Context, order *models.Order, ttl time.Duration, key string) error
 	GetOrder(ctx context.Context, orderUID string, key string) (*models.Order, error)
+	DeleteOrders(ctx context.Context, keys ...string) error
 	Close()
 }
 
+// OrderObserver узнаёт о заказах, сохранённых ProcessOrder. Вызывается синхронно в консьюмере,
+// поэтому не должен блокироваться.
+type OrderObserver interface {
+	OrderSaved(ctx context.Context, order *models.Order)
+}
+
+// Размер страницы ListOrders: по умолчанию и наибольший.
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
 type OrderService struct {
 	consumer    Consumer
 	repository  OrderRepository
 	redisClient RedisClient
 	ttl         time.Duration
+	observers   []OrderObserver
 	log         *zap.Logger
 }
 
@@ -43,69 +63,185 @@ func NewOrderService(consumer Consumer, repository OrderRepository, redisClient
 	return &OrderService{consumer: consumer, repository: repository, redisClient: redisClient, ttl: ttl, log: log.Named("OrderService")}
 }
 
+// Observe подписывает observer на сохранённые заказы. Вызывать до запуска консьюмера.
+func (s *OrderService) Observe(observer OrderObserver) {
+	s.observers = append(s.observers, observer)
+}
+
 func (s *OrderService) SaveOrder(ctx context.Context, order *models.Order) error {
 	return s.repository.SaveOrder(ctx, order)
 }
 
 func (s *OrderService) GetOrderByUID(ctx context.Context, orderUID string) (*models.Order, error) {
+	log := logger.FromContext(ctx, s.log)
 	key := fmt.Sprintf("order:%s", orderUID)
 	order, err := s.redisClient.GetOrder(ctx, orderUID, key)
 	if err == nil {
 		return order, nil
 	} else {
 		if errors.Is(err, redis.Nil) {
-			s.log.Warn("Order not found in redis", zap.String("key", key))
+			log.Warn("Order not found in redis", zap.String("key", key))
 		} else {
-			s.log.Error("Error getting order in redis", zap.Error(err))
+			log.Error("Error getting order in redis", zap.Error(err))
 		}
 	}
 	order, err = s.repository.GetOrderByUID(ctx, orderUID)
 	if err != nil {
 		if errors.Is(err, models.OrderNotFoundError) {
-			s.log.Warn("Order not found", zap.String("order_uid", orderUID))
+			log.Warn("Order not found", zap.String("order_uid", orderUID))
 			return nil, models.OrderNotFoundError
 		}
-		s.log.Error("Error getting order in postgres", zap.Error(err))
+		log.Error("Error getting order in postgres", zap.Error(err))
 		return nil, fmt.Errorf("error getting order in postgres: %w", err)
 	}
 	err = s.SetOrder(ctx, order)
 	if err != nil {
-		s.log.Error("Error setting order in redis", zap.Error(err))
+		log.Error("Error setting order in redis", zap.Error(err))
 	}
 	return order, nil
 }
 
+// GetOrderView возвращает часть заказа. Заказ из кэша нарезается в памяти, а при промахе
+// из postgres читается только нужная часть, и в кэш такой заказ не кладётся.
+func (s *OrderService) GetOrderView(ctx context.Context, orderUID string, view models.OrderView) (*models.OrderPage, error) {
+	if view.Full() {
+		order, err := s.GetOrderByUID(ctx, orderUID)
+		if err != nil {
+			return nil, err
+		}
+		return &models.OrderPage{Order: order}, nil
+	}
+	log := logger.FromContext(ctx, s.log)
+	key := fmt.Sprintf("order:%s", orderUID)
+	order, err := s.redisClient.GetOrder(ctx, orderUID, key)
+	if err == nil {
+		return view.Page(order), nil
+	}
+	if !errors.Is(err, redis.Nil) {
+		log.Error("Error getting order in redis", zap.Error(err))
+	}
+	page, err := s.repository.GetOrderView(ctx, orderUID, view)
+	if err != nil {
+		if errors.Is(err, models.OrderNotFoundError) {
+			return nil, models.OrderNotFoundError
+		}
+		log.Error("Error getting order view in postgres", zap.Error(err))
+		return nil, fmt.Errorf("error getting order view in postgres: %w", err)
+	}
+	return page, nil
+}
+
+// ListOrders возвращает страницу заказов по filter от новых к старым. after - NextCursor предыдущей
+// страницы, limit 0 - DefaultListLimit. Список читается из postgres, кэш заказов не используется.
+func (s *OrderService) ListOrders(ctx context.Context, filter models.OrderFilter, after string, limit int) (*models.OrderList, error) {
+	log := logger.FromContext(ctx, s.log)
+	if limit == 0 {
+		limit = DefaultListLimit
+	}
+	if limit < 0 || limit > MaxListLimit {
+		return nil, &models.ValidationError{Reason: fmt.Sprintf("page size must be 1-%d", MaxListLimit)}
+	}
+	var afterKey *models.OrderKey
+	if after != "" {
+		key, err := models.ParseOrderCursor(after)
+		if err != nil {
+			return nil, err
+		}
+		afterKey = &key
+	}
+	// Лишний заказ показывает, есть ли следующая страница.
+	orders, err := s.repository.ListOrders(ctx, filter, afterKey, limit+1)
+	if err != nil {
+		log.Error("Error listing orders in postgres", zap.Error(err))
+		return nil, fmt.Errorf("error listing orders in postgres: %w", err)
+	}
+	list := &models.OrderList{Orders: orders}
+	if len(orders) > limit {
+		list.Orders = orders[:limit]
+		last := list.Orders[limit-1]
+		list.NextCursor = models.OrderKey{OrderUID: last.OrderUID, DateCreated: last.DateCreated}.Cursor()
+	}
+	return list, nil
+}
+
 func (s *OrderService) PreloadRecentOrder(ctx context.Context, limit int) error {
+	log := logger.FromContext(ctx, s.log)
 	orders, err := s.repository.GetRecentOrders(ctx, limit)
 	if err != nil {
-		s.log.Error("Error getting recent orders", zap.Error(err))
+		log.Error("Error getting recent orders", zap.Error(err))
 		return fmt.Errorf("error getting recent orders: %w", err)
 	}
 	for _, order := range orders {
 		if err := s.SetOrder(ctx, order); err != nil {
-			s.log.Error("Error setting order in postgres", zap.Error(err))
+			log.Error("Error setting order in postgres", zap.Error(err))
 		} else {
-			s.log.Debug("Preloaded order to cache", zap.String("orderUID", order.OrderUID))
+			log.Debug("Preloaded order to cache", zap.String("orderUID", order.OrderUID))
 		}
 	}
 	return nil
 }
 
-func (s *OrderService) ReadMessage(ctx context.Context) (*models.Order, error) {
-
+// ReadMessage читает следующий заказ из Kafka. Возвращённый контекст несёт correlation id сообщения,
+// его нужно передать в ProcessOrder, чтобы все логи обработки заказа были связаны.
+func (s *OrderService) ReadMessage(ctx context.Context) (context.Context, *models.Order, error) {
 	msg, err := s.consumer.ReadMessage(ctx)
 	if err != nil {
 		s.log.Error("Error reading message", zap.Error(err))
-		return nil, fmt.Errorf("error reading message: %w", err)
+		return ctx, nil, fmt.Errorf("error reading message: %w", err)
+	}
+	ctx = messageContext(ctx, msg)
+	order, err := s.decodeOrder(ctx, msg)
+	return ctx, order, err
+}
+
+// ReplayMessage прогоняет сообщение, прочитанное при replay, через тот же pipeline, что и основной консьюмер.
+func (s *OrderService) ReplayMessage(ctx context.Context, msg *kafka.Message) error {
+	ctx = messageContext(ctx, msg)
+	order, err := s.decodeOrder(ctx, msg)
+	if err != nil {
+		return err
+	}
+	return s.ProcessOrder(ctx, order)
+}
+
+// messageContext берёт correlation id из заголовка trace-id, который ставит продюсер,
+// а для сообщений без него генерирует новый.
+func messageContext(ctx context.Context, msg *kafka.Message) context.Context {
+	requestID := messagebroker.HeaderValue(msg.Headers, messagebroker.HeaderTraceID)
+	if requestID == "" {
+		requestID = logger.NewRequestID()
+	}
+	ctx = logger.WithRequestID(ctx, requestID)
+	return logger.WithFields(ctx, zap.Int("partition", msg.Partition), zap.Int64("offset", msg.Offset))
+}
+
+// ProcessOrder сохраняет заказ в postgres, оповещает observers и кладёт заказ в кэш.
+func (s *OrderService) ProcessOrder(ctx context.Context, order *models.Order) error {
+	log := logger.FromContext(ctx, s.log)
+	if err := s.SaveOrder(ctx, order); err != nil {
+		log.Error("Error saving order to DB", zap.Error(err))
+		return fmt.Errorf("error saving order: %w", err)
+	}
+	for _, observer := range s.observers {
+		observer.OrderSaved(ctx, order)
+	}
+	if err := s.SetOrder(ctx, order); err != nil {
+		log.Error("Error caching order", zap.Error(err))
+		return fmt.Errorf("error caching order: %w", err)
 	}
+	return nil
+}
+
+func (s *OrderService) decodeOrder(ctx context.Context, msg *kafka.Message) (*models.Order, error) {
+	log := logger.FromContext(ctx, s.log)
 	var order models.Order
 	if err := json.Unmarshal(msg.Value, &order); err != nil {
-		s.log.Error("Error unmarshalling message", zap.Error(err))
+		log.Error("Error unmarshalling message", zap.Error(err))
 		return nil, fmt.Errorf("error unmarshalling message: %w", err)
 	}
 
 	if err := validator.ValidateOrder(&order); err != nil {
-		s.log.Error("Error validating order", zap.Error(err))
+		log.Error("Error validating order", zap.Error(err))
 		return nil, fmt.Errorf("error validating order: %w", err)
 	}
 	return &order, nil
@@ -121,10 +257,42 @@ func (s *OrderService) GetOrder(ctx context.Context, orderUID string) (*models.O
 	return s.redisClient.GetOrder(ctx, orderUID, key)
 }
 
+// ErasePII обезличивает данные доставки во всех заказах покупателя и убирает эти заказы из кэша.
+// Повторный вызов безопасен, поэтому при ошибке Redis запрос можно просто повторить.
+func (s *OrderService) ErasePII(ctx context.Context, customerID string, source string) (*models.ErasureResult, error) {
+	log := logger.FromContext(ctx, s.log)
+	result, err := s.repository.ErasePII(ctx, customerID, source)
+	if err != nil {
+		log.Error("Error erasing PII in postgres", zap.String("customer_id", customerID), zap.Error(err))
+		return nil, fmt.Errorf("error erasing PII in postgres: %w", err)
+	}
+	if err := s.EvictOrders(ctx, result.OrderUIDs...); err != nil {
+		log.Error("Error evicting erased orders from redis", zap.String("customer_id", customerID), zap.Error(err))
+		return nil, fmt.Errorf("error evicting erased orders from redis: %w", err)
+	}
+	return result, nil
+}
+
+// EvictOrders убирает заказы из кэша, например после удаления или обезличивания в postgres.
+func (s *OrderService) EvictOrders(ctx context.Context, orderUIDs ...string) error {
+	keys := make([]string, 0, len(orderUIDs))
+	for _, uid := range orderUIDs {
+		keys = append(keys, fmt.Sprintf("order:%s", uid))
+	}
+	return s.redisClient.DeleteOrders(ctx, keys...)
+}
+
+func (s *OrderService) DBStats() models.PoolStats {
+	return s.repository.PoolStats()
+}
+
 func (s *OrderService) CloseRedisClient() {
 	s.redisClient.Close()
 }
 func (s *OrderService) CloseConsumer() error {
+	if s.consumer == nil {
+		return nil
+	}
 	return s.consumer.Close()
 }
 func (s *OrderService) CloseRepo() {
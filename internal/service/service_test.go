@@ -1,8 +1,10 @@
 package service_test
 
 import (
+	"L0/internal/messagebroker"
 	"L0/internal/models"
 	"L0/internal/service"
+	"L0/pkg/logger"
 	"context"
 	"encoding/json"
 	"errors"
@@ -47,6 +49,13 @@ func (m *MockRepo) GetOrderByUID(ctx context.Context, orderUID string) (*models.
 	}
 	return nil, args.Error(1)
 }
+func (m *MockRepo) GetOrderView(ctx context.Context, orderUID string, view models.OrderView) (*models.OrderPage, error) {
+	args := m.Called(ctx, orderUID, view)
+	if page, ok := args.Get(0).(*models.OrderPage); ok {
+		return page, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
 func (m *MockRepo) GetRecentOrders(ctx context.Context, limit int) ([]*models.Order, error) {
 	args := m.Called(ctx, limit)
 	if orders, ok := args.Get(0).([]*models.Order); ok {
@@ -54,6 +63,23 @@ func (m *MockRepo) GetRecentOrders(ctx context.Context, limit int) ([]*models.Or
 	}
 	return nil, args.Error(1)
 }
+func (m *MockRepo) ListOrders(ctx context.Context, filter models.OrderFilter, after *models.OrderKey, limit int) ([]*models.Order, error) {
+	args := m.Called(ctx, filter, after, limit)
+	if orders := args.Get(0); orders != nil {
+		return orders.([]*models.Order), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+func (m *MockRepo) ErasePII(ctx context.Context, customerID string, source string) (*models.ErasureResult, error) {
+	args := m.Called(ctx, customerID, source)
+	if result, ok := args.Get(0).(*models.ErasureResult); ok {
+		return result, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+func (m *MockRepo) PoolStats() models.PoolStats {
+	return m.Called().Get(0).(models.PoolStats)
+}
 func (m *MockRepo) Close() { m.Called() }
 
 type MockRedis struct {
@@ -70,6 +96,9 @@ func (m *MockRedis) GetOrder(ctx context.Context, orderUID string, key string) (
 	}
 	return nil, args.Error(1)
 }
+func (m *MockRedis) DeleteOrders(ctx context.Context, keys ...string) error {
+	return m.Called(ctx, keys).Error(0)
+}
 func (m *MockRedis) Close() { m.Called() }
 
 // --- Tests ---
@@ -123,6 +152,44 @@ func TestGetOrderByUID_FromRepoWhenRedisNil(t *testing.T) {
 	redisClient.AssertExpectations(t)
 }
 
+func TestGetOrderView_PagesCachedOrder(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepo)
+	redisClient := new(MockRedis)
+	cached := &models.Order{OrderUID: "123", Items: []models.Item{{Rid: "a"}, {Rid: "b"}, {Rid: "c"}}}
+
+	redisClient.On("GetOrder", ctx, "123", "order:123").Return(cached, nil)
+
+	svc := service.NewOrderService(nil, repo, redisClient, time.Minute, zap.NewNop())
+
+	page, err := svc.GetOrderView(ctx, "123", models.OrderView{ItemsOffset: 1, ItemsLimit: 1})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Item{{Rid: "b"}}, page.Order.Items)
+	assert.True(t, page.HasMoreItems)
+	assert.Len(t, cached.Items, 3, "cached order must not be modified")
+	repo.AssertNotCalled(t, "GetOrderView", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetOrderView_ReadsPartialOrderOnCacheMiss(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepo)
+	redisClient := new(MockRedis)
+	view := models.OrderView{SkipItems: true}
+	page := &models.OrderPage{Order: &models.Order{OrderUID: "456"}}
+
+	redisClient.On("GetOrder", ctx, "456", "order:456").Return(nil, redis.Nil)
+	repo.On("GetOrderView", ctx, "456", view).Return(page, nil)
+
+	svc := service.NewOrderService(nil, repo, redisClient, time.Minute, zap.NewNop())
+
+	got, err := svc.GetOrderView(ctx, "456", view)
+
+	assert.NoError(t, err)
+	assert.Equal(t, page, got)
+	redisClient.AssertNotCalled(t, "SetOrder", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestReadMessage_ValidOrder(t *testing.T) {
 	ctx := context.Background()
 	repo := new(MockRepo)
@@ -179,14 +246,15 @@ func TestReadMessage_ValidOrder(t *testing.T) {
 	data, _ := json.Marshal(order)
 
 	consumer.On("ReadMessage", ctx).
-		Return(&kafka.Message{Value: data}, nil)
+		Return(&kafka.Message{Value: data, Headers: []kafka.Header{{Key: messagebroker.HeaderTraceID, Value: []byte("trace-1")}}}, nil)
 
 	svc := service.NewOrderService(consumer, repo, redisClient, time.Minute, log)
 
-	got, err := svc.ReadMessage(ctx)
+	msgCtx, got, err := svc.ReadMessage(ctx)
 
 	assert.NoError(t, err)
 	assert.Equal(t, order.OrderUID, got.OrderUID)
+	assert.Equal(t, "trace-1", logger.RequestID(msgCtx))
 	consumer.AssertExpectations(t)
 }
 func TestPreloadRecentOrder_Success(t *testing.T) {
@@ -255,3 +323,103 @@ func TestSetOrder_CallsRedis(t *testing.T) {
 	assert.NoError(t, err)
 	redisClient.AssertExpectations(t)
 }
+
+func TestErasePII_EvictsErasedOrders(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepo)
+	redisClient := new(MockRedis)
+	result := &models.ErasureResult{CustomerID: "c1", OrdersAffected: 2, OrderUIDs: []string{"a", "b"}}
+
+	repo.On("ErasePII", ctx, "c1", "api").Return(result, nil)
+	redisClient.On("DeleteOrders", ctx, []string{"order:a", "order:b"}).Return(nil)
+
+	svc := service.NewOrderService(nil, repo, redisClient, time.Minute, zap.NewNop())
+
+	got, err := svc.ErasePII(ctx, "c1", "api")
+
+	assert.NoError(t, err)
+	assert.Equal(t, result, got)
+	repo.AssertExpectations(t)
+	redisClient.AssertExpectations(t)
+}
+
+func TestErasePII_RepoError(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepo)
+	redisClient := new(MockRedis)
+
+	repo.On("ErasePII", ctx, "c1", "cli").Return(nil, errors.New("db down"))
+
+	svc := service.NewOrderService(nil, repo, redisClient, time.Minute, zap.NewNop())
+
+	got, err := svc.ErasePII(ctx, "c1", "cli")
+
+	assert.Error(t, err)
+	assert.Nil(t, got)
+	redisClient.AssertNotCalled(t, "DeleteOrders", mock.Anything, mock.Anything)
+}
+
+type recordingObserver struct {
+	saved []string
+}
+
+func (o *recordingObserver) OrderSaved(ctx context.Context, order *models.Order) {
+	o.saved = append(o.saved, order.OrderUID)
+}
+
+func TestProcessOrder_NotifiesObserversAfterSave(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepo)
+	redisClient := new(MockRedis)
+	order := &models.Order{OrderUID: "o1"}
+
+	repo.On("SaveOrder", ctx, order).Return(nil).Once()
+	repo.On("SaveOrder", ctx, order).Return(errors.New("db down")).Once()
+	redisClient.On("SetOrder", ctx, order, time.Minute, "order:o1").Return(nil)
+
+	svc := service.NewOrderService(nil, repo, redisClient, time.Minute, zap.NewNop())
+	observer := &recordingObserver{}
+	svc.Observe(observer)
+
+	assert.NoError(t, svc.ProcessOrder(ctx, order))
+	assert.Error(t, svc.ProcessOrder(ctx, order))
+	assert.Equal(t, []string{"o1"}, observer.saved)
+}
+
+func TestListOrders_Pages(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepo)
+	filter := models.OrderFilter{CustomerID: "c1"}
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	orders := []*models.Order{
+		{OrderUID: "a", DateCreated: created.Add(2 * time.Hour)},
+		{OrderUID: "b", DateCreated: created.Add(time.Hour)},
+		{OrderUID: "c", DateCreated: created},
+	}
+	repo.On("ListOrders", ctx, filter, (*models.OrderKey)(nil), 3).Return(orders, nil)
+	second := &models.OrderKey{OrderUID: "b", DateCreated: created.Add(time.Hour)}
+	repo.On("ListOrders", ctx, filter, second, 3).Return(orders[2:], nil)
+
+	svc := service.NewOrderService(nil, repo, new(MockRedis), time.Minute, zap.NewNop())
+
+	page, err := svc.ListOrders(ctx, filter, "", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, orders[:2], page.Orders)
+	assert.NotEmpty(t, page.NextCursor)
+
+	page, err = svc.ListOrders(ctx, filter, page.NextCursor, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, orders[2:], page.Orders)
+	assert.Empty(t, page.NextCursor)
+	repo.AssertExpectations(t)
+}
+
+func TestListOrders_InvalidArguments(t *testing.T) {
+	svc := service.NewOrderService(nil, new(MockRepo), new(MockRedis), time.Minute, zap.NewNop())
+	var validationErr *models.ValidationError
+
+	_, err := svc.ListOrders(context.Background(), models.OrderFilter{}, "", service.MaxListLimit+1)
+	assert.ErrorAs(t, err, &validationErr)
+	_, err = svc.ListOrders(context.Background(), models.OrderFilter{}, "not a cursor", 0)
+	assert.ErrorAs(t, err, &validationErr)
+}
@@ -0,0 +1,298 @@
+// Package webhook доставляет события о сохранённых заказах на URL подписчиков: тело подписывается
+// HMAC-SHA256 секретом подписки, неудачные попытки повторяются с экспоненциальной задержкой,
+// каждая попытка записывается в журнал доставок.
+package webhook
+
+import (
+	"L0/internal/config"
+	"L0/internal/events"
+	"L0/internal/models"
+	"L0/pkg/logger"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Заголовки запроса доставки. EventIDHeader одинаков во всех попытках и повторах одного события,
+// получатель может отбрасывать дубликаты по нему.
+const (
+	EventHeader   = "X-Webhook-Event"
+	EventIDHeader = "X-Webhook-ID"
+	AttemptHeader = "X-Webhook-Attempt"
+)
+
+// Store - хранилище подписок и журнала доставок.
+type Store interface {
+	ActiveWebhooks(ctx context.Context) ([]models.WebhookSubscription, error)
+	LogWebhookDelivery(ctx context.Context, d *models.WebhookDelivery) error
+}
+
+// Event - тело запроса доставки.
+type Event struct {
+	ID        string              `json:"id"`
+	Event     string              `json:"event"`
+	CreatedAt time.Time           `json:"created_at"`
+	Data      models.OrderSummary `json:"data"`
+}
+
+type job struct {
+	sub       models.WebhookSubscription
+	event     Event
+	body      []byte
+	requestID string
+}
+
+// Dispatcher получает заказы от OrderService и доставляет их подходящим подписчикам в Workers горутин.
+// Список подписок кэшируется и перечитывается раз в RefreshInterval, так что подписка, созданная
+// через API другого процесса, начинает получать события не позже чем через этот интервал.
+type Dispatcher struct {
+	store  Store
+	cfg    config.Webhooks
+	client *http.Client
+	queue  chan job
+	now    func() time.Time
+
+	mu   sync.RWMutex
+	subs []models.WebhookSubscription
+
+	log *zap.Logger
+}
+
+// NewDispatcher создаёт диспетчер. Нулевые поля cfg заменяются значениями по умолчанию.
+// Соединения открываются только с адресами, которые пропускает guard.
+func NewDispatcher(store Store, cfg config.Webhooks, guard *Guard, log *zap.Logger) *Dispatcher {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1000
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = time.Minute
+	}
+	cfg.MaxBackoff = max(cfg.MaxBackoff, cfg.InitialBackoff)
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = 30 * time.Second
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Через прокси соединение открывал бы он, и проверка адреса получателя теряла бы смысл.
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: guard.control}).DialContext
+	return &Dispatcher{
+		store:  store,
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout, Transport: transport},
+		queue:  make(chan job, cfg.QueueSize),
+		now:    time.Now,
+		log:    log.Named("webhooks"),
+	}
+}
+
+// OrderSaved ставит доставки события order.created в очередь и не ждёт их. При переполненной очереди
+// событие для подписчика теряется с записью в лог: консьюмер важнее вебхуков.
+func (d *Dispatcher) OrderSaved(ctx context.Context, order *models.Order) {
+	log := logger.FromContext(ctx, d.log)
+	summary := order.Summary()
+	event := Event{
+		ID:        fmt.Sprintf("%s:%s:%d", models.EventOrderCreated, order.OrderUID, order.DateCreated.Unix()),
+		Event:     models.EventOrderCreated,
+		CreatedAt: d.now().UTC(),
+		Data:      summary,
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Error("Error encoding webhook event", zap.Error(err))
+		return
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, sub := range d.subs {
+		if !matches(sub, event.Event, summary) {
+			continue
+		}
+		select {
+		case d.queue <- job{sub: sub, event: event, body: body, requestID: logger.RequestID(ctx)}:
+		default:
+			log.Warn("Webhook queue is full, event dropped",
+				zap.Int64("webhook_id", sub.ID), zap.String("event_id", event.ID))
+		}
+	}
+}
+
+func matches(sub models.WebhookSubscription, event string, summary models.OrderSummary) bool {
+	if len(sub.Events) > 0 && !slices.Contains(sub.Events, event) {
+		return false
+	}
+	return events.Filter{DeliveryService: sub.DeliveryService, CustomerID: sub.CustomerID}.Match(summary)
+}
+
+// Run загружает подписки, запускает доставку и периодически обновляет подписки до отмены ctx.
+// Недоставленные к этому моменту события не сохраняются.
+func (d *Dispatcher) Run(ctx context.Context) {
+	d.Refresh(ctx)
+
+	var wg sync.WaitGroup
+	for i := 0; i < d.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case j := <-d.queue:
+					d.deliver(ctx, j)
+				}
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(d.cfg.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			if pending := len(d.queue); pending > 0 {
+				d.log.Warn("Webhook deliveries abandoned on shutdown", zap.Int("pending", pending))
+			}
+			return
+		case <-ticker.C:
+			d.Refresh(ctx)
+		}
+	}
+}
+
+// Refresh перечитывает подписки. При ошибке остаётся прежний список.
+func (d *Dispatcher) Refresh(ctx context.Context) {
+	subs, err := d.store.ActiveWebhooks(ctx)
+	if err != nil {
+		d.log.Error("Error refreshing webhook subscriptions", zap.Error(err))
+		return
+	}
+	d.mu.Lock()
+	d.subs = subs
+	d.mu.Unlock()
+	d.log.Debug("Refreshed webhook subscriptions", zap.Int("subscriptions", len(subs)))
+}
+
+// deliver отправляет событие, повторяя попытки при сетевых ошибках, 408, 429 и 5xx.
+func (d *Dispatcher) deliver(ctx context.Context, j job) {
+	ctx = logger.WithRequestID(ctx, j.requestID)
+	ctx = logger.WithFields(ctx, zap.Int64("webhook_id", j.sub.ID), zap.String("event_id", j.event.ID))
+	log := logger.FromContext(ctx, d.log)
+
+	for attempt := 1; attempt <= d.cfg.MaxAttempts; attempt++ {
+		record := d.attempt(ctx, j, attempt)
+		// Журнал пишется и при остановке, чтобы прерванная попытка не потерялась.
+		if err := d.store.LogWebhookDelivery(context.WithoutCancel(ctx), &record.delivery); err != nil {
+			log.Error("Error logging webhook delivery", zap.Error(err))
+		}
+		if record.delivery.Success {
+			log.Info("Webhook delivered", zap.Int("attempt", attempt), zap.Int("status", record.delivery.StatusCode))
+			return
+		}
+		if !record.retry || attempt == d.cfg.MaxAttempts {
+			log.Warn("Webhook delivery failed", zap.Int("attempt", attempt),
+				zap.Int("status", record.delivery.StatusCode), zap.String("error", record.delivery.Error))
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d.backoff(attempt, record.retryAfter)):
+		}
+	}
+}
+
+type attemptResult struct {
+	delivery   models.WebhookDelivery
+	retry      bool
+	retryAfter time.Duration
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, j job, attempt int) (result attemptResult) {
+	result = attemptResult{delivery: models.WebhookDelivery{
+		SubscriptionID: j.sub.ID,
+		EventID:        j.event.ID,
+		Event:          j.event.Event,
+		OrderUID:       j.event.Data.OrderUID,
+		Attempt:        attempt,
+	}}
+	started := time.Now()
+	defer func() {
+		result.delivery.DurationMs = int(time.Since(started).Milliseconds())
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, j.sub.URL, bytes.NewReader(j.body))
+	if err != nil {
+		result.delivery.Error = err.Error()
+		return result
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "L0-webhooks/1")
+	req.Header.Set(EventHeader, j.event.Event)
+	req.Header.Set(EventIDHeader, j.event.ID)
+	req.Header.Set(AttemptHeader, strconv.Itoa(attempt))
+	req.Header.Set(SignatureHeader, Sign(j.sub.Secret, d.now(), j.body))
+	if j.requestID != "" {
+		req.Header.Set("X-Request-ID", j.requestID)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		result.delivery.Error = err.Error()
+		result.retry = !errors.Is(err, ErrForbiddenTarget)
+		return result
+	}
+	defer resp.Body.Close()
+	// Тело ответа не нужно, но дочитывается, чтобы соединение вернулось в пул.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	result.delivery.StatusCode = resp.StatusCode
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		result.delivery.Success = true
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		result.delivery.Error = resp.Status
+		result.retry = true
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			result.retryAfter = time.Duration(seconds) * time.Second
+		}
+	default:
+		// Остальные 4xx означают, что получатель отверг событие, повтор не поможет.
+		result.delivery.Error = resp.Status
+	}
+	return result
+}
+
+// backoff - InitialBackoff, удваиваемый с каждой попыткой, но не больше MaxBackoff.
+// Retry-After получателя учитывается в тех же пределах.
+func (d *Dispatcher) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	delay := d.cfg.InitialBackoff
+	for i := 1; i < attempt && delay < d.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = max(delay, retryAfter)
+	return min(delay, d.cfg.MaxBackoff)
+}
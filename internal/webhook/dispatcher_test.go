@@ -0,0 +1,236 @@
+package webhook_test
+
+import (
+	"L0/internal/config"
+	"L0/internal/models"
+	"L0/internal/webhook"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeStore struct {
+	mu         sync.Mutex
+	subs       []models.WebhookSubscription
+	deliveries []models.WebhookDelivery
+}
+
+func (s *fakeStore) ActiveWebhooks(ctx context.Context) ([]models.WebhookSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.subs, nil
+}
+
+func (s *fakeStore) LogWebhookDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries = append(s.deliveries, *d)
+	return nil
+}
+
+func (s *fakeStore) logged() []models.WebhookDelivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.WebhookDelivery(nil), s.deliveries...)
+}
+
+var testOrder = &models.Order{
+	OrderUID:        "b563feb7b2b84b6test",
+	CustomerID:      "test",
+	DeliveryService: "meest",
+	DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+	Payment:         models.Payment{Amount: 1817, Currency: "USD"},
+}
+
+// localGuard пропускает loopback, где слушают httptest серверы.
+func localGuard(t *testing.T) *webhook.Guard {
+	guard, err := webhook.NewGuard([]string{"127.0.0.0/8", "::1/128"})
+	require.NoError(t, err)
+	return guard
+}
+
+// startDispatcher запускает диспетчер с подписками store и возвращает его после первой загрузки подписок.
+func startDispatcher(t *testing.T, store *fakeStore, maxAttempts int, guard *webhook.Guard) *webhook.Dispatcher {
+	t.Helper()
+	d := webhook.NewDispatcher(store, config.Webhooks{
+		Workers:        2,
+		MaxAttempts:    maxAttempts,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		Timeout:        time.Second,
+	}, guard, zap.NewNop())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	d.Refresh(context.Background())
+	return d
+}
+
+func TestDispatcher_DeliversSignedEvent(t *testing.T) {
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	got := make(chan received, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- received{header: r.Header.Clone(), body: body}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	store := &fakeStore{subs: []models.WebhookSubscription{{ID: 1, URL: server.URL, Secret: "s3cret"}}}
+	d := startDispatcher(t, store, 3, localGuard(t))
+	d.OrderSaved(context.Background(), testOrder)
+
+	var req received
+	select {
+	case req = <-got:
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+	assert.NoError(t, webhook.Verify("s3cret", req.header.Get(webhook.SignatureHeader), req.body, time.Minute, time.Now()))
+	assert.ErrorIs(t, webhook.Verify("other", req.header.Get(webhook.SignatureHeader), req.body, 0, time.Now()), webhook.ErrInvalidSignature)
+	assert.Equal(t, models.EventOrderCreated, req.header.Get(webhook.EventHeader))
+	assert.Equal(t, "order.created:b563feb7b2b84b6test:1637907739", req.header.Get(webhook.EventIDHeader))
+
+	var event webhook.Event
+	require.NoError(t, json.Unmarshal(req.body, &event))
+	assert.Equal(t, testOrder.Summary(), event.Data)
+
+	require.Eventually(t, func() bool { return len(store.logged()) == 1 }, time.Second, 5*time.Millisecond)
+	logged := store.logged()[0]
+	assert.True(t, logged.Success)
+	assert.Equal(t, http.StatusNoContent, logged.StatusCode)
+	assert.Equal(t, int64(1), logged.SubscriptionID)
+}
+
+func TestDispatcher_RetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	ids := make(chan string, 3)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids <- r.Header.Get(webhook.EventIDHeader)
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	store := &fakeStore{subs: []models.WebhookSubscription{{ID: 1, URL: server.URL, Secret: "s"}}}
+	d := startDispatcher(t, store, 5, localGuard(t))
+	d.OrderSaved(context.Background(), testOrder)
+
+	require.Eventually(t, func() bool { return len(store.logged()) == 3 }, 2*time.Second, 5*time.Millisecond)
+	logged := store.logged()
+	for i, attempt := range logged {
+		assert.Equal(t, i+1, attempt.Attempt)
+		assert.Equal(t, i == 2, attempt.Success)
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, logged[0].StatusCode)
+	first := <-ids
+	assert.Equal(t, first, <-ids, "retries keep the event id")
+}
+
+func TestDispatcher_GivesUp(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	store := &fakeStore{subs: []models.WebhookSubscription{
+		{ID: 1, URL: server.URL + "/gone", Secret: "s"},
+		{ID: 2, URL: server.URL + "/broken", Secret: "s"},
+	}}
+	d := startDispatcher(t, store, 3, localGuard(t))
+	d.OrderSaved(context.Background(), testOrder)
+
+	// 4xx не повторяется, 5xx - до MaxAttempts.
+	require.Eventually(t, func() bool { return len(store.logged()) == 4 }, 2*time.Second, 5*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(4), calls.Load())
+	for _, attempt := range store.logged() {
+		assert.False(t, attempt.Success)
+		assert.NotEmpty(t, attempt.Error)
+	}
+}
+
+func TestDispatcher_AppliesSubscriptionFilters(t *testing.T) {
+	got := make(chan string, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got <- r.URL.Path
+	}))
+	defer server.Close()
+
+	store := &fakeStore{subs: []models.WebhookSubscription{
+		{ID: 1, URL: server.URL + "/other-service", Secret: "s", DeliveryService: "dhl"},
+		{ID: 2, URL: server.URL + "/other-event", Secret: "s", Events: []string{"order.deleted"}},
+		{ID: 3, URL: server.URL + "/customer", Secret: "s", CustomerID: "test", Events: []string{models.EventOrderCreated}},
+	}}
+	d := startDispatcher(t, store, 1, localGuard(t))
+	d.OrderSaved(context.Background(), testOrder)
+
+	select {
+	case path := <-got:
+		assert.Equal(t, "/customer", path)
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+	time.Sleep(20 * time.Millisecond)
+	assert.Empty(t, got)
+}
+
+func TestDispatcher_RefusesInternalTargets(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer server.Close()
+
+	guard, err := webhook.NewGuard(nil)
+	require.NoError(t, err)
+	store := &fakeStore{subs: []models.WebhookSubscription{{ID: 1, URL: server.URL, Secret: "s"}}}
+	d := startDispatcher(t, store, 3, guard)
+	d.OrderSaved(context.Background(), testOrder)
+
+	require.Eventually(t, func() bool { return len(store.logged()) == 1 }, 2*time.Second, 5*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(t, store.logged(), 1, "a forbidden address is not retried")
+	assert.Contains(t, store.logged()[0].Error, "not allowed")
+	assert.Zero(t, calls.Load())
+}
+
+func TestVerify_RejectsTamperedAndExpired(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	signedAt := time.Unix(1700000000, 0)
+	header := webhook.Sign("secret", signedAt, body)
+
+	assert.NoError(t, webhook.Verify("secret", header, body, time.Minute, signedAt.Add(30*time.Second)))
+	assert.ErrorIs(t, webhook.Verify("secret", header, []byte(`{"id":"2"}`), 0, signedAt), webhook.ErrInvalidSignature)
+	assert.ErrorIs(t, webhook.Verify("secret", header, body, time.Minute, signedAt.Add(2*time.Minute)), webhook.ErrSignatureExpired)
+	assert.ErrorIs(t, webhook.Verify("secret", "garbage", body, 0, signedAt), webhook.ErrInvalidSignature)
+}
@@ -0,0 +1,92 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"syscall"
+)
+
+// ErrForbiddenTarget - получатель вебхука во внутренней сети: через подписку нельзя достучаться
+// до localhost, metadata облака (169.254.169.254) или соседних сервисов.
+var ErrForbiddenTarget = errors.New("webhook target address is not allowed")
+
+// blockedNetworks дополняют проверки netip.Addr: "эта сеть", CGNAT, служебные и зарезервированные адреса.
+var blockedNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+}
+
+// Guard проверяет адреса получателей вебхуков: при создании подписки - все адреса хоста из DNS,
+// при доставке - адрес, с которым открывается соединение, так что смена DNS записи после проверки
+// не помогает. Сети из allowed разрешены, даже если они внутренние.
+type Guard struct {
+	allowed  []netip.Prefix
+	resolver *net.Resolver
+}
+
+// NewGuard создаёт Guard, allowedNetworks - список CIDR.
+func NewGuard(allowedNetworks []string) (*Guard, error) {
+	g := &Guard{resolver: net.DefaultResolver}
+	for _, cidr := range allowedNetworks {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("webhooks allowed network %q: %w", cidr, err)
+		}
+		g.allowed = append(g.allowed, prefix.Masked())
+	}
+	return g, nil
+}
+
+// CheckURL разрешает хост URL и проверяет каждый его адрес.
+func (g *Guard) CheckURL(ctx context.Context, rawURL string) error {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	addrs, err := g.resolver.LookupNetIP(ctx, "ip", target.Hostname())
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", target.Hostname(), err)
+	}
+	for _, addr := range addrs {
+		if err := g.check(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Guard) check(addr netip.Addr) error {
+	addr = addr.Unmap()
+	for _, prefix := range g.allowed {
+		if prefix.Contains(addr) {
+			return nil
+		}
+	}
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return fmt.Errorf("%w: %s", ErrForbiddenTarget, addr)
+	}
+	for _, prefix := range blockedNetworks {
+		if prefix.Contains(addr) {
+			return fmt.Errorf("%w: %s", ErrForbiddenTarget, addr)
+		}
+	}
+	return nil
+}
+
+// control - net.Dialer.Control: вызывается перед каждым соединением с уже разрешённым адресом,
+// в том числе после редиректов.
+func (g *Guard) control(network string, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrForbiddenTarget, address)
+	}
+	return g.check(addrPort.Addr())
+}
@@ -0,0 +1,48 @@
+package webhook_test
+
+import (
+	"L0/internal/webhook"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuard_CheckURL(t *testing.T) {
+	guard, err := webhook.NewGuard([]string{"10.20.0.0/16"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://203.0.113.10/hooks", true},
+		{"http://10.20.1.5:8080/hooks", true},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://127.0.0.1:8080/hooks", false},
+		{"http://localhost/hooks", false},
+		{"http://10.0.0.1/hooks", false},
+		{"http://192.168.1.1/hooks", false},
+		{"http://[::1]/hooks", false},
+		{"http://[::ffff:127.0.0.1]/hooks", false},
+		{"http://[fd00::1]/hooks", false},
+		{"http://0.0.0.0/hooks", false},
+		{"http://100.64.0.1/hooks", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			err := guard.CheckURL(context.Background(), tt.url)
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, webhook.ErrForbiddenTarget)
+			}
+		})
+	}
+}
+
+func TestNewGuard_InvalidNetwork(t *testing.T) {
+	_, err := webhook.NewGuard([]string{"10.0.0.0"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,128 @@
+-- Возврат к непартиционированным таблицам. Если один order_uid встречается с разными
+-- date_created, остаётся самая поздняя версия заказа.
+
+DROP INDEX IF EXISTS idx_orders_track_number;
+DROP INDEX IF EXISTS idx_orders_date_created;
+DROP INDEX IF EXISTS idx_orders_customer_id;
+DROP INDEX IF EXISTS idx_deliveries_phone;
+DROP INDEX IF EXISTS idx_deliveries_email;
+DROP INDEX IF EXISTS idx_payments_transaction;
+DROP INDEX IF EXISTS idx_items_chrt_id;
+DROP INDEX IF EXISTS idx_items_order_uid;
+DROP INDEX IF EXISTS idx_items_nm_id;
+
+ALTER TABLE items RENAME TO items_partitioned;
+ALTER INDEX items_pkey RENAME TO items_partitioned_pkey;
+ALTER TABLE items_partitioned RENAME CONSTRAINT unique_order_uid_rid TO items_partitioned_order_uid_rid;
+ALTER TABLE payments RENAME TO payments_partitioned;
+ALTER INDEX payments_pkey RENAME TO payments_partitioned_pkey;
+ALTER TABLE deliveries RENAME TO deliveries_partitioned;
+ALTER INDEX deliveries_pkey RENAME TO deliveries_partitioned_pkey;
+ALTER TABLE orders RENAME TO orders_partitioned;
+ALTER INDEX orders_pkey RENAME TO orders_partitioned_pkey;
+
+CREATE TABLE orders (
+    order_uid TEXT PRIMARY KEY,
+    track_number TEXT NOT NULL,
+    entry TEXT NOT NULL,
+    locale CHAR(2) NOT NULL DEFAULT 'en',
+    internal_signature TEXT,
+    customer_id TEXT NOT NULL,
+    delivery_service TEXT NOT NULL,
+    shardkey TEXT NOT NULL,
+    sm_id INTEGER NOT NULL CHECK (sm_id >= 0),
+    date_created TIMESTAMPTZ NOT NULL,
+    oof_shard TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );
+
+CREATE TABLE deliveries (
+    order_uid TEXT PRIMARY KEY REFERENCES orders(order_uid) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    phone TEXT NOT NULL,
+    zip TEXT NOT NULL,
+    city TEXT NOT NULL,
+    address TEXT NOT NULL,
+    region TEXT NOT NULL,
+    email TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );
+
+CREATE TABLE payments (
+    order_uid TEXT PRIMARY KEY REFERENCES orders(order_uid) ON DELETE CASCADE,
+    transaction TEXT NOT NULL,
+    request_id TEXT,
+    currency CHAR(3) NOT NULL DEFAULT 'USD',
+    provider TEXT NOT NULL,
+    amount INTEGER NOT NULL CHECK (amount >= 0),
+    payment_dt BIGINT NOT NULL CHECK (payment_dt >= 0),
+    bank TEXT NOT NULL,
+    delivery_cost INTEGER NOT NULL CHECK (delivery_cost >= 0),
+    goods_total INTEGER NOT NULL CHECK (goods_total >= 0),
+    custom_fee INTEGER NOT NULL DEFAULT 0 CHECK (custom_fee >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );
+
+CREATE TABLE items (
+    id INTEGER NOT NULL DEFAULT nextval('items_id_seq') PRIMARY KEY,
+    order_uid TEXT NOT NULL REFERENCES orders(order_uid) ON DELETE CASCADE,
+    chrt_id INTEGER NOT NULL CHECK (chrt_id >= 0),
+    track_number TEXT NOT NULL,
+    price INTEGER NOT NULL CHECK (price >= 0),
+    rid TEXT NOT NULL,
+    name TEXT NOT NULL,
+    sale INTEGER NOT NULL DEFAULT 0 CHECK (sale >= 0),
+    size TEXT NOT NULL,
+    total_price INTEGER NOT NULL CHECK (total_price >= 0),
+    nm_id INTEGER NOT NULL CHECK (nm_id >= 0),
+    brand TEXT NOT NULL,
+    status SMALLINT NOT NULL CHECK (status >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT unique_order_uid_rid UNIQUE (order_uid, rid)
+    );
+
+ALTER SEQUENCE items_id_seq OWNED BY items.id;
+ALTER SEQUENCE items_id_seq AS INTEGER;
+
+INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id,
+                    delivery_service, shardkey, sm_id, date_created, oof_shard, created_at)
+SELECT DISTINCT ON (order_uid)
+       order_uid, track_number, entry, locale, internal_signature, customer_id,
+       delivery_service, shardkey, sm_id, date_created, oof_shard, created_at
+FROM orders_partitioned
+ORDER BY order_uid, date_created DESC;
+
+INSERT INTO deliveries (order_uid, name, phone, zip, city, address, region, email, created_at)
+SELECT d.order_uid, d.name, d.phone, d.zip, d.city, d.address, d.region, d.email, d.created_at
+FROM deliveries_partitioned d
+JOIN orders o ON o.order_uid = d.order_uid AND o.date_created = d.date_created;
+
+INSERT INTO payments (order_uid, transaction, request_id, currency, provider, amount,
+                      payment_dt, bank, delivery_cost, goods_total, custom_fee, created_at)
+SELECT p.order_uid, p.transaction, p.request_id, p.currency, p.provider, p.amount,
+       p.payment_dt, p.bank, p.delivery_cost, p.goods_total, p.custom_fee, p.created_at
+FROM payments_partitioned p
+JOIN orders o ON o.order_uid = p.order_uid AND o.date_created = p.date_created;
+
+INSERT INTO items (id, order_uid, chrt_id, track_number, price, rid, name, sale, size,
+                   total_price, nm_id, brand, status, created_at)
+SELECT i.id, i.order_uid, i.chrt_id, i.track_number, i.price, i.rid, i.name, i.sale, i.size,
+       i.total_price, i.nm_id, i.brand, i.status, i.created_at
+FROM items_partitioned i
+JOIN orders o ON o.order_uid = i.order_uid AND o.date_created = i.date_created;
+
+DROP TABLE items_partitioned;
+DROP TABLE payments_partitioned;
+DROP TABLE deliveries_partitioned;
+DROP TABLE orders_partitioned;
+DROP FUNCTION IF EXISTS create_order_partitions(TIMESTAMPTZ);
+
+CREATE INDEX IF NOT EXISTS idx_orders_track_number ON orders(track_number);
+CREATE INDEX IF NOT EXISTS idx_orders_date_created ON orders(date_created);
+CREATE INDEX IF NOT EXISTS idx_orders_customer_id ON orders(customer_id);
+CREATE INDEX IF NOT EXISTS idx_deliveries_phone ON deliveries(phone);
+CREATE INDEX IF NOT EXISTS idx_deliveries_email ON deliveries(email);
+CREATE INDEX IF NOT EXISTS idx_payments_transaction ON payments(transaction);
+CREATE INDEX IF NOT EXISTS idx_items_chrt_id ON items(chrt_id);
+CREATE INDEX IF NOT EXISTS idx_items_order_uid ON items(order_uid);
+CREATE INDEX IF NOT EXISTS idx_items_nm_id ON items(nm_id);
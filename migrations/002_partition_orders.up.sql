@@ -0,0 +1,173 @@
+-- Помесячное партиционирование таблиц заказов по date_created.
+-- В партиционированной таблице PK, уникальные и внешние ключи обязаны включать ключ партиционирования,
+-- поэтому date_created продублирован в deliveries, payments и items.
+
+DROP INDEX IF EXISTS idx_orders_track_number;
+DROP INDEX IF EXISTS idx_orders_date_created;
+DROP INDEX IF EXISTS idx_orders_customer_id;
+DROP INDEX IF EXISTS idx_deliveries_phone;
+DROP INDEX IF EXISTS idx_deliveries_email;
+DROP INDEX IF EXISTS idx_payments_transaction;
+DROP INDEX IF EXISTS idx_items_chrt_id;
+DROP INDEX IF EXISTS idx_items_order_uid;
+DROP INDEX IF EXISTS idx_items_nm_id;
+
+ALTER TABLE items RENAME TO items_legacy;
+ALTER INDEX items_pkey RENAME TO items_legacy_pkey;
+ALTER TABLE items_legacy RENAME CONSTRAINT unique_order_uid_rid TO items_legacy_order_uid_rid;
+ALTER TABLE payments RENAME TO payments_legacy;
+ALTER INDEX payments_pkey RENAME TO payments_legacy_pkey;
+ALTER TABLE deliveries RENAME TO deliveries_legacy;
+ALTER INDEX deliveries_pkey RENAME TO deliveries_legacy_pkey;
+ALTER TABLE orders RENAME TO orders_legacy;
+ALTER INDEX orders_pkey RENAME TO orders_legacy_pkey;
+
+CREATE TABLE orders (
+    order_uid TEXT NOT NULL,
+    track_number TEXT NOT NULL,
+    entry TEXT NOT NULL,
+    locale CHAR(2) NOT NULL DEFAULT 'en',
+    internal_signature TEXT,
+    customer_id TEXT NOT NULL,
+    delivery_service TEXT NOT NULL,
+    shardkey TEXT NOT NULL,
+    sm_id INTEGER NOT NULL CHECK (sm_id >= 0),
+    date_created TIMESTAMPTZ NOT NULL,
+    oof_shard TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (order_uid, date_created)
+    ) PARTITION BY RANGE (date_created);
+
+CREATE TABLE deliveries (
+    order_uid TEXT NOT NULL,
+    date_created TIMESTAMPTZ NOT NULL,
+    name TEXT NOT NULL,
+    phone TEXT NOT NULL,
+    zip TEXT NOT NULL,
+    city TEXT NOT NULL,
+    address TEXT NOT NULL,
+    region TEXT NOT NULL,
+    email TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (order_uid, date_created),
+    FOREIGN KEY (order_uid, date_created) REFERENCES orders (order_uid, date_created) ON DELETE CASCADE
+    ) PARTITION BY RANGE (date_created);
+
+CREATE TABLE payments (
+    order_uid TEXT NOT NULL,
+    date_created TIMESTAMPTZ NOT NULL,
+    transaction TEXT NOT NULL,
+    request_id TEXT,
+    currency CHAR(3) NOT NULL DEFAULT 'USD',
+    provider TEXT NOT NULL,
+    amount INTEGER NOT NULL CHECK (amount >= 0),
+    payment_dt BIGINT NOT NULL CHECK (payment_dt >= 0),
+    bank TEXT NOT NULL,
+    delivery_cost INTEGER NOT NULL CHECK (delivery_cost >= 0),
+    goods_total INTEGER NOT NULL CHECK (goods_total >= 0),
+    custom_fee INTEGER NOT NULL DEFAULT 0 CHECK (custom_fee >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (order_uid, date_created),
+    FOREIGN KEY (order_uid, date_created) REFERENCES orders (order_uid, date_created) ON DELETE CASCADE
+    ) PARTITION BY RANGE (date_created);
+
+ALTER SEQUENCE items_id_seq AS BIGINT;
+
+CREATE TABLE items (
+    id BIGINT NOT NULL DEFAULT nextval('items_id_seq'),
+    order_uid TEXT NOT NULL,
+    date_created TIMESTAMPTZ NOT NULL,
+    chrt_id INTEGER NOT NULL CHECK (chrt_id >= 0),
+    track_number TEXT NOT NULL,
+    price INTEGER NOT NULL CHECK (price >= 0),
+    rid TEXT NOT NULL,
+    name TEXT NOT NULL,
+    sale INTEGER NOT NULL DEFAULT 0 CHECK (sale >= 0),
+    size TEXT NOT NULL,
+    total_price INTEGER NOT NULL CHECK (total_price >= 0),
+    nm_id INTEGER NOT NULL CHECK (nm_id >= 0),
+    brand TEXT NOT NULL,
+    status SMALLINT NOT NULL CHECK (status >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (id, date_created),
+    CONSTRAINT unique_order_uid_rid UNIQUE (order_uid, date_created, rid),
+    FOREIGN KEY (order_uid, date_created) REFERENCES orders (order_uid, date_created) ON DELETE CASCADE
+    ) PARTITION BY RANGE (date_created);
+
+ALTER SEQUENCE items_id_seq OWNED BY items.id;
+
+-- DEFAULT партиции принимают строки вне созданных диапазонов, чтобы вставка не падала,
+-- если maintenance-джоба не успела создать партицию заранее.
+CREATE TABLE orders_default PARTITION OF orders DEFAULT;
+CREATE TABLE deliveries_default PARTITION OF deliveries DEFAULT;
+CREATE TABLE payments_default PARTITION OF payments DEFAULT;
+CREATE TABLE items_default PARTITION OF items DEFAULT;
+
+-- Создаёт партиции <table>_pYYYY_MM всех таблиц заказов для месяца (UTC), в который попадает p_month.
+CREATE OR REPLACE FUNCTION create_order_partitions(p_month TIMESTAMPTZ) RETURNS VOID AS $$
+DECLARE
+    from_ts TIMESTAMPTZ := date_trunc('month', p_month AT TIME ZONE 'UTC') AT TIME ZONE 'UTC';
+    to_ts   TIMESTAMPTZ := from_ts + INTERVAL '1 month';
+    suffix  TEXT := to_char(from_ts AT TIME ZONE 'UTC', '"p"YYYY_MM');
+    tbl     TEXT;
+BEGIN
+    FOREACH tbl IN ARRAY ARRAY['orders', 'deliveries', 'payments', 'items'] LOOP
+        EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+                       tbl || '_' || suffix, tbl, from_ts, to_ts);
+    END LOOP;
+END;
+$$ LANGUAGE plpgsql;
+
+DO $$
+DECLARE
+    m TIMESTAMPTZ;
+BEGIN
+    FOR m IN
+        SELECT DISTINCT date_trunc('month', date_created AT TIME ZONE 'UTC') AT TIME ZONE 'UTC' FROM orders_legacy
+        UNION ALL
+        SELECT now() + make_interval(months => g) FROM generate_series(0, 2) AS g
+    LOOP
+        PERFORM create_order_partitions(m);
+    END LOOP;
+END;
+$$;
+
+INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id,
+                    delivery_service, shardkey, sm_id, date_created, oof_shard, created_at)
+SELECT order_uid, track_number, entry, locale, internal_signature, customer_id,
+       delivery_service, shardkey, sm_id, date_created, oof_shard, created_at
+FROM orders_legacy;
+
+INSERT INTO deliveries (order_uid, date_created, name, phone, zip, city, address, region, email, created_at)
+SELECT d.order_uid, o.date_created, d.name, d.phone, d.zip, d.city, d.address, d.region, d.email, d.created_at
+FROM deliveries_legacy d
+JOIN orders_legacy o USING (order_uid);
+
+INSERT INTO payments (order_uid, date_created, transaction, request_id, currency, provider, amount,
+                      payment_dt, bank, delivery_cost, goods_total, custom_fee, created_at)
+SELECT p.order_uid, o.date_created, p.transaction, p.request_id, p.currency, p.provider, p.amount,
+       p.payment_dt, p.bank, p.delivery_cost, p.goods_total, p.custom_fee, p.created_at
+FROM payments_legacy p
+JOIN orders_legacy o USING (order_uid);
+
+INSERT INTO items (id, order_uid, date_created, chrt_id, track_number, price, rid, name, sale, size,
+                   total_price, nm_id, brand, status, created_at)
+SELECT i.id, i.order_uid, o.date_created, i.chrt_id, i.track_number, i.price, i.rid, i.name, i.sale, i.size,
+       i.total_price, i.nm_id, i.brand, i.status, i.created_at
+FROM items_legacy i
+JOIN orders_legacy o USING (order_uid);
+
+DROP TABLE items_legacy;
+DROP TABLE payments_legacy;
+DROP TABLE deliveries_legacy;
+DROP TABLE orders_legacy;
+
+CREATE INDEX IF NOT EXISTS idx_orders_track_number ON orders(track_number);
+CREATE INDEX IF NOT EXISTS idx_orders_date_created ON orders(date_created);
+CREATE INDEX IF NOT EXISTS idx_orders_customer_id ON orders(customer_id);
+CREATE INDEX IF NOT EXISTS idx_deliveries_phone ON deliveries(phone);
+CREATE INDEX IF NOT EXISTS idx_deliveries_email ON deliveries(email);
+CREATE INDEX IF NOT EXISTS idx_payments_transaction ON payments(transaction);
+CREATE INDEX IF NOT EXISTS idx_items_chrt_id ON items(chrt_id);
+CREATE INDEX IF NOT EXISTS idx_items_order_uid ON items(order_uid, date_created);
+CREATE INDEX IF NOT EXISTS idx_items_nm_id ON items(nm_id);
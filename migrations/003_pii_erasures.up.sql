@@ -0,0 +1,11 @@
+-- Журнал удаления персональных данных покупателей. Сами данные сюда не попадают,
+-- только факт стирания, количество затронутых заказов и источник запроса.
+CREATE TABLE pii_erasures (
+    id BIGSERIAL PRIMARY KEY,
+    customer_id TEXT NOT NULL,
+    orders_affected INTEGER NOT NULL CHECK (orders_affected >= 0),
+    source TEXT NOT NULL,
+    erased_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_pii_erasures_customer_id ON pii_erasures(customer_id);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_deliveries_email_bidx;
+DROP INDEX IF EXISTS idx_deliveries_phone_bidx;
+
+ALTER TABLE deliveries DROP COLUMN IF EXISTS email_bidx;
+ALTER TABLE deliveries DROP COLUMN IF EXISTS phone_bidx;
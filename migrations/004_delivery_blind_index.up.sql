@@ -0,0 +1,7 @@
+-- Слепые индексы (HMAC) телефона и email: при включённом шифровании сами значения хранятся
+-- зашифрованными, а поиск идёт по этим колонкам.
+ALTER TABLE deliveries ADD COLUMN phone_bidx TEXT;
+ALTER TABLE deliveries ADD COLUMN email_bidx TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_deliveries_phone_bidx ON deliveries(phone_bidx);
+CREATE INDEX IF NOT EXISTS idx_deliveries_email_bidx ON deliveries(email_bidx);
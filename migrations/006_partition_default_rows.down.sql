@@ -0,0 +1,13 @@
+CREATE OR REPLACE FUNCTION create_order_partitions(p_month TIMESTAMPTZ) RETURNS VOID AS $$
+DECLARE
+    from_ts TIMESTAMPTZ := date_trunc('month', p_month AT TIME ZONE 'UTC') AT TIME ZONE 'UTC';
+    to_ts   TIMESTAMPTZ := from_ts + INTERVAL '1 month';
+    suffix  TEXT := to_char(from_ts AT TIME ZONE 'UTC', '"p"YYYY_MM');
+    tbl     TEXT;
+BEGIN
+    FOREACH tbl IN ARRAY ARRAY['orders', 'deliveries', 'payments', 'items'] LOOP
+        EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+                       tbl || '_' || suffix, tbl, from_ts, to_ts);
+    END LOOP;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,37 @@
+-- Заказы месяца без партиции (дата дальше premake_months, восстановленные или переигранные старые
+-- заказы) попадают в DEFAULT партиции, и CREATE TABLE ... PARTITION OF для этого месяца падает:
+-- диапазон пересекается со строками default. Теперь партиции месяца создаются отдельными таблицами,
+-- строки месяца переносятся в них из default и только после этого таблицы присоединяются.
+CREATE OR REPLACE FUNCTION create_order_partitions(p_month TIMESTAMPTZ) RETURNS VOID AS $$
+DECLARE
+    from_ts TIMESTAMPTZ := date_trunc('month', p_month AT TIME ZONE 'UTC') AT TIME ZONE 'UTC';
+    to_ts   TIMESTAMPTZ := from_ts + INTERVAL '1 month';
+    suffix  TEXT := to_char(from_ts AT TIME ZONE 'UTC', '"p"YYYY_MM');
+    tbl     TEXT;
+BEGIN
+    -- Партиции месяца создаются и отсоединяются только вместе, поэтому достаточно проверить orders.
+    IF to_regclass(format('%I', 'orders_' || suffix)) IS NOT NULL THEN
+        RETURN;
+    END IF;
+
+    -- Новые строки месяца не должны попасть в default между переносом и присоединением.
+    LOCK TABLE orders_default, deliveries_default, payments_default, items_default IN EXCLUSIVE MODE;
+
+    FOREACH tbl IN ARRAY ARRAY['orders', 'deliveries', 'payments', 'items'] LOOP
+        EXECUTE format('CREATE TABLE %I (LIKE %I INCLUDING ALL)', tbl || '_' || suffix, tbl);
+    END LOOP;
+
+    -- Сначала дочерние таблицы: удаление из orders_default каскадно удалило бы их строки.
+    FOREACH tbl IN ARRAY ARRAY['items', 'payments', 'deliveries', 'orders'] LOOP
+        EXECUTE format('WITH moved AS (DELETE FROM %I WHERE date_created >= %L AND date_created < %L RETURNING *) '
+                       'INSERT INTO %I SELECT * FROM moved',
+                       tbl || '_default', from_ts, to_ts, tbl || '_' || suffix);
+    END LOOP;
+
+    -- orders первой: внешние ключи дочерних партиций проверяются по уже присоединённой партиции orders.
+    FOREACH tbl IN ARRAY ARRAY['orders', 'deliveries', 'payments', 'items'] LOOP
+        EXECUTE format('ALTER TABLE %I ATTACH PARTITION %I FOR VALUES FROM (%L) TO (%L)',
+                       tbl, tbl || '_' || suffix, from_ts, to_ts);
+    END LOOP;
+END;
+$$ LANGUAGE plpgsql;
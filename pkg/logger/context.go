@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"go.uber.org/zap"
+)
+
+type fieldsKey struct{}
+
+type requestIDKey struct{}
+
+// WithFields добавляет поля к логгеру запроса, который достаётся из ctx через FromContext.
+func WithFields(ctx context.Context, fields ...zap.Field) context.Context {
+	prev, _ := ctx.Value(fieldsKey{}).([]zap.Field)
+	merged := make([]zap.Field, 0, len(prev)+len(fields))
+	merged = append(merged, prev...)
+	merged = append(merged, fields...)
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+// FromContext возвращает log с полями запроса из ctx. Имя логгера компонента сохраняется,
+// поэтому сервис и репозиторий вызывают FromContext со своим логгером.
+func FromContext(ctx context.Context, log *zap.Logger) *zap.Logger {
+	fields, _ := ctx.Value(fieldsKey{}).([]zap.Field)
+	if len(fields) == 0 {
+		return log
+	}
+	return log.With(fields...)
+}
+
+// WithRequestID сохраняет correlation id в ctx и добавляет его в поля логгера как request_id.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey{}, requestID)
+	return WithFields(ctx, zap.String("request_id", requestID))
+}
+
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+func NewRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ValidRequestID не пускает в логи слишком длинные id и управляющие символы, пришедшие от клиента.
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -49,7 +49,7 @@ func ValidateOrder(order *models.Order) error {
 	}
 
 	if len(errors) > 0 {
-		return fmt.Errorf("validation failed: %s", strings.Join(errors, "; "))
+		return &models.ValidationError{Reason: strings.Join(errors, "; ")}
 	}
 
 	return nil